@@ -2,6 +2,15 @@
 
 load("@bazeldnf//internal:rpm.bzl", "null_rpm_rule")
 
+# CPU constraints of the architectures which can be selected in multi architecture lock files
+_CPU_CONSTRAINTS = {
+    "aarch64": Label("@platforms//cpu:aarch64"),
+    "i686": Label("@platforms//cpu:x86_32"),
+    "ppc64le": Label("@platforms//cpu:ppc64le"),
+    "s390x": Label("@platforms//cpu:s390x"),
+    "x86_64": Label("@platforms//cpu:x86_64"),
+}
+
 def default(name, rpms, visibility = ["//visibility:public"]):
     """
     Default behaviour for alias generation.
@@ -9,12 +18,12 @@ def default(name, rpms, visibility = ["//visibility:public"]):
     Everything depends on how many times was the given package resolved ("installed"):
      0 – it was requested, but not resolved – return empty providers
      1 – resolved – package available under its name
-    >1 – resolved in multiple architectures (not supported at the moment)
+    >1 – resolved in multiple architectures – the RPM matching the CPU of the target platform is selected
 
     Args:
       name: default target name
       rpms: list of RPM metadata; each one is a dict consisting of:
-        package (optional), id, repo_name
+        package (optional), id, repo_name, arch (only for multi architecture lock files)
         Consult `bazeldnf/extension.bzl`'s `packages_metadata` variable for more datails.
       visibility: visibility for aliases
     """
@@ -26,7 +35,21 @@ def default(name, rpms, visibility = ["//visibility:public"]):
             visibility = visibility,
         )
 
-    if len(rpms):
+    if len(rpms) and rpms[0].get("arch"):
+        actual = {}
+        for rpm in rpms:
+            if rpm["arch"] not in _CPU_CONSTRAINTS:
+                fail("%s was resolved for the unsupported architecture %s" % (name, rpm["arch"]))
+            actual[_CPU_CONSTRAINTS[rpm["arch"]]] = "@{}//rpm".format(rpm["repo_name"])
+        native.alias(
+            name = name,
+            actual = select(
+                actual,
+                no_match_error = "%s is only available for %s" % (name, ", ".join([rpm["arch"] for rpm in rpms])),
+            ),
+            visibility = visibility,
+        )
+    elif len(rpms):
         rpm = rpms[0]
         alias(
             name = name,
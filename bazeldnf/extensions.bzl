@@ -11,6 +11,8 @@ load(":repositories.bzl", "bazeldnf_register_toolchains")
 
 _DEFAULT_NAME = "bazeldnf"
 
+_MAX_LOCK_FILE_SCHEMA_VERSION = 2
+
 def _bazeldnf_toolchain_extension(module_ctx):
     repos = []
     for mod in module_ctx.modules:
@@ -91,6 +93,7 @@ update_lock_file(
     nobest = {nobest},
     cache_dir = {cache_dir},
     architectures = {architectures},
+    target_architectures = {target_architectures},
     visibility = ["//visibility:public"],
 )
 """
@@ -127,6 +130,7 @@ def _alias_repository_impl(repository_ctx):
             repofile = repofile,
             nobest = "True" if repository_ctx.attr.nobest else "False",
             architectures = repr(repository_ctx.attr.architectures),
+            target_architectures = repr(repository_ctx.attr.target_architectures),
         ),
     )
 
@@ -160,6 +164,7 @@ _alias_repository = repository_rule(
         "nobest": attr.bool(default = False),
         "cache_dir": attr.string(),
         "architectures": attr.string_list(),
+        "target_architectures": attr.string_list(),
     },
 )
 
@@ -241,6 +246,7 @@ def _handle_lock_file(config, module_ctx, registered_rpms = {}, registered_blobs
         "repository_prefix": config.rpm_repository_prefix,
         "nobest": config.nobest,
         "architectures": _get_architectures(config.architecture, config.architectures),
+        "target_architectures": config.target_architectures,
     }
 
     module_ctx.watch(config.lock_file)
@@ -254,48 +260,23 @@ def _handle_lock_file(config, module_ctx, registered_rpms = {}, registered_blobs
     # - package - just an RPM package name (optional - lock file may be missing it)
     # - id - some unique identifier for the config
     # - repo_name - apparent repo name where the .rpm file is downloaded to
+    # - arch - architecture the RPM was resolved for (only for multi architecture lock files)
     packages_metadata = {}
 
     if module_ctx.path(config.lock_file).exists:
         content = module_ctx.read(config.lock_file)
         lock_file_json = json.decode(content)
 
-        # Build lookup dictionary for efficient RPM access
-        rpm_lookup = _build_rpm_lookup(lock_file_json.get("rpms", []))
-
-        # Create a blob repository for each available rpm in the lock file
-        for rpm in lock_file_json.get("rpms", []):
-            _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs)
-
-        # Create repositories for each top-level target with suffixed dependencies
-        for target in config.rpms:
-            if target in rpm_lookup:
-                # Build transitive dependency closure for this target
-                target_deps = _build_transitive_deps(rpm_lookup, target)
-                repo_info = _add_rpm_repository(config, rpm_lookup[target], registered_rpms, dependencies = target_deps)
-            else:
-                repo_info = _add_missing_rpm_repository(config, target, registered_rpms)
-            packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
-
-        if not config.rpms:
-            # if the user didn't ask for a list of RPMs then make all of the RPMs available with no dependencies
-            for rpm in rpm_lookup.values():
-                blob_name, _, _ = _normalize_repository_name(rpm, config.rpm_repository_prefix, config.lock_file)
-                repo_info = _add_rpm_repository(config, rpm, registered_rpms, [blob_name])
-                packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
-
-        for rpm in rpm_lookup.values():
-            # for RPMs with no id or name then we will make then available with only a dependency to it's blob
-            if rpm.get("id", None) or rpm.get("name"):
-                continue
-            blob_name, _, _ = _normalize_repository_name(rpm, config.rpm_repository_prefix, config.lock_file)
-            repo_info = _add_rpm_repository(config, rpm, registered_rpms, [blob_name])
-            packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+        # schema version 2 only adds package metadata, unknown versions may change the format
+        schema_version = lock_file_json.get("schema-version", 1)
+        if schema_version > _MAX_LOCK_FILE_SCHEMA_VERSION:
+            fail("lock file %s has schema version %s, this version of bazeldnf supports up to %s" % (config.lock_file, schema_version, _MAX_LOCK_FILE_SCHEMA_VERSION))
 
-        # if there's targets without matching RPMs we need to create a null target
-        # so that consumers have something consistent that they can depend on
-        for target in lock_file_json.get("targets", []):
-            packages_metadata.setdefault(target, [])
+        if "arches" in lock_file_json:
+            _handle_multi_arch_lock_file(config, lock_file_json, registered_rpms, registered_blobs, packages_metadata)
+        else:
+            _handle_single_arch_lock_file(config, lock_file_json, registered_rpms, registered_blobs, packages_metadata)
+        _add_id_aliases(packages_metadata)
     elif config.ignore_missing_lockfile:
         for target in config.rpms:
             packages_metadata.setdefault(target, [])
@@ -309,6 +290,106 @@ def _handle_lock_file(config, module_ctx, registered_rpms = {}, registered_blobs
 
     return config.name
 
+def _add_id_aliases(packages_metadata):
+    """Makes the RPMs available under their ids as well.
+
+    Lock files of `bazeldnf migrate` can contain the same package for several architectures
+    or versions, only then their RPMs are referenced by the unique ids instead of the package name.
+    """
+    ids = {}
+    for repo_infos in packages_metadata.values():
+        for repo_info in repo_infos:
+            id = repo_info["id"]
+            if id != repo_info.get("package", id) and repo_info not in ids.get(id, []):
+                ids.setdefault(id, []).append(repo_info)
+    for id, repo_infos in ids.items():
+        if id not in packages_metadata:
+            packages_metadata[id] = repo_infos
+
+def _handle_single_arch_lock_file(config, lock_file_json, registered_rpms, registered_blobs, packages_metadata):
+    # Build lookup dictionary for efficient RPM access
+    rpm_lookup = _build_rpm_lookup(lock_file_json.get("rpms", []))
+
+    # Create a blob repository for each available rpm in the lock file
+    for rpm in lock_file_json.get("rpms", []):
+        _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs)
+
+    # Create repositories for each top-level target with suffixed dependencies
+    for target in config.rpms:
+        if target in rpm_lookup:
+            # Build transitive dependency closure for this target
+            target_deps = _build_transitive_deps(rpm_lookup, target)
+            repo_info = _add_rpm_repository(config, rpm_lookup[target], registered_rpms, dependencies = target_deps)
+        else:
+            repo_info = _add_missing_rpm_repository(config, target, registered_rpms)
+        packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+
+    if not config.rpms:
+        # if the user didn't ask for a list of RPMs then make all of the RPMs available with no dependencies
+        for rpm in rpm_lookup.values():
+            blob_name, _, _ = _normalize_repository_name(rpm, config.rpm_repository_prefix, config.lock_file)
+            repo_info = _add_rpm_repository(config, rpm, registered_rpms, [blob_name])
+            packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+
+    for rpm in rpm_lookup.values():
+        # for RPMs with no id or name then we will make then available with only a dependency to it's blob
+        if rpm.get("id", None) or rpm.get("name"):
+            continue
+        blob_name, _, _ = _normalize_repository_name(rpm, config.rpm_repository_prefix, config.lock_file)
+        repo_info = _add_rpm_repository(config, rpm, registered_rpms, [blob_name])
+        packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+
+    # if there's targets without matching RPMs we need to create a null target
+    # so that consumers have something consistent that they can depend on
+    for target in lock_file_json.get("targets", []):
+        packages_metadata.setdefault(target, [])
+
+def _handle_multi_arch_lock_file(config, lock_file_json, registered_rpms, registered_blobs, packages_metadata):
+    """Creates the repositories for a lock file with per architecture package lists.
+
+    Every architecture gets its own set of RPM repositories. The shared noarch RPMs are
+    downloaded only once, but their dependencies are resolved per architecture.
+    """
+    noarch = lock_file_json.get("noarch", [])
+    shared_blobs = {}
+    for rpm in noarch:
+        shared_blobs[rpm["id"]] = _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs)
+
+    for arch, arch_config in sorted(lock_file_json["arches"].items()):
+        blobs = dict(shared_blobs)
+        rpms = list(arch_config.get("rpms", []))
+        for rpm in rpms:
+            blobs[rpm["id"]] = _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs, arch = arch)
+
+        noarch_dependencies = arch_config.get("noarch-dependencies", {})
+        for rpm in noarch:
+            if rpm["id"] in noarch_dependencies:
+                shared = dict(rpm)
+                shared["dependencies"] = noarch_dependencies[rpm["id"]]
+                rpms.append(shared)
+
+        rpm_lookup = _build_rpm_lookup(rpms)
+
+        for target in config.rpms:
+            # targets missing on this architecture fail only when they are selected
+            if target in rpm_lookup:
+                target_deps = _build_transitive_deps(rpm_lookup, target)
+                repo_info = _add_rpm_repository(config, rpm_lookup[target], registered_rpms, dependencies = target_deps, arch = arch, blobs = blobs)
+                packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+
+        if not config.rpms:
+            for id, rpm in rpm_lookup.items():
+                repo_info = _add_rpm_repository(config, rpm, registered_rpms, [id], arch = arch, blobs = blobs)
+                packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+
+    for target in config.rpms:
+        if target not in packages_metadata:
+            repo_info = _add_missing_rpm_repository(config, target, registered_rpms)
+            packages_metadata.setdefault(repo_info.get("package", repo_info["id"]), []).append(repo_info)
+
+    for target in lock_file_json.get("targets", []):
+        packages_metadata.setdefault(target, [])
+
 def _normalize_repository_name(rpm, rpm_repository_prefix, lock_file):
     # Older lockfiles may not have `id` field.
     # Name was the equivalent. We need to pop both.
@@ -331,12 +412,15 @@ def _get_blob_prefix(rpm_repository_prefix):
         return "blob-"
     return "blob-{}-".format(rpm_repository_prefix)
 
-def _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs):
-    name, _, _ = _normalize_repository_name(rpm, _get_blob_prefix(config.rpm_repository_prefix), config.lock_file)
+def _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs, arch = None):
+    prefix = _get_blob_prefix(config.rpm_repository_prefix)
+    if arch:
+        prefix = "{}{}-".format(prefix, arch)
+    name, _, _ = _normalize_repository_name(rpm, prefix, config.lock_file)
 
     # prevent the same blob to be registered more than once, needed for multiple lock files
     if name in registered_blobs:
-        return
+        return name
 
     registered_blobs[name] = 1
 
@@ -357,18 +441,24 @@ def _add_blob_rpm_repository(config, rpm, lock_file_json, registered_blobs):
         blob_mode = True,
     )
 
-    return
+    return name
 
-def _add_rpm_repository(config, rpm, registered_rpms, dependencies = []):
-    # fix for cases like c++
-    dependencies = [x.replace("+", "plus") for x in dependencies]
+def _add_rpm_repository(config, rpm, registered_rpms, dependencies = [], arch = None, blobs = None):
+    if blobs != None:
+        # multi architecture lock files map the ids to the blob of the matching architecture
+        dependencies = ["@{}//blob".format(blobs[x]) for x in dependencies if x in blobs]
+    else:
+        # fix for cases like c++
+        dependencies = [x.replace("+", "plus") for x in dependencies]
 
-    # point to the actual blob
-    dependencies = ["@{}{}//blob".format(_get_blob_prefix(config.rpm_repository_prefix), x) for x in dependencies]
+        # point to the actual blob
+        dependencies = ["@{}{}//blob".format(_get_blob_prefix(config.rpm_repository_prefix), x) for x in dependencies]
 
     repo_prefix = config.rpm_repository_prefix
     if repo_prefix:
         repo_prefix = "{}-".format(repo_prefix)
+    if arch:
+        repo_prefix = "{}{}-".format(repo_prefix, arch)
 
     name, id, package = _normalize_repository_name(rpm, repo_prefix, config.lock_file)
 
@@ -392,6 +482,9 @@ def _add_rpm_repository(config, rpm, registered_rpms, dependencies = []):
     if package:
         metadata["package"] = package
 
+    if arch:
+        metadata["arch"] = arch
+
     registered_rpms[name] = metadata
 
     return metadata
@@ -581,6 +674,11 @@ The lock file content is as:
                 with the first one having the highest priority.
                 `noarch` is implicitly added at the end (if not present on the list).""",
         ),
+        "target_architectures": attr.string_list(
+            doc = """Resolve the RPMs separately for each of these architectures into one lock file.
+
+                The generated targets select the RPMs of the target platform's CPU.""",
+        ),
     },
 )
 
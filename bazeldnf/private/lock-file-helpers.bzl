@@ -24,6 +24,9 @@ def _collect_lockfile_args(ctx):
     if ctx.attr.architectures:
         lockfile_args.extend(["--arch", ",".join(ctx.attr.architectures)])
 
+    if ctx.attr.target_architectures:
+        lockfile_args.extend(["--target-arch", ",".join(ctx.attr.target_architectures)])
+
     lockfile_args.append("--ignore-missing")
 
     return lockfile_args
@@ -71,6 +74,7 @@ update_lock_file = rule(
         "nobest": attr.bool(default = False),
         "cache_dir": attr.string(),
         "architectures": attr.string_list(),
+        "target_architectures": attr.string_list(),
         "_runner": attr.label(allow_single_file = True, default = Label("//bazeldnf/private:update-lock-file.sh")),
     },
     toolchains = [
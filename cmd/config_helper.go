@@ -30,13 +30,20 @@ func sortedPackages(pkgs []*api.Package) []*api.Package {
 	})
 }
 
-func toConfig(install, forceIgnored []*api.Package, targets []string, cmdline []string) (*bazeldnf.Config, error) {
+// toConfig creates a lock file config for the installed packages. Dependencies
+// which are satisfied by packages of the base layer are not recorded, since
+// the base layer is already installed. Schema version 2 and newer record the
+// package metadata as well.
+func toConfig(install, forceIgnored, base []*api.Package, targets []string, cmdline []string, schemaVersion int) (*bazeldnf.Config, error) {
 	ignored := make(map[*api.Package]bool)
 	ignoredNames := make(map[string]bool)
 	for _, forceIgnoredPackage := range forceIgnored {
 		ignored[forceIgnoredPackage] = true
 		ignoredNames[forceIgnoredPackage.Name] = true
 	}
+	for _, basePackage := range base {
+		ignored[basePackage] = true
+	}
 
 	allPackages := make(map[*api.Package]*bazeldnf.RPM)
 	repositories := make(map[string][]string)
@@ -63,9 +70,12 @@ func toConfig(install, forceIgnored []*api.Package, targets []string, cmdline []
 			Repository:   installPackage.Repository.Name,
 			Dependencies: deps,
 		}
+		if schemaVersion >= bazeldnf.SchemaVersion2 {
+			addMetadata(allPackages[installPackage], installPackage)
+		}
 	}
 
-	providers := collectProviders(forceIgnored, install)
+	providers := collectProviders(forceIgnored, base, install)
 	packageNames := sortedPackages(maps.Keys(allPackages))
 	sortedPackages := make([]*bazeldnf.RPM, 0, len(packageNames))
 	for _, name := range packageNames {
@@ -90,10 +100,27 @@ func toConfig(install, forceIgnored []*api.Package, targets []string, cmdline []
 		Repositories:         repositories,
 		Targets:              targets,
 	}
+	if schemaVersion > bazeldnf.SchemaVersion1 {
+		lockFile.SchemaVersion = schemaVersion
+	}
 
 	return &lockFile, nil
 }
 
+// addMetadata records the package metadata which is lost otherwise once the
+// lock file is written
+func addMetadata(rpm *bazeldnf.RPM, pkg *api.Package) {
+	rpm.Epoch = pkg.Version.Epoch
+	rpm.Version = pkg.Version.Ver
+	rpm.Release = pkg.Version.Rel
+	rpm.Arch = pkg.Arch
+	rpm.License = pkg.Format.License
+	rpm.SourceRPM = pkg.Format.Sourcerpm
+	rpm.Vendor = pkg.Format.Vendor
+	rpm.Size = pkg.Size.Package
+	rpm.InstalledSize = pkg.Size.Installed
+}
+
 func collectProviders(pkgSets ...[]*api.Package) map[string][]*api.Package {
 	providers := map[string][]*api.Package{}
 	for _, pkgSet := range pkgSets {
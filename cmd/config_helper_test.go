@@ -2,12 +2,15 @@ package main
 
 import (
 	"errors"
+	"path/filepath"
 	"slices"
 	"testing"
 
 	. "github.com/onsi/gomega"
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/spf13/cobra"
 )
 
 func TestBaseCase(t *testing.T) {
@@ -21,7 +24,7 @@ func TestBaseCase(t *testing.T) {
 		Targets:              []string{},
 		ForceIgnored:         []string{},
 	}
-	cfg, err := toConfig([]*api.Package{}, []*api.Package{}, []string{}, []string{})
+	cfg, err := toConfig([]*api.Package{}, []*api.Package{}, nil, []string{}, []string{}, bazeldnf.SchemaVersion1)
 
 	g.Expect(err).Should(BeNil())
 	g.Expect(cfg).Should(Equal(expected))
@@ -45,7 +48,7 @@ func TestSimpleInputs(t *testing.T) {
 		Targets:              targets,
 		ForceIgnored:         []string{"package0", "package1"},
 	}
-	cfg, err := toConfig([]*api.Package{}, ignored, targets, commandline)
+	cfg, err := toConfig([]*api.Package{}, ignored, nil, targets, commandline, bazeldnf.SchemaVersion1)
 
 	g.Expect(err).Should(BeNil())
 	g.Expect(cfg).Should(Equal(expected))
@@ -59,8 +62,10 @@ func TestMissingProvider(t *testing.T) {
 			newPackageWithDeps("parent", "somedep"),
 		},
 		[]*api.Package{},
+		nil,
 		[]string{},
 		[]string{},
+		bazeldnf.SchemaVersion1,
 	)
 
 	g.Expect(err).Should(Equal(errors.New("could not find provider for somedep")))
@@ -454,8 +459,10 @@ func TestConfigTransform(t *testing.T) {
 			cfg, err := toConfig(
 				tt.installed,
 				tt.ignored,
+				nil,
 				[]string{},
 				[]string{},
+				bazeldnf.SchemaVersion1,
 			)
 
 			g.Expect(err).Should(BeNil())
@@ -463,3 +470,159 @@ func TestConfigTransform(t *testing.T) {
 		})
 	}
 }
+
+func TestBaseDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	base := []*api.Package{newPackageWithDeps("glibc")}
+	install := []*api.Package{newPackageWithDeps("bash", "glibc")}
+
+	cfg, err := toConfig(install, nil, base, []string{"bash"}, []string{}, bazeldnf.SchemaVersion1)
+
+	g.Expect(err).Should(BeNil())
+	g.Expect(cfg.RPMs).Should(HaveLen(1))
+	g.Expect(cfg.RPMs[0].Name).Should(Equal("bash"))
+	g.Expect(cfg.RPMs[0].Dependencies).Should(BeEmpty())
+}
+
+func TestLoadBase(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	lockfile := filepath.Join(t.TempDir(), "base.json")
+	config := &bazeldnf.Config{
+		Repositories: map[string][]string{"fedora": {"https://example.com/fedora/44/"}},
+		RPMs: []*bazeldnf.RPM{
+			// the file name does not need to follow the name-version-release.arch.rpm scheme
+			{Id: "libvirt-daemon-driver-qemu", Name: "libvirt-daemon-driver-qemu", URLs: []string{"Packages/l/qemu-driver.rpm"}, Repository: "fedora"},
+			{Id: "glibc", Name: "glibc", URLs: []string{"Packages/g/glibc-2.41-3.fc44.x86_64.rpm"}, Repository: "fedora"},
+		},
+	}
+	g.Expect(bazel.WriteLockFile(config, lockfile)).To(Succeed())
+
+	rpms, names, err := loadBase(lockfile, "x86_64")
+	g.Expect(err).Should(BeNil())
+	g.Expect(rpms).Should(HaveLen(2))
+	g.Expect(names).Should(Equal([]string{"libvirt-daemon-driver-qemu", "glibc"}))
+}
+
+func TestResolveRequiresArchitecture(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	restoreLockFileOptions(t)
+	resolvehelperopts.arch = []string{}
+
+	_, _, _, err := resolve(&bazeldnf.Repositories{}, []string{"bash"})
+	g.Expect(err).Should(MatchError("at least one architecture is required"))
+}
+
+func TestFindInstalled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	available := &api.Package{Name: "bash", Arch: "x86_64", Version: api.Version{Epoch: "0", Ver: "5.2.37", Rel: "1.fc44"}}
+	newer := &api.Package{Name: "glibc", Arch: "x86_64", Version: api.Version{Epoch: "0", Ver: "2.42", Rel: "1.fc44"}}
+	installedBash := &api.Package{Name: "bash", Arch: "x86_64", Version: api.Version{Ver: "5.2.37", Rel: "1.fc44"}}
+	installedGlibc := &api.Package{Name: "glibc", Arch: "x86_64", Version: api.Version{Epoch: "0", Ver: "2.41", Rel: "3.fc44"}}
+
+	base, involved := findInstalled([]*api.Package{installedBash, installedGlibc}, []*api.Package{available, newer})
+	// available packages are taken from the repositories, others from the rpm database
+	g.Expect(base).Should(Equal([]*api.Package{available, installedGlibc}))
+	g.Expect(involved).Should(Equal([]*api.Package{available, newer, installedGlibc}))
+}
+
+func TestMergeArchConfigs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	rpm := func(id, file, integrity string, deps ...string) *bazeldnf.RPM {
+		return &bazeldnf.RPM{Id: id, Name: id, Integrity: integrity, URLs: []string{"Packages/" + file}, Repository: "fedora", Dependencies: deps}
+	}
+	configs := map[string]*bazeldnf.Config{
+		"x86_64": {
+			Repositories: map[string][]string{"fedora": {"https://x86_64"}},
+			RPMs: []*bazeldnf.RPM{
+				rpm("bash", "bash-5.2-1.x86_64.rpm", "sha256-a", "glibc"),
+				rpm("data", "data-1-1.noarch.rpm", "sha256-d", "bash"),
+				rpm("glibc", "glibc-2.41-1.x86_64.rpm", "sha256-b"),
+				rpm("tzdata", "tzdata-1-1.noarch.rpm", "sha256-t1"),
+			},
+			Targets:      []string{"bash"},
+			ForceIgnored: []string{"systemd"},
+		},
+		"aarch64": {
+			Repositories: map[string][]string{"fedora-aarch64": {"https://aarch64"}},
+			RPMs: []*bazeldnf.RPM{
+				rpm("bash", "bash-5.2-1.aarch64.rpm", "sha256-c"),
+				rpm("data", "data-1-1.noarch.rpm", "sha256-d"),
+				rpm("tzdata", "tzdata-2-1.noarch.rpm", "sha256-t2"),
+			},
+			Targets: []string{"bash"},
+		},
+	}
+
+	merged := mergeArchConfigs([]string{"x86_64", "aarch64"}, configs)
+	g.Expect(merged.RPMs).To(BeEmpty())
+	g.Expect(merged.Targets).To(Equal([]string{"bash"}))
+	g.Expect(merged.Repositories).To(HaveLen(2))
+	// only noarch packages which are identical on all architectures are shared
+	shared := rpm("data", "data-1-1.noarch.rpm", "sha256-d")
+	shared.Dependencies = []string{}
+	g.Expect(merged.Noarch).To(Equal([]*bazeldnf.RPM{shared}))
+	g.Expect(merged.Arches["x86_64"].NoarchDependencies).To(Equal(map[string][]string{"data": {"bash"}}))
+	g.Expect(merged.Arches["x86_64"].ForceIgnored).To(Equal([]string{"systemd"}))
+	g.Expect(merged.Arches["aarch64"].RPMs).To(Equal([]*bazeldnf.RPM{
+		rpm("bash", "bash-5.2-1.aarch64.rpm", "sha256-c"),
+		rpm("tzdata", "tzdata-2-1.noarch.rpm", "sha256-t2"),
+	}))
+
+	// the lock file of one architecture can be read back from the merged one
+	rpms, err := merged.ArchRPMs("x86_64")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rpms).To(ConsistOf(configs["x86_64"].RPMs))
+	_, err = merged.ArchRPMs("s390x")
+	g.Expect(err).To(MatchError(ContainSubstring("no packages for architecture s390x")))
+}
+
+func TestCheckTargetArchFlags(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringSlice("arch", []string{"x86_64"}, "")
+		cmd.Flags().StringSlice("target-arch", []string{}, "")
+		g.Expect(cmd.ParseFlags(args)).To(Succeed())
+		return cmd
+	}
+
+	g.Expect(checkTargetArchFlags(newCmd("--target-arch", "x86_64,aarch64"))).To(Succeed())
+	g.Expect(checkTargetArchFlags(newCmd("--arch", "x86_64,i686"))).To(Succeed())
+	// the multilib architecture would be dropped for the target architecture runs
+	g.Expect(checkTargetArchFlags(newCmd("--arch", "x86_64,i686", "--target-arch", "x86_64,aarch64"))).To(HaveOccurred())
+}
+
+func TestMetadata(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bash := newPackageWithDeps("bash")
+	bash.Arch = "x86_64"
+	bash.Version = api.Version{Epoch: "0", Ver: "5.2.37", Rel: "1.fc44"}
+	bash.Format.License = "GPL-3.0-or-later"
+	bash.Format.Sourcerpm = "bash-5.2.37-1.fc44.src.rpm"
+	bash.Format.Vendor = "Fedora Project"
+	bash.Size.Package = 1900000
+	bash.Size.Installed = 8400000
+
+	cfg, err := toConfig([]*api.Package{bash}, nil, nil, []string{"bash"}, []string{}, bazeldnf.SchemaVersion1)
+	g.Expect(err).Should(BeNil())
+	g.Expect(cfg.SchemaVersion).Should(BeZero())
+	g.Expect(cfg.HasMetadata()).Should(BeFalse())
+	g.Expect(cfg.RPMs[0].License).Should(BeEmpty())
+
+	cfg, err = toConfig([]*api.Package{bash}, nil, nil, []string{"bash"}, []string{}, bazeldnf.SchemaVersion2)
+	g.Expect(err).Should(BeNil())
+	g.Expect(cfg.SchemaVersion).Should(Equal(bazeldnf.SchemaVersion2))
+	g.Expect(cfg.HasMetadata()).Should(BeTrue())
+	rpm := cfg.RPMs[0]
+	g.Expect([]string{rpm.Epoch, rpm.Version, rpm.Release, rpm.Arch}).Should(Equal([]string{"0", "5.2.37", "1.fc44", "x86_64"}))
+	g.Expect([]string{rpm.License, rpm.SourceRPM, rpm.Vendor}).Should(Equal([]string{"GPL-3.0-or-later", "bash-5.2.37-1.fc44.src.rpm", "Fedora Project"}))
+	g.Expect(rpm.Size).Should(Equal(1900000))
+	g.Expect(rpm.InstalledSize).Should(Equal(8400000))
+}
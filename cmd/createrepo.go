@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/spf13/cobra"
+)
+
+type createrepoOpts struct {
+	filelists bool
+	update    bool
+}
+
+var createrepoopts = createrepoOpts{}
+
+func NewCreateRepoCmd() *cobra.Command {
+
+	createrepoCmd := &cobra.Command{
+		Use:   "createrepo <directory>",
+		Short: "Create repository metadata for a directory of RPMs",
+		Long:  `Reads the headers of all RPMs in the given directory and writes rpm-md metadata (repodata/repomd.xml and friends) which can be referenced via baseurl in a repo.yaml file`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return repo.NewRepoCreator(args[0], createrepoopts.filelists, createrepoopts.update).Create()
+		},
+	}
+
+	createrepoCmd.Flags().BoolVar(&createrepoopts.filelists, "filelists", false, "also write filelists metadata")
+	createrepoCmd.Flags().BoolVar(&createrepoopts.update, "update", false, "reuse existing metadata of unchanged RPMs")
+	return createrepoCmd
+}
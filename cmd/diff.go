@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockdiff"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type diffOpts struct {
+	repofiles []string
+	output    string
+}
+
+var diffopts = diffOpts{}
+
+func NewDiffCmd() *cobra.Command {
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <old lockfile> <new lockfile>",
+		Short: "Show the package changes between two lock files",
+		Long: `Show which packages were added, removed, upgraded or downgraded between two
+lock files and how their dependencies changed. If other.xml of the repositories
+was fetched with 'bazeldnf fetch --changelogs', the changelog entries of
+upgraded packages are shown as well.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if diffopts.output != "text" && diffopts.output != "markdown" {
+				return fmt.Errorf("unsupported output format %s, supported are text and markdown", diffopts.output)
+			}
+			before, err := bazel.LoadLockFile(args[0])
+			if err != nil {
+				return err
+			}
+			after, err := bazel.LoadLockFile(args[1])
+			if err != nil {
+				return err
+			}
+			changes, err := lockdiff.Compare(before, after)
+			if err != nil {
+				return err
+			}
+			addChangelogs(changes)
+
+			if diffopts.output == "markdown" {
+				return lockdiff.WriteMarkdown(os.Stdout, changes)
+			}
+			return lockdiff.WriteText(os.Stdout, changes)
+		},
+	}
+
+	diffCmd.Flags().StringArrayVarP(&diffopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file to look up changelogs. Can be specified multiple times")
+	diffCmd.Flags().StringVarP(&diffopts.output, "output", "o", "text", "output format, text or markdown")
+	repo.AddCacheHelperFlags(diffCmd)
+	return diffCmd
+}
+
+// addChangelogs adds the changelog entries since the old version to upgraded
+// packages, if other.xml of their repository is cached
+func addChangelogs(changes []*lockdiff.Change) {
+	names := map[string]map[string]bool{}
+	for _, change := range changes {
+		if change.Kind != lockdiff.Upgraded {
+			continue
+		}
+		if names[change.New.Repository] == nil {
+			names[change.New.Repository] = map[string]bool{}
+		}
+		names[change.New.Repository][change.Name] = true
+	}
+	if len(names) == 0 {
+		return
+	}
+	repos, err := repo.LoadRepoFiles(diffopts.repofiles)
+	if err != nil {
+		logrus.Infof("Changelogs are not shown, the repositories can't be loaded: %v", err)
+		return
+	}
+
+	cacheHelper := repo.NewCacheHelper()
+	for _, r := range repos.Repositories {
+		if names[r.Name] == nil {
+			continue
+		}
+		pkgs, err := cacheHelper.CurrentChangelogs(&r, names[r.Name])
+		if err != nil {
+			logrus.Infof("Changelogs of repository %s are not shown: %v", r.Name, err)
+			continue
+		}
+		for _, change := range changes {
+			if change.Kind != lockdiff.Upgraded || change.New.Repository != r.Name {
+				continue
+			}
+			for _, pkg := range pkgs {
+				if pkg.Name == change.Name && pkg.Arch == change.New.Arch && pkg.Version.Ver == change.New.Version.Ver && pkg.Version.Rel == change.New.Version.Rel {
+					change.Changelog = lockdiff.ChangelogSince(pkg.Changelog, change.Old.Version, pkg.Version)
+					break
+				}
+			}
+		}
+	}
+}
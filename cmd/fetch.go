@@ -6,7 +6,8 @@ import (
 )
 
 type FetchOpts struct {
-	repofiles []string
+	repofiles  []string
+	changelogs bool
 }
 
 var fetchopts = &FetchOpts{}
@@ -18,15 +19,19 @@ func NewFetchCmd() *cobra.Command {
 		Short: "Update repo metadata",
 		Long:  `Update repo metadata`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			repos, err := repo.LoadRepoFiles(fetchopts.repofiles)
+			repos, err := repo.LoadRepoFilesWithFilters(fetchopts.repofiles)
 			if err != nil {
 				return err
 			}
-			return repo.NewRemoteRepoFetcher(repos.Repositories).Fetch()
+			fetcher := repo.NewRemoteRepoFetcher(repos.Repositories)
+			fetcher.Changelogs = fetchopts.changelogs
+			return fetcher.Fetch()
 		},
 	}
 
 	fetchCmd.Flags().StringArrayVarP(&fetchopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times")
+	fetchCmd.Flags().BoolVar(&fetchopts.changelogs, "changelogs", false, "also fetch other.xml, which contains the changelogs shown by 'bazeldnf diff'")
 	repo.AddCacheHelperFlags(fetchCmd)
+	repo.AddRepoFilterFlags(fetchCmd)
 	return fetchCmd
 }
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 type InitOpts struct {
-	arch string
-	fc   string
-	out  string
+	arch      string
+	fc        string
+	distros   []string
+	release   string
+	templates []string
+	list      bool
+	out       string
 }
 
 var initopts = InitOpts{}
@@ -17,19 +25,63 @@ func NewInitCmd() *cobra.Command {
 
 	initCmd := &cobra.Command{
 		Use:   "init",
-		Short: "Create basic repo.yaml files for fedora releases",
-		Long:  `Create proper repo information with release- and update repos for fedora releases`,
+		Short: "Create basic repo.yaml files for distribution releases",
+		Long: `Create proper repo information for distribution releases based on a catalog of distribution templates.
+Multiple distributions can be combined, e.g. "--distro centos-stream --distro epel".`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return repo.NewRemoteInit(initopts.fc, initopts.arch, initopts.out).Init()
+			catalog, err := repo.NewDistroCatalog(initopts.templates...)
+			if err != nil {
+				return err
+			}
+
+			if initopts.list {
+				for _, name := range catalog.Names() {
+					distro, _ := catalog.Get(name)
+					fmt.Printf("%s\t%s (arches: %s)\n", distro.Name, distro.Description, strings.Join(distro.Arches, ", "))
+				}
+				return nil
+			}
+
+			release := initopts.release
+			if initopts.fc != "" {
+				release = strings.TrimPrefix(initopts.fc, "f")
+			}
+
+			distros := []*repo.Distro{}
+			baseSystems := []string{}
+			for _, name := range initopts.distros {
+				distro, err := catalog.Get(name)
+				if err != nil {
+					return err
+				}
+				distros = append(distros, distro)
+				if distro.BaseSystem != "" {
+					baseSystems = append(baseSystems, distro.BaseSystem)
+				}
+			}
+
+			repoInit, err := repo.NewDistroInit(distros, release, initopts.arch, initopts.out)
+			if err != nil {
+				return err
+			}
+			if err := repoInit.Init(); err != nil {
+				return err
+			}
+			if len(baseSystems) > 0 {
+				logrus.Infof("Wrote %s, use --basesystem %s when resolving packages", initopts.out, baseSystems[0])
+			}
+			return nil
 		},
 	}
 
 	initCmd.Flags().StringVarP(&initopts.arch, "arch", "a", "x86_64", "target architecture")
-	initCmd.Flags().StringVar(&initopts.fc, "fc", "", "target fedora core release")
+	initCmd.Flags().StringArrayVar(&initopts.distros, "distro", []string{"fedora"}, "distribution to create repositories for. Can be specified multiple times")
+	initCmd.Flags().StringVar(&initopts.release, "release", "", "release of the distribution")
+	initCmd.Flags().StringArrayVar(&initopts.templates, "template", nil, "additional distribution template file. Templates replace builtin distributions with the same name. Can be specified multiple times")
+	initCmd.Flags().BoolVar(&initopts.list, "list", false, "list all known distributions")
 	initCmd.Flags().StringVarP(&initopts.out, "output", "o", "repo.yaml", "where to write the repository information")
-	err := initCmd.MarkFlagRequired("fc")
-	if err != nil {
-		panic(err)
-	}
+	// deprecated options
+	initCmd.Flags().StringVar(&initopts.fc, "fc", "", "target fedora core release")
+	initCmd.Flags().MarkDeprecated("fc", "use --distro fedora --release instead")
 	return initCmd
 }
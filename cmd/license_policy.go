@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/license"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// licenseViolation is a package whose license is not permitted by the license policy
+type licenseViolation struct {
+	pkg    *api.Package
+	reason error
+	chain  []string
+}
+
+func (v licenseViolation) String() string {
+	s := fmt.Sprintf("%s (%s): %v", v.pkg.MatchableString(), v.pkg.Format.License, v.reason)
+	if len(v.chain) > 1 {
+		s += ", pulled in by " + strings.Join(v.chain, " -> ")
+	} else {
+		s += ", requested directly"
+	}
+	return s
+}
+
+// loadLicensePolicy returns the license policy of the given files, or nil if
+// no policy is given
+func loadLicensePolicy(files []string) (*license.Policy, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	policy, err := repo.LoadLicensePolicyFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	return license.NewPolicy(policy.Allow, policy.Deny, policy.AllowNonSPDX)
+}
+
+// checkLicensePolicy checks the licenses of the packages to install, except
+// the packages of the base. Violations fail the resolution, unless only a
+// report is requested.
+func checkLicensePolicy(policy *license.Policy, install, base []*api.Package, required []string) error {
+	if policy == nil {
+		return nil
+	}
+	violations := licenseViolations(policy, install, base, required)
+	if len(violations) == 0 {
+		logrus.Info("All packages comply with the license policy.")
+		return nil
+	}
+	lines := []string{}
+	for _, violation := range violations {
+		lines = append(lines, violation.String())
+	}
+	if resolvehelperopts.licenseReportOnly {
+		for _, line := range lines {
+			logrus.Warnf("License policy violation: %s", line)
+		}
+		return nil
+	}
+	return fmt.Errorf("packages violate the license policy:\n  %s", strings.Join(lines, "\n  "))
+}
+
+func licenseViolations(policy *license.Policy, install, base []*api.Package, required []string) []licenseViolation {
+	baseKeys := map[api.PackageKey]bool{}
+	for _, pkg := range base {
+		baseKeys[pkg.Key()] = true
+	}
+	var parents map[*api.Package]*api.Package
+	violations := []licenseViolation{}
+	for _, pkg := range sortedPackages(install) {
+		if baseKeys[pkg.Key()] {
+			continue
+		}
+		err := policy.Check(pkg.Format.License)
+		if err == nil {
+			continue
+		}
+		if parents == nil {
+			parents = dependencyParents(install, required)
+		}
+		violations = append(violations, licenseViolation{pkg: pkg, reason: err, chain: dependencyChain(parents, pkg)})
+	}
+	return violations
+}
+
+// dependencyParents walks the dependencies of the packages breadth first,
+// starting with the packages which provide the required names. It returns
+// the package through which each package was reached first. The required
+// packages are their own parents.
+func dependencyParents(install []*api.Package, required []string) map[*api.Package]*api.Package {
+	install = sortedPackages(install)
+	providers := collectProviders(install)
+	parents := map[*api.Package]*api.Package{}
+	queue := []*api.Package{}
+	for _, req := range required {
+		for _, pkg := range install {
+			if pkg.Name == req {
+				providers[req] = append(providers[req], pkg)
+			}
+		}
+		for _, pkg := range providers[req] {
+			if _, exists := parents[pkg]; !exists {
+				parents[pkg] = pkg
+				queue = append(queue, pkg)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, entry := range pkg.Format.Requires.Entries {
+			for _, provider := range providers[entry.Name] {
+				if _, exists := parents[provider]; !exists {
+					parents[provider] = pkg
+					queue = append(queue, provider)
+				}
+			}
+		}
+	}
+	return parents
+}
+
+// dependencyChain returns the names of the packages from a required package to
+// the given package
+func dependencyChain(parents map[*api.Package]*api.Package, pkg *api.Package) []string {
+	chain := []string{pkg.Name}
+	for {
+		parent, exists := parents[pkg]
+		if !exists {
+			// the package was pulled in by something which isn't part of the install set
+			return append([]string{"?"}, chain...)
+		}
+		if parent == pkg {
+			return chain
+		}
+		chain = append([]string{parent.Name}, chain...)
+		pkg = parent
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/license"
+)
+
+func TestLicenseViolations(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newLicensedPackage := func(name, expression string, deps ...string) *api.Package {
+		pkg := newPackageWithDeps(name, deps...)
+		pkg.Format.License = expression
+		pkg.Arch = "x86_64"
+		pkg.Version = api.Version{Ver: "1.0", Rel: "1.fc44"}
+		return pkg
+	}
+	app := newLicensedPackage("app", "MIT", "libfoo", "glibc")
+	libfoo := newLicensedPackage("libfoo", "MIT", "libbar")
+	libbar := newLicensedPackage("libbar", "AGPL-3.0-only")
+	glibc := newLicensedPackage("glibc", "LGPL-2.1-or-later")
+	tool := newLicensedPackage("tool", "GPL-3.0-only OR MIT")
+	editor := newLicensedPackage("editor", "GPL-3.0-only")
+	install := []*api.Package{app, libfoo, libbar, glibc, tool, editor}
+
+	policy, err := license.NewPolicy(nil, []string{"GPL-3.0-only", "AGPL-*"}, false)
+	g.Expect(err).Should(BeNil())
+
+	violations := licenseViolations(policy, install, nil, []string{"app", "tool", "editor"})
+	g.Expect(violations).Should(HaveLen(2))
+	g.Expect(violations[0].String()).Should(Equal("editor-0:1.0-1.fc44.x86_64 (GPL-3.0-only): GPL-3.0-only is denied, requested directly"))
+	g.Expect(violations[1].String()).Should(Equal("libbar-0:1.0-1.fc44.x86_64 (AGPL-3.0-only): AGPL-3.0-only is denied, pulled in by app -> libfoo -> libbar"))
+
+	// packages of the base are not checked
+	violations = licenseViolations(policy, install, []*api.Package{editor, libbar}, []string{"app", "tool", "editor"})
+	g.Expect(violations).Should(BeEmpty())
+}
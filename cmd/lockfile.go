@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/bazel"
 	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/sirupsen/logrus"
@@ -13,6 +16,8 @@ type lockfileOpts struct {
 	repofiles  []string
 	configname string
 	lockfile   string
+	arches     []string
+	schema     int
 }
 
 var lockfileopts = lockfileOpts{}
@@ -25,21 +30,15 @@ func NewLockFileCmd() *cobra.Command {
 		Long:  `Keep the bazeldnf lock file up to date using a set of dependencies`,
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, required []string) error {
-			repos, err := repo.LoadRepoFiles(lockfileopts.repofiles)
-			if err != nil {
+			if err := checkTargetArchFlags(cmd); err != nil {
 				return err
 			}
-
-			install, forceIgnored, err := resolve(repos, required)
+			repos, err := repo.LoadRepoFilesWithFilters(lockfileopts.repofiles)
 			if err != nil {
 				return err
 			}
 
-			logrus.Debugf("install: %v", install)
-			logrus.Debugf("forceIgnored: %v", forceIgnored)
-
-			config, err := toConfig(install, forceIgnored, required, os.Args[2:])
-
+			config, err := resolveLockFile(repos, required, os.Args[2:])
 			if err != nil {
 				return err
 			}
@@ -49,10 +48,62 @@ func NewLockFileCmd() *cobra.Command {
 		},
 	}
 
-	addResolveHelperFlags(lockfileCmd)
-	repo.AddCacheHelperFlags(lockfileCmd)
-	lockfileCmd.Flags().StringArrayVarP(&lockfileopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
-	lockfileCmd.Flags().StringVar(&lockfileopts.configname, "configname", "rpms", "config name to use in lockfile")
-	lockfileCmd.Flags().StringVar(&lockfileopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to write to")
+	addLockFileFlags(lockfileCmd)
+	lockfileCmd.AddCommand(NewLockFileUpdateCmd())
+	lockfileCmd.AddCommand(NewLockFileRemoveCmd())
+	lockfileCmd.AddCommand(NewLockFileRegenerateCmd())
+	lockfileCmd.AddCommand(NewLockFileVerifyCmd())
+	lockfileCmd.AddCommand(NewLockFilePreviewCmd())
 	return lockfileCmd
 }
+
+// addLockFileFlags adds all flags which influence the content of the lock file
+func addLockFileFlags(cmd *cobra.Command) {
+	addResolveHelperFlags(cmd)
+	repo.AddCacheHelperFlags(cmd)
+	repo.AddRepoFilterFlags(cmd)
+	cmd.Flags().StringArrayVarP(&lockfileopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
+	cmd.Flags().StringVar(&lockfileopts.configname, "configname", "rpms", "config name to use in lockfile")
+	cmd.Flags().StringVar(&lockfileopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to write to")
+	cmd.Flags().StringSliceVar(&lockfileopts.arches, "target-arch", []string{}, "resolve the packages separately for each of these architectures and write one lock file with per architecture package lists")
+	cmd.Flags().IntVar(&lockfileopts.schema, "schema-version", bazeldnf.SchemaVersion1, fmt.Sprintf("lock file schema version to write, version %d adds the package metadata like version, license and sizes", bazeldnf.SchemaVersion2))
+}
+
+// resolveLockFile resolves the required packages and creates the lock file
+// config, for each target architecture if several are requested
+func resolveLockFile(repos *bazeldnf.Repositories, required []string, cmdline []string) (*bazeldnf.Config, error) {
+	if lockfileopts.schema < bazeldnf.SchemaVersion1 || lockfileopts.schema > bazeldnf.LatestSchemaVersion {
+		return nil, fmt.Errorf("unsupported lock file schema version %d, supported are %d to %d", lockfileopts.schema, bazeldnf.SchemaVersion1, bazeldnf.LatestSchemaVersion)
+	}
+	var config *bazeldnf.Config
+	if len(lockfileopts.arches) > 0 {
+		configs := map[string]*bazeldnf.Config{}
+		err := resolveArches(repos, required, lockfileopts.arches, func(arch string, install, forceIgnored, base []*api.Package) error {
+			config, err := toConfig(install, forceIgnored, base, required, cmdline, lockfileopts.schema)
+			if err != nil {
+				return err
+			}
+			configs[arch] = config
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = mergeArchConfigs(lockfileopts.arches, configs)
+	} else {
+		install, forceIgnored, base, err := resolve(repos, required)
+		if err != nil {
+			return nil, err
+		}
+
+		logrus.Debugf("install: %v", install)
+		logrus.Debugf("forceIgnored: %v", forceIgnored)
+
+		config, err = toConfig(install, forceIgnored, base, required, cmdline, lockfileopts.schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.FromRepo = repos.FromRepo
+	return config, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockdiff"
+	"github.com/rmohr/bazeldnf/pkg/reducer"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/spf13/cobra"
+)
+
+type lockfilePreviewOpts struct {
+	lockfile  string
+	repofiles []string
+	output    string
+}
+
+var lockfilepreviewopts = lockfilePreviewOpts{}
+
+func NewLockFilePreviewCmd() *cobra.Command {
+
+	previewCmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Preview resolving a lock file against other repositories",
+		Long: `Resolve the targets of a lock file with the arguments it was created with,
+but against other repositories, like the ones of the next distribution release.
+Shows which packages would change or disappear, which requested packages are
+not available anymore and whether the resolution fails. The lock file is not
+changed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lockfilepreviewopts.output != "text" && lockfilepreviewopts.output != "markdown" {
+				return fmt.Errorf("unsupported output format %s, supported are text and markdown", lockfilepreviewopts.output)
+			}
+			locked, err := bazel.LoadLockFile(lockfilepreviewopts.lockfile)
+			if err != nil {
+				return err
+			}
+			required, err := replayLockFileArguments(locked)
+			if err != nil {
+				return fmt.Errorf("failed to read the arguments of lock file %s: %v", lockfilepreviewopts.lockfile, err)
+			}
+			lockfileopts.repofiles = lockfilepreviewopts.repofiles
+			repos, err := repo.LoadRepoFilesWithFilters(lockfileopts.repofiles)
+			if err != nil {
+				return err
+			}
+
+			missing, changes, resolveErr, err := previewLockFile(locked, repos, required)
+			if err != nil {
+				return err
+			}
+
+			markdown := lockfilepreviewopts.output == "markdown"
+			if len(missing) > 0 {
+				if markdown {
+					fmt.Printf("### Requested packages which are not available (%d)\n\n", len(missing))
+					for _, name := range missing {
+						fmt.Printf("- %s\n", name)
+					}
+				} else {
+					fmt.Printf("Requested packages which are not available:\n  %s\n", strings.Join(missing, "\n  "))
+				}
+				fmt.Println()
+			}
+			if resolveErr != nil {
+				if markdown {
+					fmt.Printf("### Resolution failed\n\n```\n%v\n```\n", resolveErr)
+				} else {
+					fmt.Printf("Resolution failed:\n%v\n", resolveErr)
+				}
+				cmd.SilenceUsage = true
+				return fmt.Errorf("the targets of lock file %s can't be resolved with the repositories", lockfilepreviewopts.lockfile)
+			}
+			if markdown {
+				return lockdiff.WriteMarkdown(os.Stdout, changes)
+			}
+			return lockdiff.WriteText(os.Stdout, changes)
+		},
+	}
+
+	previewCmd.Flags().StringVar(&lockfilepreviewopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to preview")
+	previewCmd.Flags().StringArrayVarP(&lockfilepreviewopts.repofiles, "repofile", "r", nil, "repository information file to resolve against. Can be specified multiple times")
+	previewCmd.Flags().StringVarP(&lockfilepreviewopts.output, "output", "o", "text", "output format, text or markdown")
+	previewCmd.MarkFlagRequired("repofile")
+	return previewCmd
+}
+
+// previewLockFile resolves the targets of the lock file against the
+// repositories with the arguments of the lock file. Targets which no package
+// of the repositories matches are returned as missing and left out, the other
+// targets are resolved to show the impact on them. The error of the
+// resolution is returned separately from errors which prevent the preview.
+func previewLockFile(locked *bazeldnf.Config, repos *bazeldnf.Repositories, required []string) (missing []string, changes []*lockdiff.Change, resolveErr error, err error) {
+	arches := lockfileopts.arches
+	if len(arches) == 0 {
+		arches = resolvehelperopts.arch
+	}
+	primaries, err := repo.NewCacheHelper().CurrentPrimaries(repos, EffectiveArchitectures(arches))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	available := []*api.Package{}
+	for _, primary := range primaries {
+		for i := range primary.Repo.Packages {
+			available = append(available, &primary.Repo.Packages[i])
+		}
+	}
+	missing = missingTargets(required, available)
+	remaining := []string{}
+	for _, target := range required {
+		if !slices.Contains(missing, target) {
+			remaining = append(remaining, target)
+		}
+	}
+	if len(remaining) == 0 {
+		return missing, nil, fmt.Errorf("none of the targets is available"), nil
+	}
+
+	preview, resolveErr := resolveLockFile(repos, remaining, locked.CommandLineArguments)
+	if resolveErr != nil {
+		return missing, nil, resolveErr, nil
+	}
+	changes, err = lockdiff.Compare(locked, preview)
+	return missing, changes, nil, err
+}
+
+// missingTargets returns the targets which no available package matches, like
+// the resolver matches them
+func missingTargets(targets []string, available []*api.Package) []string {
+	missing := []string{}
+	for _, target := range targets {
+		if !slices.ContainsFunc(available, func(pkg *api.Package) bool {
+			return reducer.PackageMatchesString(pkg, target)
+		}) {
+			missing = append(missing, target)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockdiff"
+)
+
+func TestMissingTargets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bash := newPackageWithProvides("bash", "/bin/sh")
+	bash.Arch = "x86_64"
+	bash.Version = api.Version{Epoch: "0", Ver: "5.2.37", Rel: "1.fc44"}
+	available := []*api.Package{bash, newPackageWithFiles("coreutils", "/usr/bin/ls")}
+
+	// targets are matched like the resolver matches them, not by provides
+	g.Expect(missingTargets([]string{"bash", "bash-0:5.2", "bash.x86_64", "coreutils", "/bin/sh", "/usr/bin/ls", "python2"}, available)).Should(Equal([]string{"/bin/sh", "/usr/bin/ls", "python2"}))
+}
+
+// writeCachedPrimary writes the packages as the cached primary.xml of the
+// repository, like 'bazeldnf fetch' does
+func writeCachedPrimary(t *testing.T, cacheDir string, repository string, pkgs []api.Package) {
+	g := NewGomegaWithT(t)
+
+	dir := filepath.Join(cacheDir, repository)
+	g.Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+	repomd := &api.Repomd{Data: []api.Data{{Type: api.PrimaryFileType}}}
+	repomd.Data[0].Location.Href = "repodata/primary.xml.gz"
+	content, err := xml.Marshal(repomd)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.WriteFile(filepath.Join(dir, "repomd.xml"), content, 0644)).To(Succeed())
+
+	f, err := os.Create(filepath.Join(dir, "primary.xml.gz"))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	w := gzip.NewWriter(f)
+	g.Expect(xml.NewEncoder(w).Encode(&api.Repository{Packages: pkgs})).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+}
+
+func TestPreviewLockFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	restoreLockFileOptions(t)
+
+	newRepoPackage := func(name, version string, requires ...string) api.Package {
+		pkg := newPackageWithDeps(name, requires...)
+		pkg.Arch = "x86_64"
+		pkg.Version = api.Version{Epoch: "0", Ver: version, Rel: "1.fc45"}
+		pkg.Checksum = api.Checksum{Type: "sha256", Text: "aabd099d"}
+		pkg.Location.Href = "Packages/" + name + "-" + version + "-1.fc45.x86_64.rpm"
+		pkg.Repository = nil
+		return *pkg
+	}
+	cacheDir := t.TempDir()
+	writeCachedPrimary(t, cacheDir, "fedora", []api.Package{
+		newRepoPackage("bash", "5.3.0", "glibc"),
+		newRepoPackage("glibc", "2.43"),
+	})
+	repos := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{{Name: "fedora", Mirrors: []string{"https://example.com/fedora/45/"}}}}
+
+	locked := &bazeldnf.Config{
+		Name:         "rpms",
+		Repositories: map[string][]string{"fedora": {"https://example.com/fedora/44/"}},
+		RPMs: []*bazeldnf.RPM{
+			{Id: "bash", Name: "bash", Integrity: "sha256-a", URLs: []string{"Packages/bash-5.2.37-1.fc44.x86_64.rpm"}, Repository: "fedora", Dependencies: []string{"glibc"}},
+			{Id: "glibc", Name: "glibc", Integrity: "sha256-b", URLs: []string{"Packages/glibc-2.42-1.fc44.x86_64.rpm"}, Repository: "fedora", Dependencies: []string{}},
+			{Id: "python2", Name: "python2", Integrity: "sha256-c", URLs: []string{"Packages/python2-2.7.18-1.fc44.x86_64.rpm"}, Repository: "fedora", Dependencies: []string{}},
+		},
+		CommandLineArguments: []string{"--cache-dir", cacheDir, "--basesystem", "", "bash-0:5", "glibc.x86_64", "python2"},
+	}
+	required, err := replayLockFileArguments(locked)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	missing, changes, resolveErr, err := previewLockFile(locked, repos, required)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resolveErr).ToNot(HaveOccurred())
+	g.Expect(missing).To(Equal([]string{"python2"}))
+	kinds := map[string]lockdiff.Kind{}
+	for _, change := range changes {
+		kinds[change.Name] = change.Kind
+	}
+	g.Expect(kinds).To(Equal(map[string]lockdiff.Kind{
+		"bash":    lockdiff.Upgraded,
+		"glibc":   lockdiff.Upgraded,
+		"python2": lockdiff.Removed,
+	}))
+
+	// the base system is not available, the missing targets don't hide that
+	locked.CommandLineArguments = []string{"--cache-dir", cacheDir, "bash", "python2"}
+	required, err = replayLockFileArguments(locked)
+	g.Expect(err).ToNot(HaveOccurred())
+	missing, _, resolveErr, err = previewLockFile(locked, repos, required)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(missing).To(Equal([]string{"python2"}))
+	g.Expect(resolveErr).To(MatchError(ContainSubstring("fedora-release-container")))
+}
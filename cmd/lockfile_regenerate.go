@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type lockfileRegenerateOpts struct {
+	refresh bool
+}
+
+var lockfileregenerateopts = lockfileRegenerateOpts{}
+
+func NewLockFileRegenerateCmd() *cobra.Command {
+
+	regenerateCmd := &cobra.Command{
+		Use:   "regenerate <lockfile>...",
+		Short: "Regenerate lock files from their recorded arguments",
+		Long: `Resolve lock files again with the arguments they were created with and
+rewrite them. With --refresh the repository metadata is fetched first, lock
+files which use the same repositories share the fetched metadata.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, lockfiles []string) error {
+			refresher := newRepoRefresher()
+			for _, lockfile := range lockfiles {
+				if err := regenerateLockFile(lockfile, refresher); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	regenerateCmd.Flags().BoolVar(&lockfileregenerateopts.refresh, "refresh", false, "fetch the repository metadata before resolving")
+	return regenerateCmd
+}
+
+// repoRefresher fetches the repository metadata for the lock files of a run,
+// every distinct set of repositories is only fetched once
+type repoRefresher struct {
+	fetched map[string]bool
+	fetch   func(repos []bazeldnf.Repository) error
+}
+
+func newRepoRefresher() *repoRefresher {
+	return &repoRefresher{
+		fetched: map[string]bool{},
+		fetch: func(repos []bazeldnf.Repository) error {
+			return repo.NewRemoteRepoFetcher(repos).Fetch()
+		},
+	}
+}
+
+func (r *repoRefresher) refresh(repos *bazeldnf.Repositories) error {
+	key, err := json.Marshal(repos.Repositories)
+	if err != nil {
+		return err
+	}
+	if r.fetched[string(key)] {
+		logrus.Info("Repositories were already fetched.")
+		return nil
+	}
+	if err := r.fetch(repos.Repositories); err != nil {
+		return err
+	}
+	r.fetched[string(key)] = true
+	return nil
+}
+
+func regenerateLockFile(lockfile string, refresher *repoRefresher) error {
+	logrus.Infof("Regenerating %s.", lockfile)
+	locked, err := bazel.LoadLockFile(lockfile)
+	if err != nil {
+		return err
+	}
+	required, err := replayLockFileArguments(locked)
+	if err != nil {
+		return fmt.Errorf("failed to read the arguments of lock file %s: %v", lockfile, err)
+	}
+	repos, err := repo.LoadRepoFilesWithFilters(lockfileopts.repofiles)
+	if err != nil {
+		return err
+	}
+	if lockfileregenerateopts.refresh {
+		if err := refresher.refresh(repos); err != nil {
+			return err
+		}
+	}
+	config, err := resolveLockFile(repos, required, locked.CommandLineArguments)
+	if err != nil {
+		return fmt.Errorf("failed to regenerate lock file %s: %v", lockfile, err)
+	}
+
+	for _, change := range lockFileChanges(locked, config) {
+		fmt.Println(change.String())
+	}
+
+	logrus.Info("Writing lockfile.")
+	return bazel.WriteLockFile(config, lockfile)
+}
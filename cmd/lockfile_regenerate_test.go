@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestReplayLockFileArgumentsOfSeveralLockFiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+	restoreLockFileOptions(t)
+
+	required, err := replayLockFileArguments(&bazeldnf.Config{
+		CommandLineArguments: []string{
+			"--target-arch", "x86_64,aarch64",
+			"--force-ignore-with-dependencies", "^kernel",
+			"--only-allow", "^(bash|glibc)",
+			"--schema-version", "2",
+			"bash",
+		},
+	})
+	g.Expect(err).Should(BeNil())
+	g.Expect(required).Should(Equal([]string{"bash"}))
+	g.Expect(lockfileopts.arches).Should(Equal([]string{"x86_64", "aarch64"}))
+	g.Expect(lockfileopts.schema).Should(Equal(bazeldnf.SchemaVersion2))
+	g.Expect(resolvehelperopts.forceIgnoreRegex).Should(Equal([]string{"^kernel"}))
+	g.Expect(resolvehelperopts.onlyAllowRegex).Should(Equal([]string{"^(bash|glibc)"}))
+
+	// the next lock file of the same run doesn't inherit the options
+	resolvehelperopts.locked = &bazeldnf.Config{}
+	required, err = replayLockFileArguments(&bazeldnf.Config{
+		CommandLineArguments: []string{"--lockfile", "coreutils.json", "coreutils"},
+	})
+	g.Expect(err).Should(BeNil())
+	g.Expect(required).Should(Equal([]string{"coreutils"}))
+	g.Expect(lockfileopts.lockfile).Should(Equal("coreutils.json"))
+	g.Expect(lockfileopts.arches).Should(BeEmpty())
+	g.Expect(lockfileopts.schema).Should(Equal(bazeldnf.SchemaVersion1))
+	g.Expect(resolvehelperopts.arch).Should(Equal([]string{"x86_64"}))
+	g.Expect(resolvehelperopts.forceIgnoreRegex).Should(BeEmpty())
+	g.Expect(resolvehelperopts.onlyAllowRegex).Should(BeEmpty())
+	g.Expect(resolvehelperopts.locked).Should(BeNil())
+
+	_, err = replayLockFileArguments(&bazeldnf.Config{
+		CommandLineArguments: []string{"--lockfile", "empty.json"},
+	})
+	g.Expect(err).Should(HaveOccurred())
+	_, err = replayLockFileArguments(&bazeldnf.Config{
+		CommandLineArguments: []string{"--arch", "aarch64", "--target-arch", "x86_64", "bash"},
+	})
+	g.Expect(err).Should(HaveOccurred())
+}
+
+func TestRepoRefresherFetchesRepositoriesOnce(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fetched := [][]bazeldnf.Repository{}
+	refresher := newRepoRefresher()
+	refresher.fetch = func(repos []bazeldnf.Repository) error {
+		fetched = append(fetched, repos)
+		return nil
+	}
+	fedora := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{{Name: "fedora", Baseurl: "https://example.com/fedora/44/"}}}
+	updates := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{
+		{Name: "fedora", Baseurl: "https://example.com/fedora/44/"},
+		{Name: "updates", Baseurl: "https://example.com/updates/44/"},
+	}}
+
+	for _, repos := range []*bazeldnf.Repositories{fedora, updates, fedora, updates} {
+		g.Expect(refresher.refresh(repos)).To(Succeed())
+	}
+	g.Expect(fetched).Should(Equal([][]bazeldnf.Repository{fedora.Repositories, updates.Repositories}))
+
+	// failed fetches are retried by the next lock file
+	failing := newRepoRefresher()
+	failing.fetch = func(repos []bazeldnf.Repository) error {
+		return fmt.Errorf("unreachable")
+	}
+	g.Expect(failing.refresh(fedora)).ShouldNot(Succeed())
+	g.Expect(failing.fetched).Should(BeEmpty())
+}
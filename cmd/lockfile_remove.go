@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type lockfileRemoveOpts struct {
+	lockfile string
+}
+
+var lockfileremoveopts = lockfileRemoveOpts{}
+
+func NewLockFileRemoveCmd() *cobra.Command {
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <package>...",
+		Short: "Remove requested packages from a lock file",
+		Long: `Remove the given packages from the targets of a lock file. Locked packages
+which are not required by the remaining targets anymore are removed as well.
+All other packages keep their locked versions.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, packages []string) error {
+			locked, err := bazel.LoadLockFile(lockfileremoveopts.lockfile)
+			if err != nil {
+				return err
+			}
+			config, err := removeTargets(locked, packages)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range packages {
+				if slices.ContainsFunc(lockedRPMs(config), func(rpm *bazeldnf.RPM) bool { return rpm.Name == name }) {
+					logrus.Infof("%s is still required by the remaining targets.", name)
+				}
+			}
+			changes := lockFileChanges(locked, config)
+			if len(changes) == 0 {
+				fmt.Println("No packages removed.")
+			}
+			for _, change := range changes {
+				fmt.Println(change.String())
+			}
+
+			logrus.Info("Writing lockfile.")
+			return bazel.WriteLockFile(config, lockfileremoveopts.lockfile)
+		},
+	}
+
+	removeCmd.Flags().StringVar(&lockfileremoveopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to remove the packages from")
+	return removeCmd
+}
+
+// removeTargets removes the packages from the targets of the lock file and
+// prunes all locked RPMs which are not reachable from the remaining targets
+// through the recorded dependencies
+func removeTargets(config *bazeldnf.Config, names []string) (*bazeldnf.Config, error) {
+	for _, name := range names {
+		if !slices.Contains(config.Targets, name) {
+			return nil, fmt.Errorf("%s is not a target of the lock file", name)
+		}
+	}
+	targets := []string{}
+	for _, target := range config.Targets {
+		if !slices.Contains(names, target) {
+			targets = append(targets, target)
+		}
+	}
+	lockedNames := map[string]bool{}
+	for _, rpm := range lockedRPMs(config) {
+		lockedNames[rpm.Name] = true
+	}
+	for _, target := range targets {
+		if !lockedNames[target] {
+			return nil, fmt.Errorf("target %s is not the name of a locked package, the packages it requires can't be determined", target)
+		}
+	}
+
+	pruned := *config
+	pruned.Targets = targets
+	arguments, err := removeArguments(config.CommandLineArguments, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove the packages from the recorded arguments: %v", err)
+	}
+	pruned.CommandLineArguments = arguments
+
+	if len(config.Arches) == 0 {
+		keep := reachableRPMs(config.RPMs, targets)
+		pruned.RPMs = filterRPMs(config.RPMs, keep)
+	} else {
+		pruned.Arches = map[string]*bazeldnf.ArchConfig{}
+		usedNoarch := map[string]bool{}
+		for arch, archConfig := range config.Arches {
+			rpms, err := config.ArchRPMs(arch)
+			if err != nil {
+				return nil, err
+			}
+			keep := reachableRPMs(rpms, targets)
+			prunedArch := *archConfig
+			prunedArch.RPMs = filterRPMs(archConfig.RPMs, keep)
+			if archConfig.NoarchDependencies != nil {
+				prunedArch.NoarchDependencies = map[string][]string{}
+				for id, dependencies := range archConfig.NoarchDependencies {
+					if keep[id] {
+						prunedArch.NoarchDependencies[id] = dependencies
+						usedNoarch[id] = true
+					}
+				}
+			}
+			pruned.Arches[arch] = &prunedArch
+		}
+		pruned.Noarch = filterRPMs(config.Noarch, usedNoarch)
+	}
+
+	pruned.Repositories = map[string][]string{}
+	for _, rpm := range lockedRPMs(&pruned) {
+		if mirrors, exists := config.Repositories[rpm.Repository]; exists {
+			pruned.Repositories[rpm.Repository] = mirrors
+		}
+	}
+	return &pruned, nil
+}
+
+// reachableRPMs returns the ids of the RPMs which are required by the targets,
+// directly or through the dependencies of other RPMs
+func reachableRPMs(rpms []*bazeldnf.RPM, targets []string) map[string]bool {
+	byId := map[string]*bazeldnf.RPM{}
+	for _, rpm := range rpms {
+		byId[rpm.Id] = rpm
+	}
+	queue := []string{}
+	for _, rpm := range rpms {
+		if slices.Contains(targets, rpm.Name) {
+			queue = append(queue, rpm.Id)
+		}
+	}
+	reachable := map[string]bool{}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		rpm, exists := byId[id]
+		if !exists || reachable[id] {
+			continue
+		}
+		reachable[id] = true
+		queue = append(queue, rpm.Dependencies...)
+	}
+	return reachable
+}
+
+func filterRPMs(rpms []*bazeldnf.RPM, keep map[string]bool) []*bazeldnf.RPM {
+	filtered := []*bazeldnf.RPM{}
+	for _, rpm := range rpms {
+		if keep[rpm.Id] {
+			filtered = append(filtered, rpm)
+		}
+	}
+	return filtered
+}
+
+// removeArguments removes the packages from the positional arguments of
+// recorded lock file arguments. Flags and their values are kept.
+func removeArguments(arguments []string, names []string) ([]string, error) {
+	cmd := &cobra.Command{}
+	addLockFileFlags(cmd)
+	flags := cmd.Flags()
+
+	kept := []string{}
+	positional := false
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		switch {
+		case positional || arg == "-" || !strings.HasPrefix(arg, "-"):
+			if !slices.Contains(names, arg) {
+				kept = append(kept, arg)
+			}
+			continue
+		case arg == "--":
+			positional = true
+		case strings.HasPrefix(arg, "--"):
+			name, _, hasValue := strings.Cut(arg[2:], "=")
+			flag := flags.Lookup(name)
+			if flag == nil {
+				return nil, fmt.Errorf("unknown flag %s", arg)
+			}
+			if !hasValue && flag.NoOptDefVal == "" && i+1 < len(arguments) {
+				kept = append(kept, arg)
+				i++
+				arg = arguments[i]
+			}
+		default:
+			// shorthand flags can be combined, the first one taking a value ends the group
+			for j := 1; j < len(arg); j++ {
+				flag := flags.ShorthandLookup(arg[j : j+1])
+				if flag == nil {
+					return nil, fmt.Errorf("unknown shorthand flag %s", arg)
+				}
+				if flag.NoOptDefVal != "" {
+					continue
+				}
+				if j == len(arg)-1 && i+1 < len(arguments) {
+					kept = append(kept, arg)
+					i++
+					arg = arguments[i]
+				}
+				break
+			}
+		}
+		kept = append(kept, arg)
+	}
+	return kept, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestRemoveTargets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := &bazeldnf.Config{
+		CommandLineArguments: []string{"-r", "repo.yaml", "bash", "--nobest", "openssl"},
+		Repositories:         map[string][]string{"repository": {"https://example.com"}},
+		RPMs: []*bazeldnf.RPM{
+			newSimpleRPM("bash", "glibc"),
+			newSimpleRPM("glibc"),
+			newSimpleRPM("openssl", "glibc", "openssl-libs"),
+			newSimpleRPM("openssl-libs", "glibc"),
+		},
+		Targets: []string{"bash", "openssl"},
+	}
+
+	pruned, err := removeTargets(config, []string{"openssl"})
+	g.Expect(err).Should(BeNil())
+	g.Expect(pruned.Targets).Should(Equal([]string{"bash"}))
+	g.Expect(pruned.CommandLineArguments).Should(Equal([]string{"-r", "repo.yaml", "bash", "--nobest"}))
+	g.Expect(pruned.RPMs).Should(Equal([]*bazeldnf.RPM{config.RPMs[0], config.RPMs[1]}))
+	g.Expect(pruned.Repositories).Should(Equal(config.Repositories))
+	// the original config is not changed
+	g.Expect(config.RPMs).Should(HaveLen(4))
+
+	_, err = removeTargets(config, []string{"glibc"})
+	g.Expect(err).Should(MatchError("glibc is not a target of the lock file"))
+}
+
+func TestRemoveTargetsMultiArch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := &bazeldnf.Config{
+		Targets: []string{"bash", "tzdata"},
+		Noarch:  []*bazeldnf.RPM{newSimpleRPM("tzdata"), newSimpleRPM("filesystem")},
+		Arches: map[string]*bazeldnf.ArchConfig{
+			"x86_64": {
+				RPMs:               []*bazeldnf.RPM{newSimpleRPM("bash", "filesystem")},
+				NoarchDependencies: map[string][]string{"tzdata": {}, "filesystem": {}},
+			},
+			"aarch64": {
+				RPMs:               []*bazeldnf.RPM{newSimpleRPM("bash")},
+				NoarchDependencies: map[string][]string{"tzdata": {}},
+			},
+		},
+	}
+
+	pruned, err := removeTargets(config, []string{"tzdata"})
+	g.Expect(err).Should(BeNil())
+	g.Expect(pruned.Noarch).Should(Equal([]*bazeldnf.RPM{config.Noarch[1]}))
+	g.Expect(pruned.Arches["x86_64"].NoarchDependencies).Should(Equal(map[string][]string{"filesystem": {}}))
+	g.Expect(pruned.Arches["aarch64"].NoarchDependencies).Should(BeEmpty())
+	g.Expect(pruned.Arches["aarch64"].RPMs).Should(HaveLen(1))
+}
+
+func TestRemoveArguments(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	arguments, err := removeArguments([]string{"-nr", "bash", "--lockfile=bash", "bash", "-a", "x86_64", "--only-allow", "bash", "-ibash", "--", "bash", "glibc"}, []string{"bash"})
+	g.Expect(err).Should(BeNil())
+	g.Expect(arguments).Should(Equal([]string{"-nr", "bash", "--lockfile=bash", "-a", "x86_64", "--only-allow", "bash", "-ibash", "--", "glibc"}))
+
+	_, err = removeArguments([]string{"--unknown", "bash"}, []string{"bash"})
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type lockfileUpdateOpts struct {
+	lockfile string
+}
+
+var lockfileupdateopts = lockfileUpdateOpts{}
+
+func NewLockFileUpdateCmd() *cobra.Command {
+
+	updateCmd := &cobra.Command{
+		Use:   "update <package>...",
+		Short: "Update packages of a lock file",
+		Long: `Update the given packages of a lock file to the newest allowed versions.
+All other packages keep their locked versions. If the packages can't be
+updated on their own, their dependencies are updated as well. The lock file is
+resolved with the arguments it was created with.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, packages []string) error {
+			locked, err := bazel.LoadLockFile(lockfileupdateopts.lockfile)
+			if err != nil {
+				return err
+			}
+			lockedNames := map[string]bool{}
+			for _, rpm := range lockedRPMs(locked) {
+				lockedNames[rpm.Name] = true
+			}
+			for _, name := range packages {
+				if !lockedNames[name] {
+					return fmt.Errorf("package %s is not part of the lock file %s", name, lockfileupdateopts.lockfile)
+				}
+			}
+
+			required, err := replayLockFileArguments(locked)
+			if err != nil {
+				return fmt.Errorf("failed to read the arguments of lock file %s: %v", lockfileupdateopts.lockfile, err)
+			}
+			repos, err := repo.LoadRepoFilesWithFilters(lockfileopts.repofiles)
+			if err != nil {
+				return err
+			}
+
+			resolvehelperopts.locked = locked
+			resolvehelperopts.unlocked = map[string]bool{}
+			for _, name := range packages {
+				resolvehelperopts.unlocked[name] = true
+			}
+			config, err := resolveLockFile(repos, required, locked.CommandLineArguments)
+			if err != nil {
+				dependencies := lockedDependencies(locked, packages)
+				if len(dependencies) == len(packages) {
+					return err
+				}
+				logrus.Infof("The packages can't be updated on their own, updating their dependencies as well: %v", err)
+				for _, name := range dependencies {
+					resolvehelperopts.unlocked[name] = true
+				}
+				config, err = resolveLockFile(repos, required, locked.CommandLineArguments)
+				if err != nil {
+					return err
+				}
+			}
+
+			changes := lockFileChanges(locked, config)
+			if len(changes) == 0 {
+				fmt.Println("No package versions changed.")
+			}
+			for _, change := range changes {
+				fmt.Println(change.String())
+			}
+
+			logrus.Info("Writing lockfile.")
+			return bazel.WriteLockFile(config, lockfileupdateopts.lockfile)
+		},
+	}
+
+	updateCmd.Flags().StringVar(&lockfileupdateopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to update")
+	return updateCmd
+}
+
+// replayLockFileArguments parses the arguments the lock file was created with
+// into the lock file options and returns the required packages. Options which
+// are not in the arguments get their defaults, so nothing is inherited from a
+// lock file replayed before.
+func replayLockFileArguments(config *bazeldnf.Config) ([]string, error) {
+	if len(config.CommandLineArguments) == 0 {
+		return nil, fmt.Errorf("the lock file does not record the arguments it was created with")
+	}
+	lockfileopts = lockfileOpts{}
+	resolvehelperopts = resolveHelperOpts{}
+	cmd := &cobra.Command{}
+	addLockFileFlags(cmd)
+	if err := cmd.ParseFlags(config.CommandLineArguments); err != nil {
+		return nil, err
+	}
+	if err := checkTargetArchFlags(cmd); err != nil {
+		return nil, err
+	}
+	required := cmd.Flags().Args()
+	if len(required) == 0 {
+		return nil, fmt.Errorf("the recorded arguments contain no packages")
+	}
+	return required, nil
+}
+
+// lockedRPMs returns the RPMs of all architectures of the lock file. Shared
+// noarch RPMs of multi architecture lock files are returned once for each
+// architecture, with the dependencies of that architecture.
+func lockedRPMs(config *bazeldnf.Config) []*bazeldnf.RPM {
+	if len(config.Arches) == 0 {
+		return config.RPMs
+	}
+	rpms := []*bazeldnf.RPM{}
+	for _, arch := range sortedKeys(config.Arches) {
+		archRPMs, _ := config.ArchRPMs(arch)
+		rpms = append(rpms, archRPMs...)
+	}
+	return rpms
+}
+
+// lockedDependencies returns the names of the given packages and of all
+// packages they depend on in the lock file
+func lockedDependencies(config *bazeldnf.Config, names []string) []string {
+	dependencies := map[string][]string{}
+	for _, rpm := range lockedRPMs(config) {
+		dependencies[rpm.Id] = append(dependencies[rpm.Id], rpm.Dependencies...)
+	}
+	seen := map[string]bool{}
+	queue := slices.Clone(names)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		queue = append(queue, dependencies[name]...)
+	}
+	return sortedKeys(seen)
+}
+
+// packageChange describes how the locked versions of a package changed. Old
+// or New are empty if the package was added or removed.
+type packageChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+func (c packageChange) String() string {
+	before, after := c.Old, c.New
+	if before == "" {
+		before = "(none)"
+	}
+	if after == "" {
+		after = "(removed)"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Name, before, after)
+}
+
+// lockFileChanges compares the package versions of two lock files
+func lockFileChanges(before, after *bazeldnf.Config) []packageChange {
+	oldVersions := lockedVersions(before)
+	newVersions := lockedVersions(after)
+	names := map[string]bool{}
+	for name := range oldVersions {
+		names[name] = true
+	}
+	for name := range newVersions {
+		names[name] = true
+	}
+	changes := []packageChange{}
+	for _, name := range sortedKeys(names) {
+		if oldVersions[name] != newVersions[name] {
+			changes = append(changes, packageChange{Name: name, Old: oldVersions[name], New: newVersions[name]})
+		}
+	}
+	return changes
+}
+
+// lockedVersions returns the versions of all locked packages by name. Packages
+// locked in several versions, like for different architectures, have their
+// versions joined by commas.
+func lockedVersions(config *bazeldnf.Config) map[string]string {
+	versions := map[string][]string{}
+	for _, locked := range lockedRPMs(config) {
+		key, err := rpm.LockedPackage(locked)
+		if err != nil {
+			logrus.Warnf("Unable to determine the version of %s: %v", locked.Name, err)
+			continue
+		}
+		version := rpm.EVR(key.Version)
+		if !slices.Contains(versions[locked.Name], version) {
+			versions[locked.Name] = append(versions[locked.Name], version)
+		}
+	}
+	joined := map[string]string{}
+	for name, list := range versions {
+		slices.Sort(list)
+		joined[name] = strings.Join(list, ", ")
+	}
+	return joined
+}
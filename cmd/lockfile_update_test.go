@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestLockedDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := &bazeldnf.Config{
+		RPMs: []*bazeldnf.RPM{
+			newSimpleRPM("bash", "glibc", "ncurses-libs"),
+			newSimpleRPM("glibc", "glibc-common"),
+			newSimpleRPM("glibc-common", "glibc"),
+			newSimpleRPM("ncurses-libs", "glibc"),
+			newSimpleRPM("openssl"),
+		},
+	}
+
+	g.Expect(lockedDependencies(config, []string{"ncurses-libs"})).Should(Equal([]string{"glibc", "glibc-common", "ncurses-libs"}))
+	g.Expect(lockedDependencies(config, []string{"openssl"})).Should(Equal([]string{"openssl"}))
+}
+
+func TestLockFileChanges(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	rpm := func(name, file string) *bazeldnf.RPM {
+		return &bazeldnf.RPM{Id: name, Name: name, URLs: []string{"https://example.com/Packages/" + file}}
+	}
+	before := &bazeldnf.Config{RPMs: []*bazeldnf.RPM{
+		rpm("bash", "bash-5.2.37-1.fc44.x86_64.rpm"),
+		rpm("glibc", "glibc-2.41-3.fc44.x86_64.rpm"),
+		rpm("ncurses-libs", "ncurses-libs-6.5-5.fc44.x86_64.rpm"),
+	}}
+	after := &bazeldnf.Config{RPMs: []*bazeldnf.RPM{
+		rpm("bash", "bash-5.2.37-1.fc44.x86_64.rpm"),
+		rpm("glibc", "glibc-2.42-1.fc44.x86_64.rpm"),
+		rpm("openssl-libs", "openssl-libs-3.5.0-2.fc44.x86_64.rpm"),
+	}}
+
+	changes := lockFileChanges(before, after)
+	g.Expect(changes).Should(Equal([]packageChange{
+		{Name: "glibc", Old: "2.41-3.fc44", New: "2.42-1.fc44"},
+		{Name: "ncurses-libs", Old: "6.5-5.fc44"},
+		{Name: "openssl-libs", New: "3.5.0-2.fc44"},
+	}))
+	g.Expect(changes[0].String()).Should(Equal("glibc: 2.41-3.fc44 -> 2.42-1.fc44"))
+	g.Expect(changes[1].String()).Should(Equal("ncurses-libs: 6.5-5.fc44 -> (removed)"))
+	g.Expect(changes[2].String()).Should(Equal("openssl-libs: (none) -> 3.5.0-2.fc44"))
+
+	// the versions of lock files with metadata contain the epoch
+	withMetadata := func(epoch, version string) *bazeldnf.Config {
+		libfoo := rpm("libfoo", "libfoo-"+version+"-1.fc44.x86_64.rpm")
+		libfoo.Epoch, libfoo.Version, libfoo.Release, libfoo.Arch = epoch, version, "1.fc44", "x86_64"
+		return &bazeldnf.Config{SchemaVersion: bazeldnf.SchemaVersion2, RPMs: []*bazeldnf.RPM{libfoo}}
+	}
+	changes = lockFileChanges(withMetadata("0", "2.0"), withMetadata("1", "1.0"))
+	g.Expect(changes).Should(Equal([]packageChange{{Name: "libfoo", Old: "2.0-1.fc44", New: "1:1.0-1.fc44"}}))
+}
+
+// restoreLockFileOptions restores the global lock file and resolve options
+// after the test, replaying lock file arguments changes them
+func restoreLockFileOptions(t *testing.T) {
+	lockfile, resolveHelper := lockfileopts, resolvehelperopts
+	t.Cleanup(func() {
+		lockfileopts = lockfile
+		resolvehelperopts = resolveHelper
+	})
+}
+
+func TestReplayLockFileArguments(t *testing.T) {
+	g := NewGomegaWithT(t)
+	restoreLockFileOptions(t)
+
+	required, err := replayLockFileArguments(&bazeldnf.Config{
+		CommandLineArguments: []string{"-r", "repo.yaml", "--lockfile", "rpms.json", "bash", "--nobest", "coreutils"},
+	})
+	g.Expect(err).Should(BeNil())
+	g.Expect(required).Should(Equal([]string{"bash", "coreutils"}))
+	g.Expect(lockfileopts.repofiles).Should(Equal([]string{"repo.yaml"}))
+	g.Expect(lockfileopts.lockfile).Should(Equal("rpms.json"))
+	g.Expect(resolvehelperopts.nobest).Should(BeTrue())
+
+	_, err = replayLockFileArguments(&bazeldnf.Config{})
+	g.Expect(err).Should(HaveOccurred())
+}
+
+func TestLockedConstraints(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bash := newPackage("bash", "aa", "", "fedora", nil)
+	bash.Version = api.Version{Ver: "5.2.37", Rel: "1.fc44"}
+	glibc := newPackage("glibc", "bb", "", "fedora", nil)
+	glibc.Version = api.Version{Epoch: "1", Ver: "2.41", Rel: "3.fc44"}
+	newerGlibc := newPackage("glibc", "cc", "", "fedora", nil)
+	newerGlibc.Version = api.Version{Epoch: "1", Ver: "2.42", Rel: "1.fc44"}
+	integrity := func(pkg *api.Package) string {
+		integrity, err := pkg.Checksum.Integrity()
+		g.Expect(err).Should(BeNil())
+		return integrity
+	}
+	config := &bazeldnf.Config{RPMs: []*bazeldnf.RPM{
+		{Id: "bash", Name: "bash", Integrity: integrity(bash)},
+		{Id: "glibc", Name: "glibc", Integrity: integrity(glibc)},
+	}}
+	involved := []*api.Package{bash, glibc, newerGlibc}
+
+	constraints, err := lockedConstraints(config, map[string]bool{"bash": true}, "x86_64", involved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(constraints).Should(Equal([]bazeldnf.Constraint{
+		{Name: "glibc", Allow: "= 1:2.41-3.fc44", Reason: "locked, update it as well to unlock it"},
+	}))
+
+	// locked packages which are gone can't keep their version
+	_, err = lockedConstraints(config, nil, "x86_64", []*api.Package{bash, newerGlibc})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).Should(ContainSubstring("glibc"))
+}
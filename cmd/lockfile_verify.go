@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type lockfileVerifyOpts struct {
+	lockfile string
+}
+
+var lockfileverifyopts = lockfileVerifyOpts{}
+
+func NewLockFileVerifyCmd() *cobra.Command {
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that a lock file is up to date",
+		Long: `Resolve the lock file again with the arguments it was created with against
+the cached repository metadata. Fails and prints the differences if the
+result doesn't match the lock file. The lock file is not changed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			committed, err := bazel.LoadLockFile(lockfileverifyopts.lockfile)
+			if err != nil {
+				return err
+			}
+			required, err := replayLockFileArguments(committed)
+			if err != nil {
+				return fmt.Errorf("failed to read the arguments of lock file %s: %v", lockfileverifyopts.lockfile, err)
+			}
+			repos, err := repo.LoadRepoFilesWithFilters(lockfileopts.repofiles)
+			if err != nil {
+				return err
+			}
+			resolved, err := resolveLockFile(repos, required, committed.CommandLineArguments)
+			if err != nil {
+				return err
+			}
+
+			drift, err := lockFileDrift(committed, resolved)
+			if err != nil {
+				return err
+			}
+			if len(drift) == 0 {
+				logrus.Infof("Lock file %s is up to date.", lockfileverifyopts.lockfile)
+				return nil
+			}
+			for _, line := range drift {
+				fmt.Println(line)
+			}
+			cmd.SilenceUsage = true
+			return fmt.Errorf("lock file %s is out of date, %d differences found", lockfileverifyopts.lockfile, len(drift))
+		},
+	}
+
+	verifyCmd.Flags().StringVar(&lockfileverifyopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to verify")
+	return verifyCmd
+}
+
+// lockFileDrift describes the differences between a committed lock file and
+// the lock file resolved from the same arguments, one line per difference.
+// The lock file is up to date only if writing the resolved lock file would
+// not change it, the lines only explain what changed.
+func lockFileDrift(committed, resolved *bazeldnf.Config) ([]string, error) {
+	before, err := bazel.MarshalLockFile(committed)
+	if err != nil {
+		return nil, err
+	}
+	after, err := bazel.MarshalLockFile(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(before, after) {
+		return nil, nil
+	}
+
+	drift := []string{}
+	for _, change := range lockFileChanges(committed, resolved) {
+		drift = append(drift, change.String())
+	}
+
+	arches := []string{""}
+	if len(committed.Arches) > 0 || len(resolved.Arches) > 0 {
+		all := map[string]bool{}
+		for arch := range committed.Arches {
+			all[arch] = true
+		}
+		for arch := range resolved.Arches {
+			all[arch] = true
+		}
+		arches = sortedKeys(all)
+	}
+	for _, arch := range arches {
+		prefix := ""
+		if arch != "" {
+			prefix = arch + ": "
+		}
+		before := rpmsById(committed, arch)
+		after := rpmsById(resolved, arch)
+		for _, id := range sortedKeys(before) {
+			if _, exists := after[id]; !exists && arch != "" {
+				drift = append(drift, fmt.Sprintf("%s%s: removed", prefix, id))
+			}
+		}
+		for _, id := range sortedKeys(after) {
+			b, exists := before[id]
+			a := after[id]
+			if !exists {
+				if arch != "" {
+					drift = append(drift, fmt.Sprintf("%s%s: added", prefix, id))
+				}
+				continue
+			}
+			sameFile := rpmFile(b) == rpmFile(a)
+			if sameFile && b.Integrity != a.Integrity {
+				drift = append(drift, fmt.Sprintf("%s%s: integrity changed from %s to %s", prefix, id, b.Integrity, a.Integrity))
+			}
+			if b.Repository != a.Repository {
+				drift = append(drift, fmt.Sprintf("%s%s: repository changed from %s to %s", prefix, id, b.Repository, a.Repository))
+			}
+			if added, removed := diffStrings(b.Dependencies, a.Dependencies); len(added) > 0 || len(removed) > 0 {
+				drift = append(drift, fmt.Sprintf("%s%s: dependencies changed%s", prefix, id, formatDiff(added, removed)))
+			}
+			if sameFile {
+				// a new file is already explained by the version change
+				if fields := changedFields(b, a, "integrity", "repository", "dependencies"); len(fields) > 0 {
+					drift = append(drift, fmt.Sprintf("%s%s: %s changed", prefix, id, strings.Join(fields, ", ")))
+				}
+			}
+		}
+		if arch != "" {
+			b, a := committed.Arches[arch], resolved.Arches[arch]
+			if b != nil && a != nil {
+				if added, removed := diffStrings(b.ForceIgnored, a.ForceIgnored); len(added) > 0 || len(removed) > 0 {
+					drift = append(drift, prefix+"ignored packages changed"+formatDiff(added, removed))
+				}
+				for _, field := range changedFields(b, a, "rpms", "noarch-dependencies", "ignored") {
+					drift = append(drift, prefix+field+" changed")
+				}
+			}
+		}
+	}
+
+	if added, removed := diffStrings(rpmIds(committed.Noarch), rpmIds(resolved.Noarch)); len(added) > 0 || len(removed) > 0 {
+		drift = append(drift, "shared noarch packages changed"+formatDiff(added, removed))
+	}
+	if added, removed := diffStrings(committed.ForceIgnored, resolved.ForceIgnored); len(added) > 0 || len(removed) > 0 {
+		drift = append(drift, "ignored packages changed"+formatDiff(added, removed))
+	}
+	if added, removed := diffStrings(committed.Targets, resolved.Targets); len(added) > 0 || len(removed) > 0 {
+		drift = append(drift, "targets changed"+formatDiff(added, removed))
+	}
+	if added, removed := diffStrings(sortedKeys(committed.Repositories), sortedKeys(resolved.Repositories)); len(added) > 0 || len(removed) > 0 {
+		drift = append(drift, "repositories changed"+formatDiff(added, removed))
+	}
+	for _, name := range sortedKeys(committed.Repositories) {
+		mirrors, exists := resolved.Repositories[name]
+		if exists && !slices.Equal(committed.Repositories[name], mirrors) {
+			drift = append(drift, fmt.Sprintf("mirrors of repository %s changed", name))
+		}
+	}
+	for _, field := range changedFields(committed, resolved, "rpms", "noarch", "arches", "ignored", "targets", "repositories") {
+		drift = append(drift, field+" changed")
+	}
+	if len(drift) == 0 {
+		drift = append(drift, "lock file content changed")
+	}
+	return drift, nil
+}
+
+// changedFields returns the names of the JSON fields which differ between
+// the two values, except the given fields
+func changedFields(before, after any, except ...string) []string {
+	b, a := jsonFields(before), jsonFields(after)
+	names := map[string]bool{}
+	for name := range b {
+		names[name] = true
+	}
+	for name := range a {
+		names[name] = true
+	}
+	fields := []string{}
+	for _, name := range sortedKeys(names) {
+		if !slices.Contains(except, name) && !bytes.Equal(b[name], a[name]) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func jsonFields(v any) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if content, err := json.Marshal(v); err == nil {
+		json.Unmarshal(content, &fields)
+	}
+	return fields
+}
+
+func rpmIds(rpms []*bazeldnf.RPM) []string {
+	ids := []string{}
+	for _, rpm := range rpms {
+		ids = append(ids, rpm.Id)
+	}
+	return ids
+}
+
+// rpmsById returns the RPMs of an architecture of the lock file by id. The
+// empty architecture returns the RPMs of a single architecture lock file.
+func rpmsById(config *bazeldnf.Config, arch string) map[string]*bazeldnf.RPM {
+	rpms := config.RPMs
+	if arch != "" {
+		rpms, _ = config.ArchRPMs(arch)
+	}
+	byId := map[string]*bazeldnf.RPM{}
+	for _, rpm := range rpms {
+		byId[rpm.Id] = rpm
+	}
+	return byId
+}
+
+func rpmFile(rpm *bazeldnf.RPM) string {
+	if len(rpm.URLs) == 0 {
+		return ""
+	}
+	return path.Base(rpm.URLs[0])
+}
+
+// diffStrings returns the entries which are only in after and only in before
+func diffStrings(before, after []string) (added []string, removed []string) {
+	for _, entry := range after {
+		if !slices.Contains(before, entry) {
+			added = append(added, entry)
+		}
+	}
+	for _, entry := range before {
+		if !slices.Contains(after, entry) {
+			removed = append(removed, entry)
+		}
+	}
+	return added, removed
+}
+
+func formatDiff(added, removed []string) string {
+	s := ""
+	if len(added) > 0 {
+		s += ", added " + strings.Join(added, ", ")
+	}
+	if len(removed) > 0 {
+		s += ", removed " + strings.Join(removed, ", ")
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func newDriftRPM(name, file, integrity string, deps ...string) *bazeldnf.RPM {
+	return &bazeldnf.RPM{Id: name, Name: name, Integrity: integrity, URLs: []string{"https://example.com/Packages/" + file}, Repository: "fedora", Dependencies: deps}
+}
+
+func TestLockFileDrift(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	committed := &bazeldnf.Config{
+		Repositories: map[string][]string{"fedora": {"https://example.com"}},
+		RPMs: []*bazeldnf.RPM{
+			newDriftRPM("bash", "bash-5.2.37-1.fc44.x86_64.rpm", "sha256-a", "glibc"),
+			newDriftRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-b"),
+		},
+	}
+
+	drift, err := lockFileDrift(committed, committed)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(BeEmpty())
+
+	resolved := &bazeldnf.Config{
+		Repositories: map[string][]string{"fedora": {"https://example.com"}},
+		RPMs: []*bazeldnf.RPM{
+			newDriftRPM("bash", "bash-5.2.37-1.fc44.x86_64.rpm", "sha256-c", "glibc", "ncurses-libs"),
+			newDriftRPM("glibc", "glibc-2.42-1.fc44.x86_64.rpm", "sha256-d"),
+			newDriftRPM("ncurses-libs", "ncurses-libs-6.5-5.fc44.x86_64.rpm", "sha256-e", "glibc"),
+		},
+	}
+	drift, err = lockFileDrift(committed, resolved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(Equal([]string{
+		"glibc: 2.41-3.fc44 -> 2.42-1.fc44",
+		"ncurses-libs: (none) -> 6.5-5.fc44",
+		"bash: integrity changed from sha256-a to sha256-c",
+		"bash: dependencies changed, added ncurses-libs",
+	}))
+}
+
+func TestLockFileDriftComparesWholeLockFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := func() *bazeldnf.Config {
+		bash := newDriftRPM("bash", "bash-5.2.37-1.fc44.x86_64.rpm", "sha256-a", "glibc")
+		bash.Version = "5.2.37"
+		return &bazeldnf.Config{
+			SchemaVersion: bazeldnf.SchemaVersion2,
+			Repositories:  map[string][]string{"fedora": {"https://example.com"}},
+			Targets:       []string{"bash"},
+			RPMs: []*bazeldnf.RPM{
+				bash,
+				newDriftRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-b"),
+			},
+		}
+	}
+
+	resolved := config()
+	resolved.RPMs[0].URLs = append(resolved.RPMs[0].URLs, "https://mirror.example.com/Packages/bash-5.2.37-1.fc44.x86_64.rpm")
+	resolved.RPMs[0].Version = "5.2.38"
+	drift, err := lockFileDrift(config(), resolved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(Equal([]string{"bash: urls, version changed"}))
+
+	resolved = config()
+	resolved.Repositories["fedora"] = []string{"https://mirror.example.com"}
+	resolved.Targets = []string{"bash", "glibc"}
+	resolved.SchemaVersion = 0
+	drift, err = lockFileDrift(config(), resolved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(Equal([]string{
+		"targets changed, added glibc",
+		"mirrors of repository fedora changed",
+		"schema-version changed",
+	}))
+}
+
+func TestLockFileDriftMultiArch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	config := func() *bazeldnf.Config {
+		return &bazeldnf.Config{
+			Repositories: map[string][]string{"fedora": {"https://example.com"}},
+			Noarch:       []*bazeldnf.RPM{newDriftRPM("tzdata", "tzdata-2025b-1.fc44.noarch.rpm", "sha256-t")},
+			Arches: map[string]*bazeldnf.ArchConfig{
+				"x86_64": {
+					RPMs: []*bazeldnf.RPM{
+						newDriftRPM("bash", "bash-5.2.37-1.fc44.x86_64.rpm", "sha256-a", "glibc"),
+						newDriftRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-b"),
+					},
+					NoarchDependencies: map[string][]string{"tzdata": {}},
+				},
+			},
+		}
+	}
+
+	resolved := config()
+	resolved.Arches["x86_64"].ForceIgnored = []string{"kernel"}
+	drift, err := lockFileDrift(config(), resolved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(Equal([]string{"x86_64: ignored packages changed, added kernel"}))
+
+	// tzdata moves from the shared noarch packages to the architecture
+	resolved = config()
+	resolved.Arches["x86_64"].RPMs = append(resolved.Arches["x86_64"].RPMs, resolved.Noarch[0])
+	resolved.Arches["x86_64"].NoarchDependencies = nil
+	resolved.Noarch = nil
+	drift, err = lockFileDrift(config(), resolved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(Equal([]string{"shared noarch packages changed, removed tzdata"}))
+}
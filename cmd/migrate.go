@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type migrateOpts struct {
+	repofiles  []string
+	workspace  string
+	fromMacro  string
+	buildfiles []string
+	configname string
+	lockfile   string
+	schema     int
+	dryRun     bool
+}
+
+var migrateopts = migrateOpts{}
+
+// migrationTree is an rpmtree rule together with the packages it references
+type migrationTree struct {
+	name      string
+	pkgs      []*api.Package
+	buildfile *build.File
+}
+
+func NewMigrateCmd() *cobra.Command {
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate rpm rules of a WORKSPACE or macro to a lock file for bzlmod",
+		Long: `Create a lock file from the rpm rules of a WORKSPACE or macro and let the
+rpmtree rules of the given BUILD files reference the RPMs of the lock file.
+The rpm rules are matched with the cached repository metadata by their sha256
+to recover the package names, the repositories and the dependencies, run
+'bazeldnf fetch' first. The RPMs get their package names as ids, like in lock
+files of 'bazeldnf lockfile', and the rpmtree rules reference them as
+@<configname>//<package name> afterwards. Packages which are part of the rpm
+rules several times, like for several architectures, keep the rpm rule names
+as ids. All packages become targets of the lock file, so that it can be
+verified and updated like lock files of 'bazeldnf lockfile', unless a package
+name is used several times. The rpm rules are not removed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if migrateopts.schema < bazeldnf.SchemaVersion1 || migrateopts.schema > bazeldnf.LatestSchemaVersion {
+				return fmt.Errorf("unsupported lock file schema version %d, supported are %d to %d", migrateopts.schema, bazeldnf.SchemaVersion1, bazeldnf.LatestSchemaVersion)
+			}
+			rules, source, err := loadMigrationRules()
+			if err != nil {
+				return err
+			}
+			byRule, pkgs, err := migrationPackages(rules)
+			if err != nil {
+				return err
+			}
+
+			buildfiles := map[string]*build.File{}
+			trees := []migrationTree{}
+			for _, path := range migrateopts.buildfiles {
+				buildfile, err := bazel.LoadBuild(path)
+				if err != nil {
+					return err
+				}
+				buildfiles[path] = buildfile
+				fileTrees, err := migrationTrees(buildfile, byRule)
+				if err != nil {
+					return fmt.Errorf("failed to migrate %s: %v", path, err)
+				}
+				for _, tree := range fileTrees {
+					tree.buildfile = buildfile
+					trees = append(trees, tree)
+				}
+			}
+
+			ids := migrationIds(pkgs)
+			config, err := migrationConfig(pkgs, trees, ids, migrateopts.schema)
+			if err != nil {
+				return err
+			}
+			if len(config.Targets) > 0 {
+				config.CommandLineArguments = migrationArguments(cmd, pkgs, config.Targets)
+			} else {
+				logrus.Warnf("The rpm rules contain packages with the same name, the lock file can't be updated, create it with 'bazeldnf lockfile' instead.")
+			}
+			for _, tree := range trees {
+				labels := []string{}
+				for _, pkg := range tree.pkgs {
+					labels = append(labels, "@"+migrateopts.configname+"//"+ids[pkg])
+				}
+				bazel.SetTree(tree.name, tree.buildfile, labels, false)
+			}
+
+			if migrateopts.dryRun {
+				logrus.Infof("Migrating %d RPMs and %d rpmtree rules, nothing is written in dry-run mode.", len(config.RPMs), len(trees))
+				return nil
+			}
+			logrus.Info("Writing lockfile.")
+			if err := bazel.WriteLockFile(config, migrateopts.lockfile); err != nil {
+				return err
+			}
+			for _, path := range migrateopts.buildfiles {
+				if err := bazel.WriteBuild(false, buildfiles[path], path); err != nil {
+					return err
+				}
+			}
+			logrus.Infof("Migrated %d RPMs and %d rpmtree rules. Load %s with the bazeldnf module extension as config %q, the rpm rules in %s are not needed anymore.", len(config.RPMs), len(trees), migrateopts.lockfile, migrateopts.configname, source)
+			return nil
+		},
+	}
+
+	migrateCmd.Flags().StringArrayVarP(&migrateopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times")
+	migrateCmd.Flags().StringVarP(&migrateopts.workspace, "workspace", "w", "WORKSPACE", "Bazel workspace file with the rpm rules")
+	migrateCmd.Flags().StringVar(&migrateopts.fromMacro, "from-macro", "", "read the rpm rules from a macro in the given bzl file instead of the WORKSPACE file. The expected format is: macroFile%defName")
+	migrateCmd.Flags().StringArrayVarP(&migrateopts.buildfiles, "buildfile", "b", []string{"rpm/BUILD.bazel"}, "Build file with rpmtree rules to migrate. Can be specified multiple times")
+	migrateCmd.Flags().StringVar(&migrateopts.configname, "configname", "rpms", "config name to use in lockfile")
+	migrateCmd.Flags().StringVar(&migrateopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to write to")
+	migrateCmd.Flags().IntVar(&migrateopts.schema, "schema-version", bazeldnf.SchemaVersion1, fmt.Sprintf("lock file schema version to write, version %d adds the package metadata like version, license and sizes", bazeldnf.SchemaVersion2))
+	migrateCmd.Flags().BoolVar(&migrateopts.dryRun, "dry-run", false, "only check that all rpm rules and rpmtree rules can be migrated")
+	repo.AddCacheHelperFlags(migrateCmd)
+	repo.AddRepoFilterFlags(migrateCmd)
+	return migrateCmd
+}
+
+// loadMigrationRules returns the rpm rules of the macro or the WORKSPACE and
+// the file they are read from
+func loadMigrationRules() ([]*bazel.RPMRule, string, error) {
+	if migrateopts.fromMacro != "" {
+		bzl, defname, err := bazel.ParseMacro(migrateopts.fromMacro)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse from-macro expression %q: %v", migrateopts.fromMacro, err)
+		}
+		bzlfile, err := bazel.LoadBzl(bzl)
+		if err != nil {
+			return nil, "", err
+		}
+		return bazel.GetBzlfileRPMs(bzlfile, defname), bzl, nil
+	}
+	workspace, err := bazel.LoadWorkspace(migrateopts.workspace)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open workspace %s: %v", migrateopts.workspace, err)
+	}
+	return bazel.GetWorkspaceRPMs(workspace), migrateopts.workspace, nil
+}
+
+// migrationPackages looks up the packages of the rpm rules in the cached
+// repository metadata. It returns the packages by rule name and all packages
+// in the order of the rules.
+func migrationPackages(rules []*bazel.RPMRule) (map[string]*api.Package, []*api.Package, error) {
+	cached := cachedPackagesByChecksum(migrateopts.repofiles, "")
+	byRule := map[string]*api.Package{}
+	pkgs := []*api.Package{}
+	missing := []string{}
+	for _, rule := range rules {
+		pkg, exists := cached[rule.SHA256()]
+		if !exists {
+			missing = append(missing, rule.Name())
+			continue
+		}
+		byRule[rule.Name()] = pkg
+		if !slices.Contains(pkgs, pkg) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("the packages of the rpm rules %s are not in the cached repository metadata, add their repositories and run 'bazeldnf fetch'", strings.Join(missing, ", "))
+	}
+	return byRule, pkgs, nil
+}
+
+// migrationTrees returns the rpmtree rules of the BUILD file with the packages
+// of the rpm rules they reference
+func migrationTrees(buildfile *build.File, byRule map[string]*api.Package) ([]migrationTree, error) {
+	trees := []migrationTree{}
+	for _, rule := range buildfile.Rules("rpmtree") {
+		tree := migrationTree{name: rule.Name()}
+		for _, label := range rule.AttrStrings("rpms") {
+			pkg, exists := byRule[rpmRuleName(label)]
+			if !exists {
+				return nil, fmt.Errorf("rpmtree %s references %s, which is not an rpm rule", tree.name, label)
+			}
+			tree.pkgs = append(tree.pkgs, pkg)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
+
+// rpmRuleName returns the rpm rule name of a label like @name//rpm
+func rpmRuleName(label string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(label, "@"), "//")
+	return name
+}
+
+// migrationIds returns the lock file ids of the packages. Like in lock files
+// of 'bazeldnf lockfile' the ids are the package names, packages whose name
+// is used several times keep the names of their rpm rules.
+func migrationIds(pkgs []*api.Package) map[*api.Package]string {
+	count := map[string]int{}
+	for _, pkg := range pkgs {
+		count[pkg.Name]++
+	}
+	ids := map[*api.Package]string{}
+	for _, pkg := range pkgs {
+		ids[pkg] = makeId(pkg)
+		if count[pkg.Name] > 1 {
+			ids[pkg] = bazel.PackageName(pkg)
+		}
+	}
+	return ids
+}
+
+// migrationConfig creates the lock file config for the packages of the rpm
+// rules. The dependencies of a package are looked up among the packages of
+// the rpmtree rules which contain it, the packages of no rpmtree rule only
+// depend on each other. Requirements without provider are skipped, they are
+// usually satisfied by a base image. The packages are the targets of the lock
+// file, unless a package name is used several times, which 'bazeldnf lockfile'
+// can't resolve.
+func migrationConfig(pkgs []*api.Package, trees []migrationTree, ids map[*api.Package]string, schemaVersion int) (*bazeldnf.Config, error) {
+	sets := [][]*api.Package{}
+	inTree := map[*api.Package]bool{}
+	for _, tree := range trees {
+		sets = append(sets, tree.pkgs)
+		for _, pkg := range tree.pkgs {
+			inTree[pkg] = true
+		}
+	}
+	rest := []*api.Package{}
+	for _, pkg := range pkgs {
+		if !inTree[pkg] {
+			rest = append(rest, pkg)
+		}
+	}
+	sets = append(sets, rest)
+
+	dependencies := map[*api.Package]map[string]bool{}
+	for _, set := range sets {
+		providers := collectProviders(set)
+		for _, pkg := range set {
+			requires := []string{}
+			for _, entry := range pkg.Format.Requires.Entries {
+				if _, exists := providers[entry.Name]; exists {
+					requires = append(requires, entry.Name)
+				}
+			}
+			deps, err := collectDependencies(pkg, requires, providers, nil)
+			if err != nil {
+				return nil, err
+			}
+			if dependencies[pkg] == nil {
+				dependencies[pkg] = map[string]bool{}
+			}
+			for _, dep := range deps {
+				dependencies[pkg][ids[dep]] = true
+			}
+		}
+	}
+
+	config := &bazeldnf.Config{
+		Repositories: map[string][]string{},
+		RPMs:         []*bazeldnf.RPM{},
+		Targets:      []string{},
+	}
+	if schemaVersion > bazeldnf.SchemaVersion1 {
+		config.SchemaVersion = schemaVersion
+	}
+	for _, pkg := range pkgs {
+		integrity, err := pkg.Checksum.Integrity()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read package %s integrity: %w", pkg.Name, err)
+		}
+		config.Repositories[pkg.Repository.Name] = pkg.Repository.Mirrors
+		rpm := &bazeldnf.RPM{
+			Id:           ids[pkg],
+			Name:         pkg.Name,
+			Integrity:    integrity,
+			URLs:         []string{pkg.Location.Href},
+			Repository:   pkg.Repository.Name,
+			Dependencies: sortedKeys(dependencies[pkg]),
+		}
+		if schemaVersion >= bazeldnf.SchemaVersion2 {
+			addMetadata(rpm, pkg)
+		}
+		config.RPMs = append(config.RPMs, rpm)
+		if ids[pkg] != pkg.Name {
+			config.Targets = nil
+		} else if config.Targets != nil {
+			config.Targets = append(config.Targets, pkg.Name)
+		}
+	}
+	slices.Sort(config.Targets)
+	slices.SortFunc(config.RPMs, func(a, b *bazeldnf.RPM) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return config, nil
+}
+
+// migrationArguments returns the arguments of 'bazeldnf lockfile' which
+// resolve the targets again. The repository options of the migration are
+// kept. Since all packages are targets, no base system is needed.
+func migrationArguments(cmd *cobra.Command, pkgs []*api.Package, targets []string) []string {
+	args := []string{}
+	for _, repofile := range migrateopts.repofiles {
+		args = append(args, "--repofile", repofile)
+	}
+	flags := cmd.Flags()
+	for _, name := range []string{"cache-dir", "enablerepo", "disablerepo"} {
+		flag := flags.Lookup(name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		if values, err := flags.GetStringArray(name); err == nil {
+			for _, value := range values {
+				args = append(args, "--"+name, value)
+			}
+		} else {
+			args = append(args, "--"+name, flag.Value.String())
+		}
+	}
+	arches := []string{}
+	for _, pkg := range pkgs {
+		if pkg.Arch != "noarch" && !slices.Contains(arches, pkg.Arch) {
+			arches = append(arches, pkg.Arch)
+		}
+	}
+	slices.Sort(arches)
+	if len(arches) == 1 {
+		args = append(args, "--arch", arches[0])
+	} else if len(arches) > 1 {
+		args = append(args, "--target-arch", strings.Join(arches, ","))
+	}
+	args = append(args, "--basesystem", "")
+	if migrateopts.schema > bazeldnf.SchemaVersion1 {
+		args = append(args, "--schema-version", fmt.Sprint(migrateopts.schema))
+	}
+	args = append(args, "--configname", migrateopts.configname, "--lockfile", migrateopts.lockfile)
+	return append(args, targets...)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockdiff"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+)
+
+func newMigrationPackage(name, arch string, deps ...string) *api.Package {
+	pkg := newPackageWithDeps(name, deps...)
+	pkg.Arch = arch
+	pkg.Version = api.Version{Epoch: "0", Ver: "1.0", Rel: "1.fc44"}
+	pkg.Checksum = api.Checksum{Type: "sha256", Text: "aabd099d"}
+	pkg.Location.Href = "Packages/" + name + "-1.0-1.fc44." + arch + ".rpm"
+	pkg.Repository = &bazeldnf.Repository{Name: "fedora", Mirrors: []string{"https://example.com/fedora/44/"}}
+	return pkg
+}
+
+func TestMigrationTrees(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bash := newMigrationPackage("bash", "x86_64")
+	byRule := map[string]*api.Package{"bash-0__1.0-1.fc44.x86_64": bash}
+
+	buildfile, err := build.ParseBuild("BUILD.bazel", []byte(`rpmtree(
+    name = "sandbox",
+    rpms = ["@bash-0__1.0-1.fc44.x86_64//rpm"],
+)
+`))
+	g.Expect(err).Should(BeNil())
+	trees, err := migrationTrees(buildfile, byRule)
+	g.Expect(err).Should(BeNil())
+	g.Expect(trees).Should(Equal([]migrationTree{{name: "sandbox", pkgs: []*api.Package{bash}}}))
+
+	buildfile, err = build.ParseBuild("BUILD.bazel", []byte(`rpmtree(
+    name = "sandbox",
+    rpms = ["@coreutils-0__9.5-1.fc44.x86_64//rpm"],
+)
+`))
+	g.Expect(err).Should(BeNil())
+	_, err = migrationTrees(buildfile, byRule)
+	g.Expect(err).Should(MatchError("rpmtree sandbox references @coreutils-0__9.5-1.fc44.x86_64//rpm, which is not an rpm rule"))
+}
+
+func TestMigrationConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bash := newMigrationPackage("bash", "x86_64", "glibc", "filesystem")
+	glibc := newMigrationPackage("glibc", "x86_64")
+	bashArm := newMigrationPackage("bash", "aarch64", "glibc", "filesystem")
+	glibcArm := newMigrationPackage("glibc", "aarch64")
+	// not referenced by any rpmtree
+	tzdata := newMigrationPackage("tzdata", "noarch")
+	pkgs := []*api.Package{bash, glibc, bashArm, glibcArm, tzdata}
+	trees := []migrationTree{
+		{name: "sandbox_x86_64", pkgs: []*api.Package{bash, glibc}},
+		{name: "sandbox_aarch64", pkgs: []*api.Package{bashArm, glibcArm}},
+	}
+
+	// packages of several architectures keep the rpm rule names as ids
+	config, err := migrationConfig(pkgs, trees, migrationIds(pkgs), bazeldnf.SchemaVersion1)
+	g.Expect(err).Should(BeNil())
+	g.Expect(config.SchemaVersion).Should(Equal(0))
+	g.Expect(config.Repositories).Should(Equal(map[string][]string{"fedora": {"https://example.com/fedora/44/"}}))
+	g.Expect(config.RPMs).Should(HaveLen(5))
+	g.Expect(config.Targets).Should(BeNil())
+	g.Expect(config.RPMs[0]).Should(Equal(&bazeldnf.RPM{
+		Id:           "bash-0__1.0-1.fc44.aarch64",
+		Name:         "bash",
+		Integrity:    "sha256-qr0JnQ==",
+		URLs:         []string{"Packages/bash-1.0-1.fc44.aarch64.rpm"},
+		Repository:   "fedora",
+		Dependencies: []string{"glibc-0__1.0-1.fc44.aarch64"},
+	}))
+	g.Expect(config.RPMs[1].Id).Should(Equal("bash-0__1.0-1.fc44.x86_64"))
+	g.Expect(config.RPMs[1].Dependencies).Should(Equal([]string{"glibc-0__1.0-1.fc44.x86_64"}))
+	g.Expect(config.RPMs[4].Id).Should(Equal("tzdata"))
+	g.Expect(config.RPMs[4].Dependencies).Should(BeEmpty())
+
+	// packages used once get their names as ids, like in 'bazeldnf lockfile'
+	pkgs = []*api.Package{bash, glibc, tzdata}
+	config, err = migrationConfig(pkgs, trees[:1], migrationIds(pkgs), bazeldnf.SchemaVersion2)
+	g.Expect(err).Should(BeNil())
+	g.Expect(config.SchemaVersion).Should(Equal(bazeldnf.SchemaVersion2))
+	g.Expect(config.Targets).Should(Equal([]string{"bash", "glibc", "tzdata"}))
+	g.Expect(config.RPMs[0].Id).Should(Equal("bash"))
+	g.Expect(config.RPMs[0].Arch).Should(Equal("x86_64"))
+	g.Expect(config.RPMs[0].Dependencies).Should(Equal([]string{"glibc"}))
+}
+
+func restoreMigrateOptions(t *testing.T) {
+	original := migrateopts
+	t.Cleanup(func() { migrateopts = original })
+}
+
+func TestMigrationArguments(t *testing.T) {
+	g := NewGomegaWithT(t)
+	restoreMigrateOptions(t)
+	restoreLockFileOptions(t)
+
+	cmd := NewMigrateCmd()
+	g.Expect(cmd.ParseFlags([]string{"--repofile", "repo.yaml", "--cache-dir", "/tmp/cache", "--disablerepo", "updates", "--schema-version", "2"})).To(Succeed())
+	bash := newMigrationPackage("bash", "x86_64")
+	tzdata := newMigrationPackage("tzdata", "noarch")
+	args := migrationArguments(cmd, []*api.Package{bash, tzdata}, []string{"bash", "tzdata"})
+	g.Expect(args).Should(Equal([]string{
+		"--repofile", "repo.yaml", "--cache-dir", "/tmp/cache", "--disablerepo", "updates", "--arch", "x86_64", "--basesystem", "",
+		"--schema-version", "2", "--configname", "rpms", "--lockfile", "bazeldnf-lock.json", "bash", "tzdata",
+	}))
+
+	// the arguments can be replayed like the ones of 'bazeldnf lockfile'
+	required, err := replayLockFileArguments(&bazeldnf.Config{CommandLineArguments: args})
+	g.Expect(err).Should(BeNil())
+	g.Expect(required).Should(Equal([]string{"bash", "tzdata"}))
+	g.Expect(resolvehelperopts.arch).Should(Equal([]string{"x86_64"}))
+	g.Expect(resolvehelperopts.baseSystem).Should(BeEmpty())
+	g.Expect(lockfileopts.schema).Should(Equal(bazeldnf.SchemaVersion2))
+
+	glibcArm := newMigrationPackage("glibc", "aarch64")
+	args = migrationArguments(cmd, []*api.Package{bash, glibcArm, tzdata}, []string{"bash", "glibc", "tzdata"})
+	required, err = replayLockFileArguments(&bazeldnf.Config{CommandLineArguments: args})
+	g.Expect(err).Should(BeNil())
+	g.Expect(required).Should(Equal([]string{"bash", "glibc", "tzdata"}))
+	g.Expect(lockfileopts.arches).Should(Equal([]string{"aarch64", "x86_64"}))
+}
+
+func TestMigrateAndVerify(t *testing.T) {
+	g := NewGomegaWithT(t)
+	restoreMigrateOptions(t)
+	restoreLockFileOptions(t)
+
+	newRepoPackage := func(name string, requires ...string) api.Package {
+		pkg := newMigrationPackage(name, "x86_64", requires...)
+		pkg.Checksum.Text = fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+		pkg.Repository = nil
+		return *pkg
+	}
+	repoPkgs := []api.Package{newRepoPackage("bash", "glibc"), newRepoPackage("glibc")}
+
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	writeCachedPrimary(t, cacheDir, "fedora", repoPkgs)
+	repofile := filepath.Join(dir, "repo.yaml")
+	g.Expect(os.WriteFile(repofile, []byte("repositories:\n- name: fedora\n  baseurl: https://example.com/fedora/44/\n"), 0644)).To(Succeed())
+
+	// a WORKSPACE and BUILD file like 'bazeldnf rpmtree' writes them
+	pkgs := []*api.Package{}
+	for i := range repoPkgs {
+		pkg := repoPkgs[i]
+		pkg.Repository = &bazeldnf.Repository{Name: "fedora", Mirrors: []string{"https://example.com/fedora/44/"}}
+		pkgs = append(pkgs, &pkg)
+	}
+	workspace := &build.File{Type: build.TypeWorkspace}
+	g.Expect(bazel.AddWorkspaceRPMs(workspace, pkgs)).To(Succeed())
+	workspacefile := filepath.Join(dir, "WORKSPACE")
+	g.Expect(bazel.WriteWorkspace(false, workspace, workspacefile)).To(Succeed())
+	buildfile := &build.File{Type: build.TypeBuild}
+	bazel.AddTree("sandbox", "", buildfile, pkgs, false)
+	buildfilePath := filepath.Join(dir, "BUILD.bazel")
+	g.Expect(bazel.WriteBuild(false, buildfile, buildfilePath)).To(Succeed())
+
+	lockfile := filepath.Join(dir, "bazeldnf-lock.json")
+	cmd := NewMigrateCmd()
+	cmd.SetArgs([]string{"--repofile", repofile, "--cache-dir", cacheDir, "--workspace", workspacefile, "--buildfile", buildfilePath, "--lockfile", lockfile})
+	g.Expect(cmd.Execute()).To(Succeed())
+
+	migratedBuild, err := bazel.LoadBuild(buildfilePath)
+	g.Expect(err).Should(BeNil())
+	g.Expect(migratedBuild.Rules("rpmtree")[0].AttrStrings("rpms")).Should(Equal([]string{"@rpms//bash", "@rpms//glibc"}))
+
+	// resolving the migrated lock file again, like 'lockfile verify' and
+	// 'lockfile update' do, doesn't change it
+	migrated, err := bazel.LoadLockFile(lockfile)
+	g.Expect(err).Should(BeNil())
+	g.Expect(migrated.Targets).Should(Equal([]string{"bash", "glibc"}))
+	required, err := replayLockFileArguments(migrated)
+	g.Expect(err).Should(BeNil())
+	repos, err := repo.LoadRepoFilesWithFilters(lockfileopts.repofiles)
+	g.Expect(err).Should(BeNil())
+	resolved, err := resolveLockFile(repos, required, migrated.CommandLineArguments)
+	g.Expect(err).Should(BeNil())
+	drift, err := lockFileDrift(migrated, resolved)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(BeEmpty())
+
+	resolvehelperopts.locked = migrated
+	resolvehelperopts.unlocked = map[string]bool{"bash": true}
+	updated, err := resolveLockFile(repos, required, migrated.CommandLineArguments)
+	g.Expect(err).Should(BeNil())
+	g.Expect(lockFileChanges(migrated, updated)).Should(BeEmpty())
+	drift, err = lockFileDrift(migrated, updated)
+	g.Expect(err).Should(BeNil())
+	g.Expect(drift).Should(BeEmpty())
+}
+
+func TestMigrationLockFileDiff(t *testing.T) {
+	bash := newMigrationPackage("bash", "x86_64", "glibc")
+	glibc := newMigrationPackage("glibc", "x86_64")
+	newerBash := newMigrationPackage("bash", "x86_64", "glibc")
+	newerBash.Version = api.Version{Epoch: "1", Ver: "1.1", Rel: "1.fc44"}
+	newerBash.Location.Href = "Packages/bash-1.1-1.fc44.x86_64.rpm"
+
+	for _, schemaVersion := range []int{bazeldnf.SchemaVersion1, bazeldnf.SchemaVersion2} {
+		g := NewGomegaWithT(t)
+		lockfile := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+
+		pkgs := []*api.Package{bash, glibc}
+		config, err := migrationConfig(pkgs, []migrationTree{{name: "sandbox", pkgs: pkgs}}, migrationIds(pkgs), schemaVersion)
+		g.Expect(err).Should(BeNil())
+		g.Expect(bazel.WriteLockFile(config, lockfile)).To(Succeed())
+		migrated, err := bazel.LoadLockFile(lockfile)
+		g.Expect(err).Should(BeNil())
+
+		changes, err := lockdiff.Compare(migrated, migrated)
+		g.Expect(err).Should(BeNil())
+		g.Expect(changes).Should(BeEmpty())
+
+		pkgs = []*api.Package{newerBash, glibc}
+		updated, err := migrationConfig(pkgs, []migrationTree{{name: "sandbox", pkgs: pkgs}}, migrationIds(pkgs), schemaVersion)
+		g.Expect(err).Should(BeNil())
+		changes, err = lockdiff.Compare(migrated, updated)
+		g.Expect(err).Should(BeNil())
+		g.Expect(changes).Should(HaveLen(1))
+		g.Expect(changes[0].Name).Should(Equal("bash"))
+		g.Expect(changes[0].Kind).Should(Equal(lockdiff.Upgraded))
+		if schemaVersion == bazeldnf.SchemaVersion2 {
+			g.Expect(changes[0].New.EVR()).Should(Equal("1:1.1-1.fc44"))
+		} else {
+			// the epoch is unknown without metadata
+			g.Expect(changes[0].New.EVR()).Should(Equal("1.1-1.fc44"))
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// checkTargetArchFlags rejects --arch together with --target-arch. Every
+// target architecture is resolved on its own, so additional architectures
+// like the ones of a multilib setup would be dropped silently.
+func checkTargetArchFlags(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("arch") && cmd.Flags().Changed("target-arch") {
+		return fmt.Errorf("--arch can't be combined with --target-arch, every target architecture is resolved on its own")
+	}
+	return nil
+}
+
+// resolveArches resolves the required packages once for every target
+// architecture. The resolve helper flags are shared, only the architecture
+// changes between the runs.
+func resolveArches(repos *bazeldnf.Repositories, required []string, arches []string, process func(arch string, install, forceIgnored, base []*api.Package) error) error {
+	original := resolvehelperopts.arch
+	defer func() { resolvehelperopts.arch = original }()
+	for _, arch := range arches {
+		logrus.Infof("Resolving for architecture %s.", arch)
+		resolvehelperopts.arch = []string{arch}
+		install, forceIgnored, base, err := resolve(repos, required)
+		if err != nil {
+			return err
+		}
+		if err := process(arch, install, forceIgnored, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNoarch(rpm *bazeldnf.RPM) bool {
+	return len(rpm.URLs) > 0 && strings.HasSuffix(rpm.URLs[0], ".noarch.rpm")
+}
+
+// mergeArchConfigs merges the lock files of several architectures into one
+// multi architecture lock file. Noarch RPMs which were picked in the same
+// version for all architectures which need them are stored only once, their
+// dependencies are kept per architecture since they may be resolved to
+// architecture specific packages.
+func mergeArchConfigs(arches []string, configs map[string]*bazeldnf.Config) *bazeldnf.Config {
+	merged := &bazeldnf.Config{
+		Repositories: map[string][]string{},
+		RPMs:         []*bazeldnf.RPM{},
+		Arches:       map[string]*bazeldnf.ArchConfig{},
+	}
+
+	integrities := map[string]map[string]bool{}
+	noarch := map[string]*bazeldnf.RPM{}
+	for _, arch := range arches {
+		config := configs[arch]
+		merged.SchemaVersion = config.SchemaVersion
+		merged.CommandLineArguments = config.CommandLineArguments
+		merged.Targets = config.Targets
+		for name, mirrors := range config.Repositories {
+			merged.Repositories[name] = mirrors
+		}
+		for _, rpm := range config.RPMs {
+			if !isNoarch(rpm) {
+				continue
+			}
+			if integrities[rpm.Id] == nil {
+				integrities[rpm.Id] = map[string]bool{}
+			}
+			integrities[rpm.Id][rpm.Integrity] = true
+			noarch[rpm.Id] = rpm
+		}
+	}
+
+	for _, arch := range arches {
+		config := configs[arch]
+		archConfig := &bazeldnf.ArchConfig{
+			RPMs:         []*bazeldnf.RPM{},
+			ForceIgnored: config.ForceIgnored,
+		}
+		for _, rpm := range config.RPMs {
+			if !isNoarch(rpm) || len(integrities[rpm.Id]) > 1 {
+				archConfig.RPMs = append(archConfig.RPMs, rpm)
+				continue
+			}
+			if archConfig.NoarchDependencies == nil {
+				archConfig.NoarchDependencies = map[string][]string{}
+			}
+			archConfig.NoarchDependencies[rpm.Id] = rpm.Dependencies
+		}
+		merged.Arches[arch] = archConfig
+	}
+
+	for _, id := range sortedKeys(noarch) {
+		if len(integrities[id]) > 1 {
+			logrus.Infof("Noarch package %s differs between architectures, keeping it per architecture.", id)
+			continue
+		}
+		shared := *noarch[id]
+		shared.Dependencies = []string{}
+		merged.Noarch = append(merged.Noarch, &shared)
+	}
+
+	return merged
+}
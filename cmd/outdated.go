@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/outdated"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type outdatedOpts struct {
+	repofiles []string
+	lockfile  string
+	workspace string
+	fromMacro string
+	output    string
+}
+
+var outdatedopts = outdatedOpts{}
+
+func NewOutdatedCmd() *cobra.Command {
+
+	outdatedCmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "Show locked packages with newer versions in the repositories",
+		Long: `Compare the packages of a lock file, WORKSPACE or macro with the cached
+repository metadata and show the packages which have newer versions.
+Updates which are blocked by the requirements of other locked packages are
+flagged. If the changelogs were fetched with 'bazeldnf fetch --changelogs',
+updates which fix CVEs are highlighted.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outdatedopts.output != "table" && outdatedopts.output != "json" {
+				return fmt.Errorf("unsupported output format %s, supported are table and json", outdatedopts.output)
+			}
+			locked, err := loadLockedPackages()
+			if err != nil {
+				return err
+			}
+			repos, err := repo.LoadRepoFilesWithFilters(outdatedopts.repofiles)
+			if err != nil {
+				return err
+			}
+
+			arches := []string{}
+			for _, l := range locked {
+				if !slices.Contains(arches, l.Arch) {
+					arches = append(arches, l.Arch)
+				}
+			}
+			cacheHelper := repo.NewCacheHelper()
+			primaries, err := cacheHelper.CurrentPrimaries(repos, arches)
+			if err != nil {
+				return err
+			}
+			available := []*api.Package{}
+			for _, primary := range primaries {
+				for i := range primary.Repo.Packages {
+					available = append(available, &primary.Repo.Packages[i])
+				}
+			}
+
+			entries := outdated.Check(locked, available)
+			addSecurityInformation(cacheHelper, repos.Repositories, entries)
+
+			if outdatedopts.output == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "\t")
+				return encoder.Encode(entries)
+			}
+			return writeOutdatedTable(entries)
+		},
+	}
+
+	outdatedCmd.Flags().StringArrayVarP(&outdatedopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times")
+	outdatedCmd.Flags().StringVar(&outdatedopts.lockfile, "lockfile", "", "lock file with the locked packages")
+	outdatedCmd.Flags().StringVarP(&outdatedopts.workspace, "workspace", "w", "", "Bazel workspace file with the locked packages")
+	outdatedCmd.Flags().StringVar(&outdatedopts.fromMacro, "from-macro", "", "read the locked packages from a macro in the given bzl file. The expected format is: macroFile%defName")
+	outdatedCmd.Flags().StringVarP(&outdatedopts.output, "output", "o", "table", "output format, table or json")
+	repo.AddCacheHelperFlags(outdatedCmd)
+	repo.AddRepoFilterFlags(outdatedCmd)
+	return outdatedCmd
+}
+
+// loadLockedPackages reads the locked packages from the lock file, WORKSPACE
+// or macro. The lock file bazeldnf-lock.json is used if nothing is given.
+func loadLockedPackages() ([]*outdated.Locked, error) {
+	sources := 0
+	for _, source := range []string{outdatedopts.lockfile, outdatedopts.workspace, outdatedopts.fromMacro} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("only one of --lockfile, --workspace and --from-macro can be used")
+	}
+
+	keys := []*api.PackageKey{}
+	addRule := func(rule *bazel.RPMRule) error {
+		urls := rule.URLs()
+		if len(urls) == 0 {
+			return nil
+		}
+		key, err := rpm.ParseFileName(urls[0])
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	}
+	switch {
+	case outdatedopts.workspace != "":
+		workspace, err := bazel.LoadWorkspace(outdatedopts.workspace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open workspace %s: %v", outdatedopts.workspace, err)
+		}
+		for _, rule := range bazel.GetWorkspaceRPMs(workspace) {
+			if err := addRule(rule); err != nil {
+				return nil, err
+			}
+		}
+	case outdatedopts.fromMacro != "":
+		bzl, defname, err := bazel.ParseMacro(outdatedopts.fromMacro)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse from-macro expression %q: %v", outdatedopts.fromMacro, err)
+		}
+		bzlfile, err := bazel.LoadBzl(bzl)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range bazel.GetBzlfileRPMs(bzlfile, defname) {
+			if err := addRule(rule); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		lockfile := outdatedopts.lockfile
+		if lockfile == "" {
+			lockfile = "bazeldnf-lock.json"
+		}
+		config, err := bazel.LoadLockFile(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		for _, locked := range lockedRPMs(config) {
+			key, err := rpm.LockedPackage(locked)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	locked := []*outdated.Locked{}
+	seen := map[api.PackageKey]bool{}
+	for _, key := range keys {
+		if !seen[*key] {
+			seen[*key] = true
+			locked = append(locked, &outdated.Locked{Name: key.Name, Arch: key.Arch, Version: key.Version})
+		}
+	}
+	return locked, nil
+}
+
+// addSecurityInformation records the CVEs fixed by the updates, if other.xml
+// of their repository is cached
+func addSecurityInformation(cacheHelper *repo.CacheHelper, repositories []bazeldnf.Repository, entries []*outdated.Entry) {
+	for i := range repositories {
+		r := &repositories[i]
+		names := map[string]bool{}
+		for _, entry := range entries {
+			if entry.Repository == r.Name {
+				names[entry.Name] = true
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		pkgs, err := cacheHelper.CurrentChangelogs(r, names)
+		if err != nil {
+			logrus.Infof("Security information of repository %s is not available: %v", r.Name, err)
+			continue
+		}
+		for _, entry := range entries {
+			latest := entry.LatestPackage()
+			for _, pkg := range pkgs {
+				if entry.Repository == r.Name && pkg.Name == latest.Name && pkg.Arch == latest.Arch && pkg.Version.Ver == latest.Version.Ver && pkg.Version.Rel == latest.Version.Rel {
+					entry.SetChangelog(pkg.Changelog)
+					break
+				}
+			}
+		}
+	}
+}
+
+func writeOutdatedTable(entries []*outdated.Entry) error {
+	if len(entries) == 0 {
+		fmt.Println("All packages are up to date.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tARCH\tCURRENT\tLATEST\tREPOSITORY\tNOTES")
+	for _, entry := range entries {
+		notes := []string{}
+		if entry.Security() {
+			notes = append(notes, "SECURITY: "+strings.Join(entry.CVEs, ", "))
+		}
+		if entry.Blocked() {
+			notes = append(notes, "blocked: "+strings.Join(entry.BlockedBy, ", "))
+		}
+		name := entry.Name
+		if entry.Security() {
+			name = "! " + name
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, entry.Arch, entry.Current, entry.Latest, entry.Repository, strings.Join(notes, "; "))
+	}
+	return w.Flush()
+}
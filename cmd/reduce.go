@@ -14,13 +14,16 @@ import (
 )
 
 type reduceOpts struct {
-	in            []string
-	repofiles     []string
-	out           string
-	nobest        bool
-	ignoreMissing bool
-	architectures []string
-	baseSystem    string
+	in              []string
+	repofiles       []string
+	out             string
+	nobest          bool
+	ignoreMissing   bool
+	architectures   []string
+	baseSystem      string
+	priorityMasking bool
+	fromRepo        []string
+	fixups          []string
 }
 
 var reduceopts = reduceOpts{}
@@ -38,12 +41,19 @@ which allow reducing huge rpm repos to a smaller problem set for debugging, remo
 			repos := &bazeldnf.Repositories{}
 			if len(reduceopts.in) == 0 {
 				var err error
-				repos, err = repo.LoadRepoFiles(reduceopts.repofiles)
+				repos, err = repo.LoadRepoFilesWithFilters(reduceopts.repofiles)
 				if err != nil {
 					return err
 				}
 			}
-			_, involved, err := reducer.Resolve(repos, reduceopts.in, reduceopts.baseSystem, EffectiveArchitectures(reduceopts.architectures), required, reduceopts.ignoreMissing)
+			if err := parseFromRepo(repos, reduceopts.fromRepo); err != nil {
+				return err
+			}
+			fixer, err := loadFixups(append(repos.Fixups, reduceopts.fixups...))
+			if err != nil {
+				return err
+			}
+			_, involved, err := reducer.Resolve(repos, reduceopts.in, reduceopts.baseSystem, EffectiveArchitectures(reduceopts.architectures), required, reduceopts.ignoreMissing, reduceopts.priorityMasking, fixer)
 			if err != nil {
 				return err
 			}
@@ -70,6 +80,9 @@ which allow reducing huge rpm repos to a smaller problem set for debugging, remo
 	reduceCmd.Flags().StringSliceVarP(&reduceopts.architectures, "arch", "a", []string{"x86_64"}, "target architectures; `noarch` will be automatically added")
 	reduceCmd.Flags().BoolVarP(&reduceopts.nobest, "nobest", "n", false, "allow picking versions which are not the newest")
 	reduceCmd.Flags().BoolVar(&reduceopts.ignoreMissing, "ignore-missing", false, "ignore missing packages")
+	reduceCmd.Flags().BoolVar(&reduceopts.priorityMasking, "priority-masking", false, "hide packages of repositories if a repository with a higher priority (lower value) contains a package with the same name, like dnf does")
+	reduceCmd.Flags().StringArrayVar(&reduceopts.fromRepo, "from-repo", []string{}, "only take the package from the given repository, in the form name=repo. Can be specified multiple times")
+	reduceCmd.Flags().StringArrayVar(&reduceopts.fixups, "fixups", []string{}, "fixups file which adds or removes provides, requires and conflicts of packages. Can be specified multiple times")
 	reduceCmd.Flags().StringArrayVarP(&reduceopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
 	// deprecated options
 	reduceCmd.Flags().StringVarP(&reduceopts.baseSystem, "fedora-base-system", "f", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
@@ -78,6 +91,7 @@ which allow reducing huge rpm repos to a smaller problem set for debugging, remo
 	reduceCmd.Flags().MarkShorthandDeprecated("nobest", "use --nobest instead")
 
 	repo.AddCacheHelperFlags(reduceCmd)
+	repo.AddRepoFilterFlags(reduceCmd)
 
 	return reduceCmd
 }
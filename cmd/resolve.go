@@ -26,7 +26,7 @@ func NewResolveCmd() *cobra.Command {
 			repos := &bazeldnf.Repositories{}
 			if len(resolvehelperopts.in) == 0 {
 				var err error
-				repos, err = repo.LoadRepoFiles(resolveopts.repofiles)
+				repos, err = repo.LoadRepoFilesWithFilters(resolveopts.repofiles)
 				if err != nil {
 					return err
 				}
@@ -36,7 +36,7 @@ func NewResolveCmd() *cobra.Command {
 				resolvehelperopts.baseSystem = ""
 			}
 
-			install, forceIgnored, err := resolve(repos, required)
+			install, forceIgnored, _, err := resolve(repos, required)
 			if err != nil {
 				return err
 			}
@@ -51,6 +51,7 @@ func NewResolveCmd() *cobra.Command {
 	resolveCmd.Flags().StringArrayVarP(&resolveopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
 
 	repo.AddCacheHelperFlags(resolveCmd)
+	repo.AddRepoFilterFlags(resolveCmd)
 	addResolveHelperFlags(resolveCmd)
 
 	return resolveCmd
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"slices"
+	"strings"
 
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/fixup"
 	"github.com/rmohr/bazeldnf/pkg/reducer"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpmdb"
 	"github.com/rmohr/bazeldnf/pkg/sat"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -19,6 +25,21 @@ type resolveHelperOpts struct {
 	ignoreMissing    bool
 	forceIgnoreRegex []string
 	onlyAllowRegex   []string
+	priorityMasking  bool
+	fromRepo         []string
+	prefer           []string
+	avoid            []string
+	constraints      []string
+	baseLockfile     string
+	baseImage        string
+	fixups           []string
+	licensePolicies  []string
+	// licenseReportOnly logs license policy violations instead of failing
+	licenseReportOnly bool
+	// locked contains the lock file whose packages keep their versions,
+	// except the unlocked ones
+	locked   *bazeldnf.Config
+	unlocked map[string]bool
 }
 
 var resolvehelperopts = resolveHelperOpts{}
@@ -30,27 +51,258 @@ func EffectiveArchitectures(architectures []string) []string {
 	return architectures
 }
 
-func resolve(repos *bazeldnf.Repositories, required []string) ([]*api.Package, []*api.Package, error) {
-	matched, involved, err := reducer.Resolve(repos, resolvehelperopts.in, resolvehelperopts.baseSystem, EffectiveArchitectures(resolvehelperopts.arch), required, resolvehelperopts.ignoreMissing)
+// parseFromRepo parses `name=repo` pairs and merges them into the pins from the repository files
+func parseFromRepo(repos *bazeldnf.Repositories, pairs []string) error {
+	for _, pair := range pairs {
+		name, repoName, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || repoName == "" {
+			return fmt.Errorf("invalid --from-repo value %q, expected name=repo", pair)
+		}
+		if repos.FromRepo == nil {
+			repos.FromRepo = map[string]string{}
+		}
+		repos.FromRepo[name] = repoName
+	}
+	return nil
+}
+
+// loadBase loads the RPMs of the base lock file for the given architecture and
+// the names of the packages they contain
+func loadBase(lockfile string, arch string) ([]*bazeldnf.RPM, []string, error) {
+	if lockfile == "" {
+		return nil, nil, nil
+	}
+	config, err := bazel.LoadLockFile(lockfile)
 	if err != nil {
 		return nil, nil, err
 	}
+	rpms, err := config.ArchRPMs(arch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load base lock file %s: %v", lockfile, err)
+	}
+	names := []string{}
+	for _, rpm := range rpms {
+		if rpm.Name == "" {
+			return nil, nil, fmt.Errorf("RPM %s in base lock file %s has no name", rpm.Id, lockfile)
+		}
+		names = append(names, rpm.Name)
+	}
+	return rpms, names, nil
+}
+
+// findByIntegrity looks up the packages of lock file RPMs by their integrity
+// and returns the names of the RPMs which are not available
+func findByIntegrity(rpms []*bazeldnf.RPM, involved []*api.Package) ([]*api.Package, []string) {
+	byIntegrity := map[string]*api.Package{}
+	for _, pkg := range involved {
+		integrity, err := pkg.Checksum.Integrity()
+		if err != nil {
+			continue
+		}
+		byIntegrity[integrity] = pkg
+	}
+	found := []*api.Package{}
+	missing := []string{}
+	for _, rpm := range rpms {
+		pkg, exists := byIntegrity[rpm.Integrity]
+		if !exists {
+			missing = append(missing, rpm.Name)
+			continue
+		}
+		found = append(found, pkg)
+	}
+	return found, missing
+}
+
+// findBase looks up the packages of the base lock file by their integrity
+func findBase(baseRPMs []*bazeldnf.RPM, involved []*api.Package) ([]*api.Package, error) {
+	base, missing := findByIntegrity(baseRPMs, involved)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("packages of the base lock file are not available in the repositories, the base lock file may need to be updated: %s", strings.Join(missing, ", "))
+	}
+	return base, nil
+}
+
+// lockedConstraints creates constraints which keep the packages of the lock
+// file at their locked versions. Unlocked packages are free to change.
+func lockedConstraints(config *bazeldnf.Config, unlocked map[string]bool, arch string, involved []*api.Package) ([]bazeldnf.Constraint, error) {
+	if config == nil {
+		return nil, nil
+	}
+	rpms, err := config.ArchRPMs(arch)
+	if err != nil {
+		return nil, err
+	}
+	pinned := []*bazeldnf.RPM{}
+	for _, rpm := range rpms {
+		if !unlocked[rpm.Name] {
+			pinned = append(pinned, rpm)
+		}
+	}
+	pkgs, missing := findByIntegrity(pinned, involved)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("locked packages are not available in the repositories anymore and have to be updated as well: %s", strings.Join(missing, ", "))
+	}
+
+	versions := map[string]string{}
+	ambiguous := map[string]bool{}
+	for _, pkg := range pkgs {
+		version := pkg.Version.String()
+		if existing, exists := versions[pkg.Name]; exists && existing != version {
+			ambiguous[pkg.Name] = true
+		}
+		versions[pkg.Name] = version
+	}
+	constraints := []bazeldnf.Constraint{}
+	for _, name := range sortedKeys(versions) {
+		if ambiguous[name] {
+			logrus.Warnf("Package %s is locked in several versions, its version is not kept.", name)
+			continue
+		}
+		constraints = append(constraints, bazeldnf.Constraint{
+			Name:   name,
+			Allow:  "= " + versions[name],
+			Reason: "locked, update it as well to unlock it",
+		})
+	}
+	return constraints, nil
+}
+
+// findInstalled looks up the packages installed in the base image in the
+// involved packages. Installed packages which are not available in the
+// repositories anymore are added to the involved packages, so that they can
+// satisfy requirements with what their rpm database headers provide.
+func findInstalled(installed []*api.Package, involved []*api.Package) ([]*api.Package, []*api.Package) {
+	byKey := map[api.PackageKey]*api.Package{}
+	for _, pkg := range involved {
+		byKey[normalizedKey(pkg)] = pkg
+	}
+	base := []*api.Package{}
+	for _, pkg := range installed {
+		if available, exists := byKey[normalizedKey(pkg)]; exists {
+			base = append(base, available)
+			continue
+		}
+		logrus.Debugf("Installed package %s is not available in the repositories, using the rpm database header", pkg.String())
+		involved = append(involved, pkg)
+		base = append(base, pkg)
+	}
+	return base, involved
+}
+
+// loadFixups creates a fixer for the built-in fixups and the fixups of the given files
+func loadFixups(files []string) (*fixup.Fixer, error) {
+	fixups, err := repo.LoadFixupsFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	return fixup.New(fixups.Fixups)
+}
+
+func normalizedKey(pkg *api.Package) api.PackageKey {
+	key := pkg.Key()
+	if key.Version.Epoch == "" {
+		key.Version.Epoch = "0"
+	}
+	return key
+}
+
+// resolve returns the packages to install, the force ignored packages and the
+// packages of the base lock file or base image. Packages of the base are
+// never part of the packages to install.
+func resolve(repos *bazeldnf.Repositories, required []string) ([]*api.Package, []*api.Package, []*api.Package, error) {
+	if len(resolvehelperopts.arch) == 0 {
+		return nil, nil, nil, fmt.Errorf("at least one architecture is required")
+	}
+	if err := parseFromRepo(repos, resolvehelperopts.fromRepo); err != nil {
+		return nil, nil, nil, err
+	}
+	baseRPMs, baseNames, err := loadBase(resolvehelperopts.baseLockfile, resolvehelperopts.arch[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var installed []*api.Package
+	if resolvehelperopts.baseImage != "" {
+		installed, err = rpmdb.ReadImage(resolvehelperopts.baseImage, resolvehelperopts.arch[0])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		logrus.Infof("Found %d installed packages in the base image.", len(installed))
+	}
+
+	fixer, err := loadFixups(append(repos.Fixups, resolvehelperopts.fixups...))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	policy, err := loadLicensePolicy(resolvehelperopts.licensePolicies)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	matched, involved, err := reducer.Resolve(repos, resolvehelperopts.in, resolvehelperopts.baseSystem, EffectiveArchitectures(resolvehelperopts.arch), append(baseNames, required...), resolvehelperopts.ignoreMissing, resolvehelperopts.priorityMasking, fixer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	if len(matched) == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
+	}
+
+	base, err := findBase(baseRPMs, involved)
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	imageBase, involved := findInstalled(installed, involved)
+	base = append(base, imageBase...)
 
 	loader := sat.NewLoader()
+	loader.SetFromRepo(repos.FromRepo)
+	loader.SetPreferences(append(repos.Prefer, resolvehelperopts.prefer...), append(repos.Avoid, resolvehelperopts.avoid...))
+	constraints, err := repo.LoadConstraintsFiles(append(repos.Constraints, resolvehelperopts.constraints...))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	locked, err := lockedConstraints(resolvehelperopts.locked, resolvehelperopts.unlocked, resolvehelperopts.arch[0], involved)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	loader.SetConstraints(append(constraints.Constraints, locked...))
+	loader.SetInstalled(base)
+	loader.SetFixups(fixer)
 
 	logrus.Info("Loading involved packages into the resolver.")
 	model, err := loader.Load(involved, matched, resolvehelperopts.forceIgnoreRegex, resolvehelperopts.onlyAllowRegex, resolvehelperopts.nobest, EffectiveArchitectures(resolvehelperopts.arch))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	logrus.Info("Solving.")
 	install, _, forceIgnored, err := sat.Resolve(model)
-	return install, forceIgnored, err
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkLicensePolicy(policy, install, base, required); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(base) == 0 {
+		return install, forceIgnored, nil, nil
+	}
+
+	baseKeys := map[api.PackageKey]struct{}{}
+	for _, pkg := range base {
+		baseKeys[pkg.Key()] = struct{}{}
+	}
+	delta := []*api.Package{}
+	installedBase := []*api.Package{}
+	for _, pkg := range install {
+		if _, exists := baseKeys[pkg.Key()]; exists {
+			installedBase = append(installedBase, pkg)
+		} else {
+			delta = append(delta, pkg)
+		}
+	}
+	logrus.Infof("%d packages are already part of the base, %d packages are added.", len(installedBase), len(delta))
+	return delta, forceIgnored, installedBase, nil
 }
 
 func addResolveHelperFlags(cmd *cobra.Command) {
@@ -61,6 +313,16 @@ func addResolveHelperFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&resolvehelperopts.ignoreMissing, "ignore-missing", false, "ignore missing packages")
 	cmd.Flags().StringArrayVar(&resolvehelperopts.forceIgnoreRegex, "force-ignore-with-dependencies", []string{}, "Packages matching these regex patterns will not be installed. Allows force-removing unwanted dependencies. Be careful, this can lead to hidden missing dependencies.")
 	cmd.Flags().StringArrayVar(&resolvehelperopts.onlyAllowRegex, "only-allow", []string{}, "Packages matching these regex patterns may be installed. Allows scoping dependencies. Be careful, this can lead to hidden missing dependencies.")
+	cmd.Flags().BoolVar(&resolvehelperopts.priorityMasking, "priority-masking", false, "hide packages of repositories if a repository with a higher priority (lower value) contains a package with the same name, like dnf does")
+	cmd.Flags().StringArrayVar(&resolvehelperopts.fromRepo, "from-repo", []string{}, "only take the package from the given repository, in the form name=repo. Can be specified multiple times")
+	cmd.Flags().StringArrayVar(&resolvehelperopts.prefer, "prefer", []string{}, "prefer packages matching this package specification over other packages which provide the same resources. Can be specified multiple times")
+	cmd.Flags().StringArrayVar(&resolvehelperopts.avoid, "avoid", []string{}, "avoid installing packages matching this package specification if another package can satisfy the requirement. Can be specified multiple times")
+	cmd.Flags().StringVar(&resolvehelperopts.baseLockfile, "base-lockfile", "", "lock file of a base layer. Its packages are treated as installed and only the additional packages are written")
+	cmd.Flags().StringVar(&resolvehelperopts.baseImage, "base-image", "", "image tarball or OCI layout of a base image. The packages in its rpm database are treated as installed and only the additional packages are written")
+	cmd.Flags().StringArrayVar(&resolvehelperopts.constraints, "constraints", []string{}, "file with version constraints for packages. Can be specified multiple times")
+	cmd.Flags().StringArrayVar(&resolvehelperopts.fixups, "fixups", []string{}, "fixups file which adds or removes provides, requires and conflicts of packages. Can be specified multiple times")
+	cmd.Flags().StringArrayVar(&resolvehelperopts.licensePolicies, "license-policy", []string{}, "file with allowed and denied licenses. Packages violating it fail the resolution. Can be specified multiple times")
+	cmd.Flags().BoolVar(&resolvehelperopts.licenseReportOnly, "license-report-only", false, "only report license policy violations instead of failing")
 	// deprecated options
 	cmd.Flags().StringVarP(&resolvehelperopts.baseSystem, "fedora-base-system", "f", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
 	cmd.Flags().MarkDeprecated("fedora-base-system", "use --basesystem instead")
@@ -85,6 +85,11 @@ func Execute() {
 	rootCmd.AddCommand(NewTar2FilesCmd())
 	rootCmd.AddCommand(NewLddCmd())
 	rootCmd.AddCommand(NewVerifyCmd())
+	rootCmd.AddCommand(NewCreateRepoCmd())
+	rootCmd.AddCommand(NewDiffCmd())
+	rootCmd.AddCommand(NewOutdatedCmd())
+	rootCmd.AddCommand(NewSBOMCmd())
+	rootCmd.AddCommand(NewMigrateCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -4,11 +4,14 @@ import (
 	"archive/tar"
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/order"
 	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +22,8 @@ type rpm2tarOpts struct {
 	symlinks       map[string]string
 	capabilities   map[string]string
 	selinuxLabels  map[string]string
+	fileConflicts  string
+	installOrder   bool
 }
 
 var rpm2taropts = rpm2tarOpts{}
@@ -29,6 +34,10 @@ func NewRpm2TarCmd() *cobra.Command {
 		Short: "convert a rpm to a tar archive",
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			sortSymlinkKeys()
+			conflictMode := rpm.ConflictMode(rpm2taropts.fileConflicts)
+			if !slices.Contains(rpm.ConflictModes, conflictMode) {
+				return fmt.Errorf("invalid --file-conflicts value %q, expected one of %v", rpm2taropts.fileConflicts, rpm.ConflictModes)
+			}
 			rpmStream := os.Stdin
 			tarStream := os.Stdout
 			if rpm2taropts.output != "" {
@@ -48,8 +57,16 @@ func NewRpm2TarCmd() *cobra.Command {
 			tarWriter := tar.NewWriter(tarStream)
 			defer tarWriter.Close()
 			collector := rpm.NewCollector()
+			collector.SetConflictMode(conflictMode)
 			if len(rpm2taropts.input) != 0 {
-				directoryTree, err := order.TreeFromRPMs(rpm2taropts.input)
+				inputs := rpm2taropts.input
+				if rpm2taropts.installOrder {
+					inputs, err = sortByInstallOrder(inputs)
+					if err != nil {
+						return err
+					}
+				}
+				directoryTree, err := order.TreeFromRPMs(inputs)
 				if err != nil {
 					return err
 				}
@@ -79,7 +96,7 @@ func NewRpm2TarCmd() *cobra.Command {
 					}
 				}
 
-				for _, i := range rpm2taropts.input {
+				for _, i := range inputs {
 					rpmStream, err = os.Open(i)
 					if err != nil {
 						return fmt.Errorf("could not open rpm at %s: %v", i, err)
@@ -90,6 +107,9 @@ func NewRpm2TarCmd() *cobra.Command {
 						return fmt.Errorf("could not convert rpm at %s: %v", i, err)
 					}
 				}
+				if conflicts := collector.Conflicts(); len(conflicts) > 0 {
+					logrus.Warnf("Found %d file conflicts between packages.", len(conflicts))
+				}
 			} else {
 				err := collector.RPMToTar(rpmStream, tarWriter, false, cap, rpm2taropts.selinuxLabels)
 				if err != nil {
@@ -105,6 +125,8 @@ func NewRpm2TarCmd() *cobra.Command {
 	rpm2tarCmd.Flags().StringToStringVarP(&rpm2taropts.symlinks, "symlinks", "s", map[string]string{}, "symlinks to add. Relative or absolute.")
 	rpm2tarCmd.Flags().StringToStringVarP(&rpm2taropts.capabilities, "capabilities", "c", map[string]string{}, "capabilities of files (--capabilities=/bin/ls=cap_net_bind_service)")
 	rpm2tarCmd.Flags().StringToStringVar(&rpm2taropts.selinuxLabels, "selinux-labels", map[string]string{}, "selinux labels of files (--selinux-labels=/bin/ls=unconfined_u:object_r:default_t:s0)")
+	rpm2tarCmd.Flags().StringVar(&rpm2taropts.fileConflicts, "file-conflicts", string(rpm.ConflictModeWarn), "how to handle files which are contained in multiple RPMs with a different content or mode: warn (keep the first file), fail, or order (take the file of the last RPM)")
+	rpm2tarCmd.Flags().BoolVar(&rpm2taropts.installOrder, "install-order", false, "write the RPMs in the order rpm would install them instead of the order of the inputs")
 	// deprecated options
 	rpm2tarCmd.Flags().StringToStringVar(&rpm2taropts.capabilities, "capabilties", map[string]string{}, "capabilities of files (-c=/bin/ls=cap_net_bind_service)")
 	rpm2tarCmd.Flags().MarkDeprecated("capabilties", "use --capabilities instead")
@@ -113,6 +135,32 @@ func NewRpm2TarCmd() *cobra.Command {
 	return rpm2tarCmd
 }
 
+// sortByInstallOrder sorts the RPM files in the order rpm would install them
+func sortByInstallOrder(inputs []string) ([]string, error) {
+	pkgs := []*api.Package{}
+	files := map[*api.Package]string{}
+	for _, input := range inputs {
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, fmt.Errorf("could not open rpm at %s: %v", input, err)
+		}
+		pkg, provided, err := rpm.ReadPackage(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read rpm at %s: %v", input, err)
+		}
+		// rpm takes all files into account, not only the ones listed in primary.xml
+		pkg.Format.Files = provided
+		pkgs = append(pkgs, pkg)
+		files[pkg] = input
+	}
+	sorted := []string{}
+	for _, pkg := range order.InstallOrder(pkgs) {
+		sorted = append(sorted, files[pkg])
+	}
+	return sorted, nil
+}
+
 func sortSymlinkKeys() {
 	rpm2taropts.sortedSymlinks = make([]string, len(rpm2taropts.symlinks))
 	i := 0
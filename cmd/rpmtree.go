@@ -22,6 +22,7 @@ type rpmtreeOpts struct {
 	lockfile   string
 	name       string
 	public     bool
+	arches     []string
 }
 
 var rpmtreeopts = rpmtreeOpts{}
@@ -144,15 +145,13 @@ func NewRpmTreeCmd() *cobra.Command {
 		Short: "Writes a rpmtree rule and its rpmdependencies to bazel files",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, required []string) error {
-			repos, err := repo.LoadRepoFiles(rpmtreeopts.repofiles)
-			if err != nil {
+			if err := checkTargetArchFlags(cmd); err != nil {
 				return err
 			}
-			install, forceIgnored, err := resolve(repos, required)
+			repos, err := repo.LoadRepoFilesWithFilters(rpmtreeopts.repofiles)
 			if err != nil {
 				return err
 			}
-
 			handler, configname, err := newHandler()
 			if err != nil {
 				return err
@@ -162,7 +161,32 @@ func NewRpmTreeCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			bazel.AddTree(rpmtreeopts.name, configname, build, install, rpmtreeopts.public)
+			var install, forceIgnored []*api.Package
+			if len(rpmtreeopts.arches) > 0 {
+				// every architecture gets its own tree, the RPMs of all trees are
+				// written to the same file
+				seen := map[string]bool{}
+				err = resolveArches(repos, required, rpmtreeopts.arches, func(arch string, archInstall, archForceIgnored, _ []*api.Package) error {
+					bazel.AddTree(rpmtreeopts.name+"_"+arch, configname, build, archInstall, rpmtreeopts.public)
+					for _, pkg := range archInstall {
+						if !seen[pkg.String()] {
+							seen[pkg.String()] = true
+							install = append(install, pkg)
+						}
+					}
+					forceIgnored = append(forceIgnored, archForceIgnored...)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			} else {
+				install, forceIgnored, _, err = resolve(repos, required)
+				if err != nil {
+					return err
+				}
+				bazel.AddTree(rpmtreeopts.name, configname, build, install, rpmtreeopts.public)
+			}
 
 			if err := handler.Process(install, build); err != nil {
 				return err
@@ -194,9 +218,11 @@ func NewRpmTreeCmd() *cobra.Command {
 	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.configname, "configname", "rpms", "config name to use in lockfile")
 	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.lockfile, "lockfile", "", "lockfile for RPMs")
 	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.name, "name", "", "rpmtree rule name")
+	rpmtreeCmd.Flags().StringSliceVar(&rpmtreeopts.arches, "target-arch", []string{}, "resolve the packages separately for each of these architectures and write one rpmtree rule per architecture, named <name>_<arch>")
 	rpmtreeCmd.MarkFlagRequired("name")
 
 	repo.AddCacheHelperFlags(rpmtreeCmd)
+	repo.AddRepoFilterFlags(rpmtreeCmd)
 	addResolveHelperFlags(rpmtreeCmd)
 
 	return rpmtreeCmd
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/rmohr/bazeldnf/pkg/rpmdb"
+	"github.com/rmohr/bazeldnf/pkg/sbom"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type sbomOpts struct {
+	repofiles []string
+	lockfile  string
+	workspace string
+	fromMacro string
+	tar       string
+	rpms      []string
+	arch      string
+	output    string
+	name      string
+	namespace string
+}
+
+var sbomopts = sbomOpts{}
+
+func NewSBOMCmd() *cobra.Command {
+
+	sbomCmd := &cobra.Command{
+		Use:   "sbom",
+		Short: "Create a software bill of materials of locked or installed packages",
+		Long: `Create an SPDX 2.3 or CycloneDX 1.5 SBOM of the packages of a lock file,
+a WORKSPACE or macro, of RPM files, or of the packages installed in a root
+filesystem tar which contains an rpm database. The tars of rpmtree rules
+contain no rpm database, use the RPMs of the rpmtree for them. The SBOM
+contains package URLs, licenses, checksums, vendors and the dependencies
+between the packages.
+Metadata which is not part of the input, like the licenses of lock files
+without schema version 2, is taken from the cached repository metadata.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sbomopts.output != "spdx" && sbomopts.output != "cyclonedx" {
+				return fmt.Errorf("unsupported output format %s, supported are spdx and cyclonedx", sbomopts.output)
+			}
+			doc, err := loadSBOMDocument()
+			if err != nil {
+				return err
+			}
+			if sbomopts.output == "cyclonedx" {
+				return sbom.WriteCycloneDX(os.Stdout, doc)
+			}
+			return sbom.WriteSPDX(os.Stdout, doc)
+		},
+	}
+
+	sbomCmd.Flags().StringArrayVarP(&sbomopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file to look up missing package metadata. Can be specified multiple times")
+	sbomCmd.Flags().StringVar(&sbomopts.lockfile, "lockfile", "", "lock file with the packages")
+	sbomCmd.Flags().StringVarP(&sbomopts.workspace, "workspace", "w", "", "Bazel workspace file with the packages")
+	sbomCmd.Flags().StringVar(&sbomopts.fromMacro, "from-macro", "", "read the packages from a macro in the given bzl file. The expected format is: macroFile%defName")
+	sbomCmd.Flags().StringVar(&sbomopts.tar, "tar", "", "root filesystem tar with an rpm database, like a layer of an image built with dnf. The tars of rpmtree rules contain no rpm database")
+	sbomCmd.Flags().StringArrayVar(&sbomopts.rpms, "rpm", []string{}, "RPM file to describe, like the RPMs of an rpmtree rule. Can be specified multiple times")
+	sbomCmd.Flags().StringVar(&sbomopts.arch, "arch", "", "architecture to describe, required for multi architecture lock files")
+	sbomCmd.Flags().StringVarP(&sbomopts.output, "output", "o", "spdx", "output format, spdx or cyclonedx")
+	sbomCmd.Flags().StringVar(&sbomopts.name, "name", "", "name of the SBOM, defaults to the name of the input file")
+	sbomCmd.Flags().StringVar(&sbomopts.namespace, "namespace", "fedora", "namespace of the package URLs, usually the distribution")
+	repo.AddCacheHelperFlags(sbomCmd)
+	repo.AddRepoFilterFlags(sbomCmd)
+	return sbomCmd
+}
+
+// loadSBOMDocument reads the packages from the lock file, WORKSPACE, macro,
+// RPM files or tar. The lock file bazeldnf-lock.json is used if nothing is
+// given.
+func loadSBOMDocument() (*sbom.Document, error) {
+	sources := 0
+	for _, source := range []string{sbomopts.lockfile, sbomopts.workspace, sbomopts.fromMacro, sbomopts.tar} {
+		if source != "" {
+			sources++
+		}
+	}
+	if len(sbomopts.rpms) > 0 {
+		sources++
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("only one of --lockfile, --workspace, --from-macro, --rpm and --tar can be used")
+	}
+
+	var input string
+	var packages []*sbom.Package
+	var err error
+	switch {
+	case sbomopts.workspace != "":
+		input = sbomopts.workspace
+		workspace, err := bazel.LoadWorkspace(sbomopts.workspace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open workspace %s: %v", sbomopts.workspace, err)
+		}
+		packages, err = rpmRulePackages(bazel.GetWorkspaceRPMs(workspace))
+		if err != nil {
+			return nil, err
+		}
+	case sbomopts.fromMacro != "":
+		bzl, defname, err := bazel.ParseMacro(sbomopts.fromMacro)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse from-macro expression %q: %v", sbomopts.fromMacro, err)
+		}
+		input = bzl
+		bzlfile, err := bazel.LoadBzl(bzl)
+		if err != nil {
+			return nil, err
+		}
+		packages, err = rpmRulePackages(bazel.GetBzlfileRPMs(bzlfile, defname))
+		if err != nil {
+			return nil, err
+		}
+	case len(sbomopts.rpms) > 0:
+		input = sbomopts.rpms[0]
+		packages, err = rpmFilePackages(sbomopts.rpms)
+		if err != nil {
+			return nil, err
+		}
+	case sbomopts.tar != "":
+		input = sbomopts.tar
+		installed, err := rpmdb.ReadTar(sbomopts.tar)
+		if err != nil {
+			return nil, err
+		}
+		packages, err = sbomPackages(installed)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		input = sbomopts.lockfile
+		if input == "" {
+			input = "bazeldnf-lock.json"
+		}
+		packages, err = lockFilePackages(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := sbomCreationTime()
+	if err != nil {
+		return nil, err
+	}
+	name := sbomopts.name
+	if name == "" {
+		name = strings.Split(filepath.Base(input), ".")[0]
+	}
+	return &sbom.Document{
+		Name:      name,
+		Namespace: sbomopts.namespace,
+		Created:   created,
+		Packages:  packages,
+	}, nil
+}
+
+// sbomCreationTime honors SOURCE_DATE_EPOCH for reproducible SBOMs
+func sbomCreationTime() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Now(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", epoch, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// lockFilePackages creates the SBOM packages of a lock file. The dependencies
+// are the ones recorded in the lock file.
+func lockFilePackages(lockfile string) ([]*sbom.Package, error) {
+	config, err := bazel.LoadLockFile(lockfile)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Arches) > 0 && sbomopts.arch == "" {
+		return nil, fmt.Errorf("lock file %s contains several architectures, select one with --arch", lockfile)
+	}
+	rpms, err := config.ArchRPMs(sbomopts.arch)
+	if err != nil {
+		return nil, err
+	}
+
+	var cached map[string]*api.Package
+	if !config.HasMetadata() {
+		cached = cachedPackagesByChecksum(sbomopts.repofiles, sbomopts.arch)
+	}
+	byId := map[string]*sbom.Package{}
+	packages := []*sbom.Package{}
+	for _, rpm := range rpms {
+		checksum := sbom.SHA256FromIntegrity(rpm.Integrity)
+		var pkg *sbom.Package
+		if config.HasMetadata() {
+			pkg = &sbom.Package{
+				Name:      rpm.Name,
+				Epoch:     rpm.Epoch,
+				Version:   rpm.Version,
+				Release:   rpm.Release,
+				Arch:      rpm.Arch,
+				License:   rpm.License,
+				SourceRPM: rpm.SourceRPM,
+				Vendor:    rpm.Vendor,
+			}
+		} else if cachedPkg, exists := cached[checksum]; exists {
+			pkg = sbom.FromPackage(cachedPkg)
+		} else {
+			logrus.Warnf("Package %s is not in the cached repository metadata, its license is unknown", rpm.Name)
+			if len(rpm.URLs) == 0 {
+				return nil, fmt.Errorf("RPM %s has no URLs", rpm.Name)
+			}
+			if pkg, err = packageFromFileName(rpm.URLs[0]); err != nil {
+				return nil, err
+			}
+		}
+		pkg.SHA256 = checksum
+		if len(rpm.URLs) > 0 {
+			pkg.DownloadURL = downloadURL(config.Repositories[rpm.Repository], rpm.URLs[0])
+		}
+		id := rpm.Id
+		if id == "" {
+			// hand written lock files may not have ids
+			id = rpm.Name
+		}
+		byId[id] = pkg
+		packages = append(packages, pkg)
+	}
+	for _, rpm := range rpms {
+		id := rpm.Id
+		if id == "" {
+			id = rpm.Name
+		}
+		for _, dep := range rpm.Dependencies {
+			if depPkg, exists := byId[dep]; exists {
+				byId[id].Dependencies = append(byId[id].Dependencies, depPkg)
+			}
+		}
+	}
+	return packages, nil
+}
+
+// rpmRulePackages creates the SBOM packages of rpm() rules. Rules are matched
+// with the cached repository metadata by their sha256 to learn the metadata
+// and the dependencies of the packages.
+func rpmRulePackages(rules []*bazel.RPMRule) ([]*sbom.Package, error) {
+	cached := cachedPackagesByChecksum(sbomopts.repofiles, sbomopts.arch)
+	known := []*api.Package{}
+	unknown := []*sbom.Package{}
+	urls := map[*api.Package]string{}
+	for _, rule := range rules {
+		ruleURLs := rule.URLs()
+		if len(ruleURLs) == 0 {
+			return nil, fmt.Errorf("rpm rule %s has no URLs", rule.Name())
+		}
+		if pkg, exists := cached[rule.SHA256()]; exists {
+			known = append(known, pkg)
+			urls[pkg] = ruleURLs[0]
+			continue
+		}
+		logrus.Warnf("Package %s is not in the cached repository metadata, its license and dependencies are unknown", rule.Name())
+		pkg, err := packageFromFileName(ruleURLs[0])
+		if err != nil {
+			return nil, err
+		}
+		pkg.SHA256 = rule.SHA256()
+		pkg.DownloadURL = ruleURLs[0]
+		unknown = append(unknown, pkg)
+	}
+	packages, err := sbomPackages(known)
+	if err != nil {
+		return nil, err
+	}
+	for i, pkg := range known {
+		packages[i].DownloadURL = urls[pkg]
+	}
+	return append(packages, unknown...), nil
+}
+
+// rpmFilePackages creates the SBOM packages of RPM files. The metadata is read
+// from the RPM headers, the dependencies are the ones between the RPMs.
+func rpmFilePackages(files []string) ([]*sbom.Package, error) {
+	pkgs := []*api.Package{}
+	for _, file := range files {
+		pkg, err := readRPMFile(file)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return sbomPackages(pkgs)
+}
+
+// readRPMFile reads the header and the sha256 of a RPM file
+func readRPMFile(file string) (*api.Package, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not open rpm at %s: %v", file, err)
+	}
+	defer f.Close()
+
+	sha := sha256.New()
+	pkg, provided, err := rpm.ReadPackage(io.TeeReader(f, sha))
+	if err != nil {
+		return nil, fmt.Errorf("could not read rpm at %s: %v", file, err)
+	}
+	if _, err := io.Copy(sha, f); err != nil {
+		return nil, fmt.Errorf("could not read rpm at %s: %v", file, err)
+	}
+	// dependencies on files which are not listed in primary.xml are common
+	pkg.Format.Files = provided
+	pkg.Checksum = api.Checksum{Type: "sha256", Text: hex.EncodeToString(sha.Sum(nil))}
+	return pkg, nil
+}
+
+// sbomPackages creates the SBOM packages for the given packages, in the same
+// order, with the dependencies between them. Requirements which none of the
+// packages provide are skipped, they are usually satisfied by a base image.
+func sbomPackages(pkgs []*api.Package) ([]*sbom.Package, error) {
+	providers := collectProviders(pkgs)
+	byPackage := map[*api.Package]*sbom.Package{}
+	packages := []*sbom.Package{}
+	for _, pkg := range pkgs {
+		byPackage[pkg] = sbom.FromPackage(pkg)
+		packages = append(packages, byPackage[pkg])
+	}
+	for _, pkg := range pkgs {
+		requires := []string{}
+		for _, entry := range pkg.Format.Requires.Entries {
+			if _, exists := providers[entry.Name]; exists {
+				requires = append(requires, entry.Name)
+			}
+		}
+		deps, err := collectDependencies(pkg, requires, providers, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			byPackage[pkg].Dependencies = append(byPackage[pkg].Dependencies, byPackage[dep])
+		}
+	}
+	return packages, nil
+}
+
+// cachedPackagesByChecksum returns the packages of the cached repository
+// metadata by their sha256 checksum. The metadata of all architectures is
+// used if no architecture is given. Missing metadata is not an error, the
+// SBOM just contains less information.
+func cachedPackagesByChecksum(repofiles []string, arch string) map[string]*api.Package {
+	byChecksum := map[string]*api.Package{}
+	repos, err := repo.LoadRepoFilesWithFilters(repofiles)
+	if err != nil {
+		logrus.Warnf("Repository metadata is not available: %v", err)
+		return byChecksum
+	}
+	arches := []string{}
+	if arch != "" {
+		arches = append(arches, arch)
+	}
+	for _, r := range repos.Repositories {
+		if r.Arch != "" && arch == "" {
+			arches = append(arches, r.Arch)
+		}
+	}
+	primaries, err := repo.NewCacheHelper().CurrentPrimaries(repos, EffectiveArchitectures(arches))
+	if err != nil {
+		logrus.Warnf("Repository metadata is not available, run 'bazeldnf fetch' first: %v", err)
+		return byChecksum
+	}
+	for _, primary := range primaries {
+		for i := range primary.Repo.Packages {
+			pkg := &primary.Repo.Packages[i]
+			if pkg.Checksum.Type == "sha256" {
+				byChecksum[pkg.Checksum.Text] = pkg
+			}
+		}
+	}
+	return byChecksum
+}
+
+// packageFromFileName creates an SBOM package with the version and the
+// architecture from the RPM file name
+func packageFromFileName(u string) (*sbom.Package, error) {
+	key, err := rpm.ParseFileName(u)
+	if err != nil {
+		return nil, err
+	}
+	return &sbom.Package{Name: key.Name, Version: key.Version.Ver, Release: key.Version.Rel, Arch: key.Arch}, nil
+}
+
+// downloadURL returns the absolute URL of an RPM, lock files store the
+// location relative to the repository mirrors
+func downloadURL(mirrors []string, href string) string {
+	if u, err := url.Parse(href); err == nil && u.IsAbs() {
+		return href
+	}
+	if len(mirrors) == 0 {
+		return ""
+	}
+	u, err := url.Parse(mirrors[0])
+	if err != nil {
+		return ""
+	}
+	return u.JoinPath(href).String()
+}
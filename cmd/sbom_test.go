@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/sbom"
+)
+
+func TestSBOMPackages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	bash := newPackageWithDeps("bash", "glibc", "/bin/sh", "filesystem")
+	bash.Format.Files = []api.ProvidedFile{{Text: "/bin/sh"}}
+	glibc := newPackageWithDeps("glibc")
+
+	// filesystem is provided by the base image and not part of the SBOM
+	packages, err := sbomPackages([]*api.Package{bash, glibc})
+	g.Expect(err).Should(BeNil())
+	g.Expect(packages).Should(HaveLen(2))
+	g.Expect(packages[0].Name).Should(Equal("bash"))
+	g.Expect(packages[0].Dependencies).Should(Equal([]*sbom.Package{packages[1]}))
+	g.Expect(packages[1].Dependencies).Should(BeEmpty())
+}
+
+func TestLockFilePackages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	lockfile := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+	config := &bazeldnf.Config{
+		SchemaVersion: bazeldnf.SchemaVersion2,
+		Repositories:  map[string][]string{"fedora": {"https://example.com/fedora/44/"}},
+		RPMs: []*bazeldnf.RPM{
+			{
+				Id: "bash", Name: "bash", Integrity: "sha256-qr0JnQ==", Repository: "fedora",
+				URLs:         []string{"Packages/b/bash-5.2.37-1.fc44.x86_64.rpm"},
+				Dependencies: []string{"glibc"},
+				Epoch:        "0", Version: "5.2.37", Release: "1.fc44", Arch: "x86_64", License: "GPL-3.0-or-later",
+			},
+			{
+				Id: "glibc", Name: "glibc", Integrity: "sha256-qr0JnQ==", Repository: "fedora",
+				URLs:         []string{"Packages/g/glibc-2.41-3.fc44.x86_64.rpm"},
+				Dependencies: []string{},
+				Epoch:        "0", Version: "2.41", Release: "3.fc44", Arch: "x86_64", License: "LGPL-2.1-or-later",
+			},
+		},
+	}
+	g.Expect(bazel.WriteLockFile(config, lockfile)).To(Succeed())
+
+	packages, err := lockFilePackages(lockfile)
+	g.Expect(err).Should(BeNil())
+	g.Expect(packages).Should(HaveLen(2))
+	g.Expect(packages[0].NEVRA()).Should(Equal("bash-5.2.37-1.fc44.x86_64"))
+	g.Expect(packages[0].License).Should(Equal("GPL-3.0-or-later"))
+	g.Expect(packages[0].SHA256).Should(Equal("aabd099d"))
+	g.Expect(packages[0].DownloadURL).Should(Equal("https://example.com/fedora/44/Packages/b/bash-5.2.37-1.fc44.x86_64.rpm"))
+	g.Expect(packages[0].Dependencies).Should(Equal([]*sbom.Package{packages[1]}))
+}
+
+func TestDownloadURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(downloadURL([]string{"https://example.com/fedora"}, "Packages/b/bash.rpm")).Should(Equal("https://example.com/fedora/Packages/b/bash.rpm"))
+	g.Expect(downloadURL(nil, "https://example.com/bash.rpm")).Should(Equal("https://example.com/bash.rpm"))
+	g.Expect(downloadURL(nil, "Packages/b/bash.rpm")).Should(BeEmpty())
+}
+
+func TestRPMFilePackages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	packages, err := rpmFilePackages([]string{
+		"../pkg/repo/testdata/rpms/one-epoch-0.1-1.x86_64.rpm",
+		"../pkg/repo/testdata/rpms/simple-1.0.1-1.i386.rpm",
+	})
+	g.Expect(err).Should(BeNil())
+	g.Expect(packages).Should(HaveLen(2))
+	g.Expect(packages[0].NEVRA()).Should(Equal("one-epoch-1:0.1-1.x86_64"))
+	g.Expect(packages[0].SHA256).Should(Equal("f39544ab84ffb1506615d6e3558ffb9d1e924bbf786aa35ba2397c360697462e"))
+	g.Expect(packages[1].NEVRA()).Should(Equal("simple-1.0.1-1.i386"))
+
+	_, err = rpmFilePackages([]string{"../pkg/repo/testdata/repomd.xml"})
+	g.Expect(err).Should(MatchError(ContainSubstring("could not read rpm at ../pkg/repo/testdata/repomd.xml")))
+}
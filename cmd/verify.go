@@ -31,7 +31,7 @@ func NewVerifyCmd() *cobra.Command {
 		Short: "verify RPMs against gpg keys defined in repo.yaml",
 		Long:  `verify RPMs against gpg keys defined in repo.yaml`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			repos, err := repo.LoadRepoFiles(verifyopts.repofiles)
+			repos, err := repo.LoadRepoFilesWithFilters(verifyopts.repofiles)
 			if err != nil {
 				return err
 			}
@@ -85,6 +85,7 @@ func NewVerifyCmd() *cobra.Command {
 	}
 
 	verifyCmd.Flags().StringArrayVarP(&verifyopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file (can be specified multiple times)")
+	repo.AddRepoFilterFlags(verifyCmd)
 	verifyCmd.Flags().StringVarP(&verifyopts.workspace, "workspace", "w", "WORKSPACE", "Bazel workspace file")
 	verifyCmd.Flags().StringVarP(&verifyopts.fromMacro, "from-macro", "", "", "Tells bazeldnf to read the RPMs from a macro in the given bzl file instead of the WORKSPACE file. The expected format is: macroFile%defName")
 	return verifyCmd
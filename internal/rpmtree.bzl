@@ -47,6 +47,12 @@ def _rpm2tar_impl(ctx):
             selinux_labels.append(k + "=" + v)
         args.add_joined("--selinux-labels", selinux_labels, join_with = ",")
 
+    if ctx.attr.file_conflicts != "warn":
+        args.add_all(["--file-conflicts", ctx.attr.file_conflicts])
+
+    if ctx.attr.install_order:
+        args.add("--install-order")
+
     all_rpms = []
 
     for target in ctx.attr.rpms:
@@ -109,6 +115,15 @@ _rpm2tar_attrs = {
     "symlinks": attr.string_dict(),
     "capabilities": attr.string_list_dict(),
     "selinux_labels": attr.string_list_dict(),
+    "file_conflicts": attr.string(
+        default = "warn",
+        values = ["warn", "fail", "order"],
+        doc = "How to handle files which are contained in multiple RPMs with a different content or mode",
+    ),
+    "install_order": attr.bool(
+        default = False,
+        doc = "Write the RPMs in the order rpm would install them instead of the order of `rpms`",
+    ),
     "out": attr.output(mandatory = True),
 }
 
@@ -12,6 +12,7 @@ import (
 const (
 	PrimaryFileType   = "primary"
 	FilelistsFileType = "filelists"
+	OtherFileType     = "other"
 )
 
 type URL struct {
@@ -176,6 +177,7 @@ type Entry struct {
 	Epoch string `xml:"epoch,attr"`
 	Ver   string `xml:"ver,attr"`
 	Rel   string `xml:"rel,attr"`
+	Pre   string `xml:"pre,attr,omitempty"`
 }
 
 func (e Entry) String() string {
@@ -351,26 +353,11 @@ type Repository struct {
 }
 
 type Filelists struct {
-	XMLName  xml.Name `xml:"filelists"`
-	Text     string   `xml:",chardata"`
-	Xmlns    string   `xml:"xmlns,attr"`
-	Packages string   `xml:"packages,attr"`
-	Package  []struct {
-		Text    string `xml:",chardata"`
-		Pkgid   string `xml:"pkgid,attr"`
-		Name    string `xml:"name,attr"`
-		Arch    string `xml:"arch,attr"`
-		Version struct {
-			Text  string `xml:",chardata"`
-			Epoch string `xml:"epoch,attr"`
-			Ver   string `xml:"ver,attr"`
-			Rel   string `xml:"rel,attr"`
-		} `xml:"version"`
-		File []struct {
-			Text string `xml:",chardata"`
-			Type string `xml:"type,attr"`
-		} `xml:"file"`
-	} `xml:"package"`
+	XMLName  xml.Name          `xml:"filelists"`
+	Text     string            `xml:",chardata"`
+	Xmlns    string            `xml:"xmlns,attr"`
+	Packages string            `xml:"packages,attr"`
+	Package  []FileListPackage `xml:"package"`
 }
 
 type FileListPackage struct {
@@ -385,3 +372,32 @@ type FileListPackage struct {
 func (p *FileListPackage) String() string {
 	return p.Name + "-" + p.Version.String()
 }
+
+type Otherdata struct {
+	XMLName  xml.Name       `xml:"otherdata"`
+	Text     string         `xml:",chardata"`
+	Xmlns    string         `xml:"xmlns,attr"`
+	Packages string         `xml:"packages,attr"`
+	Package  []OtherPackage `xml:"package"`
+}
+
+type OtherPackage struct {
+	Text      string           `xml:",chardata"`
+	Pkgid     string           `xml:"pkgid,attr"`
+	Name      string           `xml:"name,attr"`
+	Arch      string           `xml:"arch,attr"`
+	Version   Version          `xml:"version"`
+	Changelog []ChangelogEntry `xml:"changelog"`
+}
+
+func (p *OtherPackage) String() string {
+	return p.Name + "-" + p.Version.String()
+}
+
+// ChangelogEntry is an entry of the RPM changelog. The author usually ends
+// with the version the entry was written for, like `Jane Doe <jane@example.com> - 1.0-1`.
+type ChangelogEntry struct {
+	Text   string `xml:",chardata"`
+	Author string `xml:"author,attr"`
+	Date   int64  `xml:"date,attr"`
+}
@@ -1,5 +1,19 @@
 package bazeldnf
 
+import "fmt"
+
+const (
+	// SchemaVersion1 lock files only contain what is needed to download the
+	// RPMs and to build their dependency graph. Lock files without a schema
+	// version use this schema.
+	SchemaVersion1 = 1
+	// SchemaVersion2 lock files additionally contain the package metadata,
+	// like version, license and sizes
+	SchemaVersion2 = 2
+	// LatestSchemaVersion is the newest schema version bazeldnf can write
+	LatestSchemaVersion = SchemaVersion2
+)
+
 type RPM struct {
 	Id           string   `json:"id"`
 	Name         string   `json:"name"`
@@ -7,13 +21,81 @@ type RPM struct {
 	URLs         []string `json:"urls"`
 	Repository   string   `json:"repository"`
 	Dependencies []string `json:"dependencies"`
+
+	// The package metadata is only recorded by schema version 2 and newer
+	Epoch         string `json:"epoch,omitempty"`
+	Version       string `json:"version,omitempty"`
+	Release       string `json:"release,omitempty"`
+	Arch          string `json:"arch,omitempty"`
+	License       string `json:"license,omitempty"`
+	SourceRPM     string `json:"sourcerpm,omitempty"`
+	Vendor        string `json:"vendor,omitempty"`
+	Size          int    `json:"size,omitempty"`
+	InstalledSize int    `json:"installed-size,omitempty"`
+}
+
+// ArchConfig contains the packages resolved for one architecture of a multi
+// architecture lock file
+type ArchConfig struct {
+	RPMs []*RPM `json:"rpms"`
+	// NoarchDependencies contains the dependencies of the shared noarch RPMs
+	// which are part of this architecture, by RPM id
+	NoarchDependencies map[string][]string `json:"noarch-dependencies,omitempty"`
+	ForceIgnored       []string            `json:"ignored,omitempty"`
 }
 
 type Config struct {
+	// SchemaVersion is the version of the lock file format, it is omitted
+	// for schema version 1
+	SchemaVersion        int                 `json:"schema-version,omitempty"`
 	CommandLineArguments []string            `json:"cli-arguments,omitempty"`
 	Name                 string              `json:"name"`
 	Repositories         map[string][]string `json:"repositories"`
 	RPMs                 []*RPM              `json:"rpms"`
 	Targets              []string            `json:"targets,omitempty"`
 	ForceIgnored         []string            `json:"ignored,omitempty"`
+	FromRepo             map[string]string   `json:"from-repo,omitempty"`
+	// Noarch contains the noarch RPMs which are shared by all architectures of
+	// a multi architecture lock file. Their dependencies are architecture
+	// specific and stored in the architecture configs.
+	Noarch []*RPM                 `json:"noarch,omitempty"`
+	Arches map[string]*ArchConfig `json:"arches,omitempty"`
+}
+
+// Schema returns the schema version of the lock file
+func (c *Config) Schema() int {
+	if c.SchemaVersion == 0 {
+		return SchemaVersion1
+	}
+	return c.SchemaVersion
+}
+
+// HasMetadata returns true if the RPMs of the lock file contain the package
+// metadata
+func (c *Config) HasMetadata() bool {
+	return c.Schema() >= SchemaVersion2
+}
+
+// ArchRPMs returns the RPMs which were resolved for the given architecture.
+// Lock files without per architecture package lists only contain one
+// architecture, all their RPMs are returned.
+func (c *Config) ArchRPMs(arch string) ([]*RPM, error) {
+	if len(c.Arches) == 0 {
+		return c.RPMs, nil
+	}
+	archConfig, exists := c.Arches[arch]
+	if !exists {
+		return nil, fmt.Errorf("lock file contains no packages for architecture %s", arch)
+	}
+	rpms := append([]*RPM{}, archConfig.RPMs...)
+	for _, rpm := range c.Noarch {
+		dependencies, exists := archConfig.NoarchDependencies[rpm.Id]
+		if !exists {
+			continue
+		}
+		shared := *rpm
+		shared.Dependencies = dependencies
+		rpms = append(rpms, &shared)
+	}
+	return rpms, nil
 }
@@ -0,0 +1,19 @@
+package bazeldnf
+
+// Constraints restrict the versions of packages which may be installed
+type Constraints struct {
+	Constraints []Constraint `json:"constraints"`
+}
+
+// Constraint restricts the versions of all packages with the given name.
+// Allow and Exclude contain a comparison operator (<, <=, =, !=, >=, >) and
+// a version, like `< 6.9` or `>= 1:3.0.7-1`.
+type Constraint struct {
+	Name string `json:"name"`
+	// Allow only permits versions which match the expression
+	Allow string `json:"allow,omitempty"`
+	// Exclude forbids versions which match the expression
+	Exclude string `json:"exclude,omitempty"`
+	// Reason is reported when the constraint prevents finding a solution
+	Reason string `json:"reason,omitempty"`
+}
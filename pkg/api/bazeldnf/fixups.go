@@ -0,0 +1,32 @@
+package bazeldnf
+
+// Fixups change the metadata of packages from the repositories, to work
+// around packaging quirks of distributions
+type Fixups struct {
+	Fixups []Fixup `json:"fixups"`
+}
+
+// Fixup adds or removes provides, requires and conflicts of all packages
+// whose name matches Package. Added entries are resource names, optionally
+// followed by an operator and a version, like `python3 >= 3.9`. Removed
+// entries are matched by their name only.
+type Fixup struct {
+	// Name identifies the fixup. A fixup replaces the built-in fixup with the same name.
+	Name string `json:"name"`
+	// Package is a regular expression which has to match the whole package name
+	Package string `json:"package"`
+
+	AddProvides     []string `json:"add-provides,omitempty"`
+	RemoveProvides  []string `json:"remove-provides,omitempty"`
+	AddRequires     []string `json:"add-requires,omitempty"`
+	RemoveRequires  []string `json:"remove-requires,omitempty"`
+	AddConflicts    []string `json:"add-conflicts,omitempty"`
+	RemoveConflicts []string `json:"remove-conflicts,omitempty"`
+
+	// QuietConflicts doesn't log conflicts of matching packages, since they are expected
+	QuietConflicts bool `json:"quiet-conflicts,omitempty"`
+	// Disabled turns the fixup off, mostly useful to disable a built-in fixup
+	Disabled bool `json:"disabled,omitempty"`
+	// Reason documents why the fixup is needed
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,13 @@
+package bazeldnf
+
+// LicensePolicy restricts the licenses of the packages which may be installed.
+// Allow and Deny contain SPDX license expressions, like `GPL-3.0-only` or
+// `AGPL-*`. If nothing is allowed explicitly, all licenses which are not
+// denied are allowed.
+type LicensePolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	// AllowNonSPDX permits licenses which are not SPDX expressions, like the
+	// license names Fedora used before Fedora 38
+	AllowNonSPDX bool `json:"allow-non-spdx,omitempty"`
+}
@@ -2,6 +2,16 @@ package bazeldnf
 
 type Repositories struct {
 	Repositories []Repository `json:"repositories"`
+	// FromRepo restricts the candidates of package names to a single repository
+	FromRepo map[string]string `json:"from-repo,omitempty"`
+	// Prefer and Avoid contain package specifications which are preferred or
+	// avoided when more than one package can satisfy a requirement
+	Prefer []string `json:"prefer,omitempty"`
+	Avoid  []string `json:"avoid,omitempty"`
+	// Constraints references constraint files, relative to the repository file
+	Constraints []string `json:"constraints,omitempty"`
+	// Fixups references fixup files, relative to the repository file
+	Fixups []string `json:"fixups,omitempty"`
 }
 
 type Repository struct {
@@ -14,4 +24,20 @@ type Repository struct {
 	GPGKey   string   `json:"gpgkey,omitempty"`
 	Priority int      `json:"priority,omitempty"`
 	Exclude  []string `json:"exclude,omitempty"`
+	// IncludePkgs restricts the packages of the repository to the ones matching
+	// at least one of the regular expressions
+	IncludePkgs []string `json:"includepkgs,omitempty"`
+}
+
+// DefaultPriority is the priority of repositories which don't specify one, like in dnf
+const DefaultPriority = 99
+
+// EffectivePriority returns the priority of the repository. Lower values
+// mean higher priority. Packages without a repository, like packages which are
+// installed in a base image, have the default priority.
+func (r *Repository) EffectivePriority() int {
+	if r == nil || r.Priority == 0 {
+		return DefaultPriority
+	}
+	return r.Priority
 }
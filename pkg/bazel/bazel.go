@@ -79,8 +79,28 @@ func WriteBzl(dryRun bool, bzl *build.File, path string) error {
 	return os.WriteFile(path, build.Format(bzl), 0644)
 }
 
+func LoadLockFile(path string) (*bazeldnf.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file %s: %v", path, err)
+	}
+	config := &bazeldnf.Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %v", path, err)
+	}
+	if config.Schema() > bazeldnf.LatestSchemaVersion {
+		return nil, fmt.Errorf("lock file %s has schema version %d, this bazeldnf only supports up to %d, update bazeldnf", path, config.Schema(), bazeldnf.LatestSchemaVersion)
+	}
+	return config, nil
+}
+
+// MarshalLockFile returns the content of the lock file as WriteLockFile writes it
+func MarshalLockFile(config *bazeldnf.Config) ([]byte, error) {
+	return json.MarshalIndent(config, "", "\t")
+}
+
 func WriteLockFile(config *bazeldnf.Config, path string) error {
-	configJson, err := json.MarshalIndent(config, "", "\t")
+	configJson, err := MarshalLockFile(config)
 	if err != nil {
 		return err
 	}
@@ -124,7 +144,7 @@ func AddWorkspaceRPMs(workspace *build.File, pkgs []*api.Package) error {
 	}
 
 	for _, pkg := range pkgs {
-		pkgName := pkgName(pkg)
+		pkgName := PackageName(pkg)
 		rule := rpms[pkgName]
 		if rule == nil {
 			call := &build.CallExpr{X: &build.Ident{Name: "rpm"}}
@@ -178,7 +198,7 @@ func AddBzlfileRPMs(bzlfile *build.File, defName string, pkgs []*api.Package) er
 	}
 
 	for _, pkg := range pkgs {
-		pkgName := pkgName(pkg)
+		pkgName := PackageName(pkg)
 		rule := rpms[pkgName]
 		if rule == nil {
 			call := &build.CallExpr{X: &build.Ident{Name: "rpm"}, ForceMultiLine: true}
@@ -278,6 +298,17 @@ func AddTree(name, configname string, buildfile *build.File, pkgs []*api.Package
 		}
 	}
 
+	rpms := []string{}
+	for _, pkg := range pkgs {
+		pkgName := PackageName(pkg)
+		rpms = append(rpms, transform(pkgName))
+	}
+	SetTree(name, buildfile, rpms, public)
+}
+
+// SetTree sets the RPM labels of the rpmtree rule with the given name, the
+// rule is created if it doesn't exist
+func SetTree(name string, buildfile *build.File, rpms []string, public bool) {
 	rpmtrees := map[string]*rpmTree{}
 
 	for _, rule := range buildfile.Rules("rpmtree") {
@@ -285,11 +316,7 @@ func AddTree(name, configname string, buildfile *build.File, pkgs []*api.Package
 	}
 	buildfile.DelRules("rpmtree", "")
 
-	rpms := []string{}
-	for _, pkg := range pkgs {
-		pkgName := pkgName(pkg)
-		rpms = append(rpms, transform(pkgName))
-	}
+	rpms = append([]string{}, rpms...)
 	sort.SliceStable(rpms, func(i, j int) bool {
 		return rpms[i] < rpms[j]
 	})
@@ -526,7 +553,7 @@ func AddConfigRPMs(config *bazeldnf.Config, pkgs []*api.Package) error {
 		config.RPMs = append(
 			config.RPMs,
 			&bazeldnf.RPM{
-				Name:      pkgName(pkg),
+				Name:      PackageName(pkg),
 				Integrity: integrity,
 				URLs:      URLs,
 			},
@@ -536,7 +563,8 @@ func AddConfigRPMs(config *bazeldnf.Config, pkgs []*api.Package) error {
 	return nil
 }
 
-func pkgName(pkg *api.Package) string {
+// PackageName returns the name of the rpm rule or the lock file entry of a package
+func PackageName(pkg *api.Package) string {
 	return sanitize(fmt.Sprintf("%s-%s.%s", pkg.Name, pkg.Version.String(), pkg.Arch))
 }
 
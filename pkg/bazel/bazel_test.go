@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -129,6 +130,23 @@ func TestBuildfileWithRPMs(t *testing.T) {
 	}
 }
 
+func TestLoadLockFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+	config := &bazeldnf.Config{SchemaVersion: bazeldnf.LatestSchemaVersion, Name: "rpms", RPMs: []*bazeldnf.RPM{{Id: "bash", Name: "bash"}}}
+	g.Expect(WriteLockFile(config, path)).To(Succeed())
+	loaded, err := LoadLockFile(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loaded).To(Equal(config))
+
+	// lock files written by a newer bazeldnf may contain unknown fields
+	config.SchemaVersion = bazeldnf.LatestSchemaVersion + 1
+	g.Expect(WriteLockFile(config, path)).To(Succeed())
+	_, err = LoadLockFile(path)
+	g.Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("has schema version %d", bazeldnf.LatestSchemaVersion+1))))
+}
+
 func newPkg(name string, version string, repository *bazeldnf.Repository) *api.Package {
 	pkg := &api.Package{}
 	pkg.Name = name
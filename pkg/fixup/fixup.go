@@ -0,0 +1,164 @@
+package fixup
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed builtin.yaml
+var builtinFixups []byte
+
+var flags = map[string]string{
+	"<":  "LT",
+	"<=": "LE",
+	"=":  "EQ",
+	"==": "EQ",
+	">=": "GE",
+	">":  "GT",
+}
+
+// Fixer applies fixups to packages
+type Fixer struct {
+	fixups []*compiledFixup
+}
+
+type compiledFixup struct {
+	bazeldnf.Fixup
+	pkg          *regexp.Regexp
+	addProvides  []api.Entry
+	addRequires  []api.Entry
+	addConflicts []api.Entry
+}
+
+// BuiltinFixups returns the built-in fixups
+func BuiltinFixups() []bazeldnf.Fixup {
+	fixups := &bazeldnf.Fixups{}
+	if err := yaml.Unmarshal(builtinFixups, fixups); err != nil {
+		panic(fmt.Sprintf("invalid built-in fixups: %v", err))
+	}
+	return fixups.Fixups
+}
+
+// Builtin returns a fixer which only applies the built-in fixups
+func Builtin() *Fixer {
+	fixer, err := New(nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in fixups: %v", err))
+	}
+	return fixer
+}
+
+// New creates a fixer for the built-in fixups and the given fixups. Fixups
+// replace earlier fixups with the same name, the built-in ones included.
+func New(fixups []bazeldnf.Fixup) (*Fixer, error) {
+	merged := []bazeldnf.Fixup{}
+	for _, fixup := range append(BuiltinFixups(), fixups...) {
+		merged = slices.DeleteFunc(merged, func(existing bazeldnf.Fixup) bool {
+			return existing.Name == fixup.Name
+		})
+		merged = append(merged, fixup)
+	}
+
+	fixer := &Fixer{}
+	for _, fixup := range merged {
+		if fixup.Disabled {
+			continue
+		}
+		compiled, err := compile(fixup)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixup %s: %v", fixup.Name, err)
+		}
+		fixer.fixups = append(fixer.fixups, compiled)
+	}
+	return fixer, nil
+}
+
+func compile(fixup bazeldnf.Fixup) (*compiledFixup, error) {
+	pkg, err := regexp.Compile("^(?:" + fixup.Package + ")$")
+	if err != nil {
+		return nil, err
+	}
+	compiled := &compiledFixup{Fixup: fixup, pkg: pkg}
+	if compiled.addProvides, err = parseEntries(fixup.AddProvides); err != nil {
+		return nil, err
+	}
+	if compiled.addRequires, err = parseEntries(fixup.AddRequires); err != nil {
+		return nil, err
+	}
+	if compiled.addConflicts, err = parseEntries(fixup.AddConflicts); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// parseEntries parses entries like `name` or `name >= 1.0-1`
+func parseEntries(entries []string) ([]api.Entry, error) {
+	parsed := []api.Entry{}
+	for _, entry := range entries {
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			parsed = append(parsed, api.Entry{Name: fields[0]})
+		case 3:
+			flag, exists := flags[fields[1]]
+			if !exists {
+				return nil, fmt.Errorf("unknown operator %q in %q", fields[1], entry)
+			}
+			version := rpm.ParseVersion(fields[2])
+			parsed = append(parsed, api.Entry{Name: fields[0], Flags: flag, Epoch: version.Epoch, Ver: version.Ver, Rel: version.Rel})
+		default:
+			return nil, fmt.Errorf("expected a name, optionally followed by an operator and a version, but got %q", entry)
+		}
+	}
+	return parsed, nil
+}
+
+// Apply changes the package according to all matching fixups. Applying the
+// fixups more than once has no further effect.
+func (f *Fixer) Apply(p *api.Package) {
+	for _, fixup := range f.fixups {
+		if !fixup.pkg.MatchString(p.Name) {
+			continue
+		}
+		p.Format.Provides.Entries = fix(p.Format.Provides.Entries, fixup.RemoveProvides, fixup.addProvides)
+		p.Format.Requires.Entries = fix(p.Format.Requires.Entries, fixup.RemoveRequires, fixup.addRequires)
+		p.Format.Conflicts.Entries = fix(p.Format.Conflicts.Entries, fixup.RemoveConflicts, fixup.addConflicts)
+	}
+}
+
+// QuietConflicts returns true if conflicts of the package are expected and
+// should not be logged
+func (f *Fixer) QuietConflicts(p *api.Package) bool {
+	for _, fixup := range f.fixups {
+		if fixup.QuietConflicts && fixup.pkg.MatchString(p.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func fix(entries []api.Entry, remove []string, add []api.Entry) []api.Entry {
+	if len(remove) == 0 && len(add) == 0 {
+		return entries
+	}
+	var fixed []api.Entry
+	for _, entry := range entries {
+		if !slices.Contains(remove, entry.Name) {
+			fixed = append(fixed, entry)
+		}
+	}
+	for _, entry := range add {
+		if !slices.Contains(fixed, entry) {
+			fixed = append(fixed, entry)
+		}
+	}
+	return fixed
+}
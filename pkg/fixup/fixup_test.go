@@ -0,0 +1,107 @@
+package fixup
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func newPackage(name string, provides, requires, conflicts []api.Entry) *api.Package {
+	p := &api.Package{Name: name}
+	p.Format.Provides.Entries = provides
+	p.Format.Requires.Entries = requires
+	p.Format.Conflicts.Entries = conflicts
+	return p
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixups   []bazeldnf.Fixup
+		pkg      *api.Package
+		expected *api.Package
+		wantErr  string
+	}{
+		{
+			name:     "should apply the built-in platform-python fixup",
+			pkg:      newPackage("platform-python", nil, []api.Entry{{Name: "/usr/libexec/platform-python"}, {Name: "libc.so.6"}}, nil),
+			expected: newPackage("platform-python", []api.Entry{{Name: "/usr/libexec/platform-python"}}, []api.Entry{{Name: "libc.so.6"}}, nil),
+		},
+		{
+			name:     "should only apply fixups to packages matching the whole name",
+			pkg:      newPackage("platform-python-devel", nil, []api.Entry{{Name: "/usr/libexec/platform-python"}}, nil),
+			expected: newPackage("platform-python-devel", nil, []api.Entry{{Name: "/usr/libexec/platform-python"}}, nil),
+		},
+		{
+			name: "should disable built-in fixups",
+			fixups: []bazeldnf.Fixup{
+				{Name: "platform-python", Disabled: true},
+			},
+			pkg:      newPackage("platform-python", nil, []api.Entry{{Name: "/usr/libexec/platform-python"}}, nil),
+			expected: newPackage("platform-python", nil, []api.Entry{{Name: "/usr/libexec/platform-python"}}, nil),
+		},
+		{
+			name: "should add and remove versioned entries",
+			fixups: []bazeldnf.Fixup{
+				{
+					Name:            "broken",
+					Package:         "broken(-libs)?",
+					AddProvides:     []string{"broken-compat = 1:2.0-1"},
+					RemoveRequires:  []string{"missing"},
+					AddRequires:     []string{"present >= 3"},
+					AddConflicts:    []string{"other < 2"},
+					RemoveConflicts: []string{"legacy"},
+				},
+			},
+			pkg: newPackage("broken-libs", nil, []api.Entry{{Name: "missing"}}, []api.Entry{{Name: "legacy"}}),
+			expected: newPackage("broken-libs",
+				[]api.Entry{{Name: "broken-compat", Flags: "EQ", Epoch: "1", Ver: "2.0", Rel: "1"}},
+				[]api.Entry{{Name: "present", Flags: "GE", Ver: "3"}},
+				[]api.Entry{{Name: "other", Flags: "LT", Ver: "2"}},
+			),
+		},
+		{
+			name: "should reject unknown operators",
+			fixups: []bazeldnf.Fixup{
+				{Name: "broken", Package: "broken", AddRequires: []string{"present ~> 3"}},
+			},
+			wantErr: "invalid fixup broken: unknown operator",
+		},
+		{
+			name: "should reject invalid package patterns",
+			fixups: []bazeldnf.Fixup{
+				{Name: "broken", Package: "broken("},
+			},
+			wantErr: "invalid fixup broken",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			fixer, err := New(tt.fixups)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			fixer.Apply(tt.pkg)
+			g.Expect(tt.pkg).To(Equal(tt.expected))
+			// applying the fixups again changes nothing
+			fixer.Apply(tt.pkg)
+			g.Expect(tt.pkg).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestQuietConflicts(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fixer := Builtin()
+	g.Expect(fixer.QuietConflicts(&api.Package{Name: "fedora-release-container"})).To(BeTrue())
+	g.Expect(fixer.QuietConflicts(&api.Package{Name: "bash"})).To(BeFalse())
+
+	fixer, err := New([]bazeldnf.Fixup{{Name: "fedora-release", Package: "fedora-release-common", QuietConflicts: true}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(fixer.QuietConflicts(&api.Package{Name: "fedora-release-container"})).To(BeFalse())
+}
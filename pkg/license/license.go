@@ -0,0 +1,331 @@
+// Package license parses SPDX license expressions and checks them against
+// license policies.
+package license
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// licenseId matches license identifiers and license references
+	licenseId = regexp.MustCompile(`^(LicenseRef-|DocumentRef-[A-Za-z0-9.\-]+:LicenseRef-)?[A-Za-z0-9.\-]+\+?$`)
+	// licensePattern additionally allows `*` wildcards, like `AGPL-*`
+	licensePattern = regexp.MustCompile(`^[A-Za-z0-9.\-:*]+\+?$`)
+
+	// knownLicenses and knownExceptions map the lower case SPDX identifiers
+	// to the identifiers, SPDX identifiers are case insensitive
+	knownLicenses   = lowerCaseIndex(spdxLicenses)
+	knownExceptions = lowerCaseIndex(spdxExceptions)
+)
+
+func lowerCaseIndex(ids []string) map[string]string {
+	index := map[string]string{}
+	for _, id := range ids {
+		index[strings.ToLower(id)] = id
+	}
+	return index
+}
+
+// Expression is a parsed SPDX license expression. It is either a license
+// term, or two expressions combined with AND or OR.
+type Expression struct {
+	// Op is AND or OR for compound expressions and empty for license terms
+	Op          string
+	Left, Right *Expression
+	License     string
+	Exception   string
+}
+
+// Term is a license, optionally with an exception, like `GPL-2.0-only WITH Classpath-exception-2.0`
+type Term struct {
+	License   string
+	Exception string
+}
+
+func (t Term) String() string {
+	if t.Exception != "" {
+		return t.License + " WITH " + t.Exception
+	}
+	return t.License
+}
+
+// Parse parses an SPDX license expression. The licenses and exceptions have
+// to be on the SPDX lists or license references like `LicenseRef-Fedora-Public-Domain`,
+// they are returned with the case of the lists. The operators AND, OR and WITH
+// are case sensitive. So the license names Fedora used before switching to
+// SPDX, like `GPLv2+ and MIT` or `AGPLv3`, are rejected.
+func Parse(expression string) (*Expression, error) {
+	return parse(expression, licenseId, true)
+}
+
+// Valid returns true if the license is an SPDX license expression
+func Valid(expression string) bool {
+	_, err := Parse(expression)
+	return err == nil
+}
+
+// IsLicenseRef returns true for license references, like `LicenseRef-Fedora-Public-Domain`
+func IsLicenseRef(id string) bool {
+	return strings.HasPrefix(id, "LicenseRef-") || strings.HasPrefix(id, "DocumentRef-")
+}
+
+func parse(expression string, id *regexp.Regexp, known bool) (*Expression, error) {
+	p := &parser{
+		tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)),
+		id:     id,
+		known:  known,
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty license expression")
+	}
+	expr, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("invalid license expression %q: %v", expression, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid license expression %q: unexpected %q", expression, p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	id     *regexp.Regexp
+	// known requires the identifiers to be on the SPDX lists
+	known bool
+}
+
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) or() (*Expression, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.next() == "OR" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Expression{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (*Expression, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.next() == "AND" {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &Expression{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (*Expression, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end")
+	case token == "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	case isOperator(token) || !p.id.MatchString(token):
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	license, err := p.license(token)
+	if err != nil {
+		return nil, err
+	}
+	p.pos++
+	expr := &Expression{License: license}
+	if p.next() == "WITH" {
+		p.pos++
+		exception := p.next()
+		if isOperator(exception) || !p.id.MatchString(exception) {
+			return nil, fmt.Errorf("invalid exception %q", exception)
+		}
+		if p.known {
+			known, exists := knownExceptions[strings.ToLower(exception)]
+			if !exists {
+				return nil, fmt.Errorf("unknown SPDX exception %q", exception)
+			}
+			exception = known
+		}
+		p.pos++
+		expr.Exception = exception
+	}
+	return expr, nil
+}
+
+// license returns the SPDX identifier of a license, which may end with `+`
+func (p *parser) license(token string) (string, error) {
+	if !p.known || IsLicenseRef(token) {
+		return token, nil
+	}
+	id, plus := strings.CutSuffix(token, "+")
+	if known, exists := knownLicenses[strings.ToLower(token)]; exists {
+		// deprecated identifiers like GPL-2.0+ contain the +
+		return known, nil
+	}
+	known, exists := knownLicenses[strings.ToLower(id)]
+	if !exists {
+		return "", fmt.Errorf("unknown SPDX license %q", token)
+	}
+	if plus {
+		known += "+"
+	}
+	return known, nil
+}
+
+func isOperator(token string) bool {
+	return token == "AND" || token == "OR" || token == "WITH" || token == "(" || token == ")"
+}
+
+// Terms returns all license terms of the expression
+func (e *Expression) Terms() []Term {
+	if e.Op == "" {
+		return []Term{{License: e.License, Exception: e.Exception}}
+	}
+	return append(e.Left.Terms(), e.Right.Terms()...)
+}
+
+// Policy decides which licenses may be installed
+type Policy struct {
+	allow []Term
+	deny  []Term
+	// allowNonSPDX permits licenses which are not SPDX expressions
+	allowNonSPDX bool
+}
+
+// NewPolicy creates a policy from allowed and denied SPDX expressions. All
+// terms of the expressions are allowed or denied, their licenses and
+// exceptions may contain `*` wildcards. If nothing is allowed explicitly, all
+// licenses which are not denied are allowed.
+func NewPolicy(allow, deny []string, allowNonSPDX bool) (*Policy, error) {
+	policy := &Policy{allowNonSPDX: allowNonSPDX}
+	for _, entries := range []struct {
+		expressions []string
+		terms       *[]Term
+	}{{allow, &policy.allow}, {deny, &policy.deny}} {
+		for _, expression := range entries.expressions {
+			expr, err := parse(expression, licensePattern, false)
+			if err != nil {
+				return nil, err
+			}
+			*entries.terms = append(*entries.terms, expr.Terms()...)
+		}
+	}
+	return policy, nil
+}
+
+// Check returns an error explaining why the license is not permitted. For
+// `A OR B` one permitted license is enough, for `A AND B` both have to be
+// permitted.
+func (p *Policy) Check(license string) error {
+	expr, err := Parse(license)
+	if err != nil {
+		if p.allowNonSPDX {
+			return nil
+		}
+		return fmt.Errorf("license %q is not an SPDX expression", license)
+	}
+	if problems := p.check(expr); len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func (p *Policy) check(expr *Expression) []string {
+	switch expr.Op {
+	case "AND":
+		return append(p.check(expr.Left), p.check(expr.Right)...)
+	case "OR":
+		left := p.check(expr.Left)
+		if len(left) == 0 {
+			return nil
+		}
+		right := p.check(expr.Right)
+		if len(right) == 0 {
+			return nil
+		}
+		return append(left, right...)
+	}
+	term := Term{License: expr.License, Exception: expr.Exception}
+	if !p.permits(term) {
+		if p.denies(term) {
+			return []string{term.String() + " is denied"}
+		}
+		return []string{term.String() + " is not allowed"}
+	}
+	return nil
+}
+
+// permits checks a term. An allowed term with an exception overrides the
+// denial of its license, like allowing `GPL-2.0-only WITH Classpath-exception-2.0`
+// while denying `GPL-2.0-only`.
+func (p *Policy) permits(term Term) bool {
+	for _, allowed := range p.allow {
+		if allowed.Exception != "" && matches(allowed, term) {
+			return true
+		}
+	}
+	if p.denies(term) {
+		return false
+	}
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, allowed := range p.allow {
+		if matches(allowed, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) denies(term Term) bool {
+	for _, denied := range p.deny {
+		if matches(denied, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the term matches the pattern. A pattern without an
+// exception matches the license with any exception.
+func matches(pattern Term, term Term) bool {
+	if matched, _ := path.Match(pattern.License, term.License); !matched {
+		return false
+	}
+	if pattern.Exception == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern.Exception, term.Exception)
+	return matched
+}
@@ -0,0 +1,68 @@
+package license
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParse(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	expr, err := Parse("(MIT OR Apache-2.0) AND GPL-2.0-or-later WITH Classpath-exception-2.0")
+	g.Expect(err).Should(BeNil())
+	g.Expect(expr.Op).Should(Equal("AND"))
+	g.Expect(expr.Left.Op).Should(Equal("OR"))
+	g.Expect(expr.Terms()).Should(Equal([]Term{
+		{License: "MIT"},
+		{License: "Apache-2.0"},
+		{License: "GPL-2.0-or-later", Exception: "Classpath-exception-2.0"},
+	}))
+
+	// AND binds stronger than OR
+	expr, err = Parse("MIT OR BSD-3-Clause AND Zlib")
+	g.Expect(err).Should(BeNil())
+	g.Expect(expr.Op).Should(Equal("OR"))
+	g.Expect(expr.Right.Op).Should(Equal("AND"))
+
+	for _, valid := range []string{"LicenseRef-Fedora-Public-Domain", "GPL-2.0+", "LGPL-2.1+", "Apache-1.0+", "mit"} {
+		g.Expect(Valid(valid)).Should(BeTrue(), valid)
+	}
+	for _, invalid := range []string{"", "GPLv2+ and MIT", "MIT AND", "(MIT", "MIT)", "MIT WITH", "AGPL-*", "GPLv2", "GPLv3+", "AGPLv3", "GPL-2.0-only WITH Foo-exception"} {
+		g.Expect(Valid(invalid)).Should(BeFalse(), invalid)
+	}
+
+	// identifiers are case insensitive and returned like on the SPDX lists
+	expr, err = Parse("gpl-2.0-or-later WITH classpath-exception-2.0")
+	g.Expect(err).Should(BeNil())
+	g.Expect(expr.Terms()).Should(Equal([]Term{{License: "GPL-2.0-or-later", Exception: "Classpath-exception-2.0"}}))
+}
+
+func TestPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	policy, err := NewPolicy(nil, []string{"GPL-3.0-only", "AGPL-*"}, false)
+	g.Expect(err).Should(BeNil())
+	g.Expect(policy.Check("MIT AND GPL-2.0-or-later")).Should(Succeed())
+	g.Expect(policy.Check("GPL-3.0-only")).Should(MatchError("GPL-3.0-only is denied"))
+	g.Expect(policy.Check("AGPL-3.0-or-later AND MIT")).Should(MatchError("AGPL-3.0-or-later is denied"))
+	// one permitted alternative is enough
+	g.Expect(policy.Check("GPL-3.0-only OR MIT")).Should(Succeed())
+	// licenses which are not on the SPDX list can't bypass the denied ones
+	g.Expect(policy.Check("GPLv3+")).Should(MatchError(`license "GPLv3+" is not an SPDX expression`))
+	g.Expect(policy.Check("AGPLv3")).Should(MatchError(`license "AGPLv3" is not an SPDX expression`))
+	g.Expect(policy.Check("gpl-3.0-only")).Should(MatchError("GPL-3.0-only is denied"))
+	g.Expect(policy.Check("GPLv3 and MIT")).Should(MatchError(`license "GPLv3 and MIT" is not an SPDX expression`))
+
+	policy, err = NewPolicy([]string{"MIT OR Apache-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"}, []string{"GPL-2.0-only"}, true)
+	g.Expect(err).Should(BeNil())
+	g.Expect(policy.Check("Apache-2.0")).Should(Succeed())
+	g.Expect(policy.Check("GPL-2.0-only WITH Classpath-exception-2.0")).Should(Succeed())
+	g.Expect(policy.Check("GPL-2.0-only")).Should(MatchError("GPL-2.0-only is denied"))
+	g.Expect(policy.Check("MIT AND BSD-3-Clause")).Should(MatchError("BSD-3-Clause is not allowed"))
+	g.Expect(policy.Check("GPLv3 and MIT")).Should(Succeed())
+	g.Expect(policy.Check("GPLv2")).Should(Succeed())
+
+	_, err = NewPolicy([]string{"MIT AND"}, nil, false)
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -0,0 +1,233 @@
+package lockdiff
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+)
+
+// Kind describes how a locked package changed
+type Kind string
+
+const (
+	Added      Kind = "added"
+	Removed    Kind = "removed"
+	Upgraded   Kind = "upgraded"
+	Downgraded Kind = "downgraded"
+	// Rebuilt packages have the same file name but a different integrity
+	Rebuilt Kind = "rebuilt"
+	// DependenciesChanged packages only changed their dependencies
+	DependenciesChanged Kind = "dependencies changed"
+)
+
+// Kinds contains all kinds in the order they are reported
+var Kinds = []Kind{Added, Removed, Upgraded, Downgraded, Rebuilt, DependenciesChanged}
+
+// Locked is a locked RPM with its version and architecture. They are taken
+// from the package metadata if the lock file has it and from the file name
+// otherwise, which doesn't contain the epoch.
+type Locked struct {
+	*bazeldnf.RPM
+	Version api.Version
+	Arch    string
+}
+
+// NEVRA returns the name, version and architecture of the RPM, like
+// `name-[epoch:]version-release.arch`
+func (l *Locked) NEVRA() string {
+	return l.Name + "-" + l.EVR() + "." + l.Arch
+}
+
+// EVR returns the version and release of the RPM, with the epoch if it is
+// known and not 0
+func (l *Locked) EVR() string {
+	return rpm.EVR(l.Version)
+}
+
+// Change describes the difference of a package between two lock files
+type Change struct {
+	Kind Kind
+	Name string
+	// LockArch is the architecture of a multi architecture lock file the
+	// package was resolved for, empty for single architecture lock files
+	LockArch            string
+	Old                 *Locked
+	New                 *Locked
+	AddedDependencies   []string
+	RemovedDependencies []string
+	// Changelog contains the changelog entries of the new version which were
+	// written after the old version, newest first
+	Changelog []api.ChangelogEntry
+}
+
+// Compare returns the differences of the packages of two lock files, sorted
+// by name and lock file architecture
+func Compare(before, after *bazeldnf.Config) ([]*Change, error) {
+	beforeRPMs, err := lockedByArch(before)
+	if err != nil {
+		return nil, err
+	}
+	afterRPMs, err := lockedByArch(after)
+	if err != nil {
+		return nil, err
+	}
+
+	lockArches := map[string]bool{}
+	for arch := range beforeRPMs {
+		lockArches[arch] = true
+	}
+	for arch := range afterRPMs {
+		lockArches[arch] = true
+	}
+
+	changes := []*Change{}
+	for lockArch := range lockArches {
+		ids := map[string]bool{}
+		for id := range beforeRPMs[lockArch] {
+			ids[id] = true
+		}
+		for id := range afterRPMs[lockArch] {
+			ids[id] = true
+		}
+		for id := range ids {
+			change := compareLocked(beforeRPMs[lockArch][id], afterRPMs[lockArch][id])
+			if change == nil {
+				continue
+			}
+			change.LockArch = lockArch
+			changes = append(changes, change)
+		}
+	}
+	slices.SortFunc(changes, func(a, b *Change) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.LockArch, b.LockArch))
+	})
+	return changes, nil
+}
+
+func compareLocked(before, after *Locked) *Change {
+	switch {
+	case before == nil:
+		return &Change{Kind: Added, Name: after.Name, New: after}
+	case after == nil:
+		return &Change{Kind: Removed, Name: before.Name, Old: before}
+	}
+	change := &Change{Name: after.Name, Old: before, New: after}
+	for _, dependency := range after.Dependencies {
+		if !slices.Contains(before.Dependencies, dependency) {
+			change.AddedDependencies = append(change.AddedDependencies, dependency)
+		}
+	}
+	for _, dependency := range before.Dependencies {
+		if !slices.Contains(after.Dependencies, dependency) {
+			change.RemovedDependencies = append(change.RemovedDependencies, dependency)
+		}
+	}
+
+	beforeVersion, afterVersion := before.Version, after.Version
+	if beforeVersion.Epoch == "" || afterVersion.Epoch == "" {
+		// the epoch is unknown if a lock file has no metadata
+		beforeVersion.Epoch, afterVersion.Epoch = "", ""
+	}
+	versions := rpm.Compare(beforeVersion, afterVersion)
+	switch {
+	case versions < 0:
+		change.Kind = Upgraded
+	case versions > 0:
+		change.Kind = Downgraded
+	case before.Arch != after.Arch:
+		// only the architecture changed, like from noarch to x86_64
+		change.Kind = Upgraded
+	case before.Integrity != after.Integrity:
+		change.Kind = Rebuilt
+	case len(change.AddedDependencies) > 0 || len(change.RemovedDependencies) > 0:
+		change.Kind = DependenciesChanged
+	default:
+		return nil
+	}
+	return change
+}
+
+// lockedByArch returns the locked RPMs by id for each architecture of the
+// lock file. Single architecture lock files use the empty architecture.
+func lockedByArch(config *bazeldnf.Config) (map[string]map[string]*Locked, error) {
+	byArch := map[string][]*bazeldnf.RPM{"": config.RPMs}
+	if len(config.Arches) > 0 {
+		byArch = map[string][]*bazeldnf.RPM{}
+		for arch := range config.Arches {
+			rpms, err := config.ArchRPMs(arch)
+			if err != nil {
+				return nil, err
+			}
+			byArch[arch] = rpms
+		}
+	}
+
+	locked := map[string]map[string]*Locked{}
+	for arch, rpms := range byArch {
+		locked[arch] = map[string]*Locked{}
+		for _, r := range rpms {
+			key, err := rpm.LockedPackage(r)
+			if err != nil {
+				return nil, err
+			}
+			id := r.Id
+			if id == "" {
+				// hand written lock files may not have ids
+				id = r.Name
+			}
+			locked[arch][id] = &Locked{RPM: r, Version: key.Version, Arch: key.Arch}
+		}
+	}
+	return locked, nil
+}
+
+// ChangelogSince returns the changelog entries of the current version which
+// were written for versions newer than the given one. Changelogs are sorted
+// newest first and entries usually end their author with the version, like
+// `Jane Doe - 1.0-1`, mostly without the epoch. Entries without an epoch get
+// the epoch of the current version, until an entry has a higher version than
+// the entry before it, which means that the epoch was raised in between.
+// Epochs are ignored if the given version has none, like the versions of lock
+// files without metadata. Entries without a version are kept.
+func ChangelogSince(changelog []api.ChangelogEntry, since, current api.Version) []api.ChangelogEntry {
+	epoch := current.Epoch
+	if epoch == "" {
+		epoch = "0"
+	}
+	var newer *api.Version
+	entries := []api.ChangelogEntry{}
+	for _, entry := range changelog {
+		_, evr, found := cutLast(entry.Author, " - ")
+		if found && strings.TrimSpace(evr) != "" {
+			version := rpm.ParseVersion(strings.TrimSpace(evr))
+			switch {
+			case since.Epoch == "":
+				version.Epoch = ""
+			case version.Epoch != "":
+				epoch = version.Epoch
+			default:
+				if newer != nil && rpm.Compare(api.Version{Ver: version.Ver, Rel: version.Rel}, api.Version{Ver: newer.Ver, Rel: newer.Rel}) > 0 {
+					epoch = since.Epoch
+				}
+				version.Epoch = epoch
+			}
+			if rpm.Compare(version, since) <= 0 {
+				break
+			}
+			newer = &version
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
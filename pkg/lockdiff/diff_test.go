@@ -0,0 +1,161 @@
+package lockdiff
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func newRPM(name, file, integrity string, deps ...string) *bazeldnf.RPM {
+	return &bazeldnf.RPM{Id: name, Name: name, Integrity: integrity, URLs: []string{"https://example.com/Packages/" + file}, Repository: "fedora", Dependencies: deps}
+}
+
+func TestCompare(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	before := &bazeldnf.Config{RPMs: []*bazeldnf.RPM{
+		newRPM("bash", "bash-5.2.37-1.fc44.x86_64.rpm", "sha256-a", "glibc"),
+		newRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-b"),
+		newRPM("ncurses-libs", "ncurses-libs-6.5-5.fc44.x86_64.rpm", "sha256-c", "glibc"),
+		newRPM("tzdata", "tzdata-2025b-1.fc44.noarch.rpm", "sha256-d"),
+		newRPM("zlib-ng", "zlib-ng-2.2.4-1.fc44.x86_64.rpm", "sha256-e"),
+	}}
+	after := &bazeldnf.Config{RPMs: []*bazeldnf.RPM{
+		newRPM("bash", "bash-5.2.37-1.fc44.x86_64.rpm", "sha256-a", "glibc", "openssl-libs"),
+		newRPM("glibc", "glibc-2.42-1.fc44.x86_64.rpm", "sha256-f"),
+		newRPM("openssl-libs", "openssl-libs-3.5.0-2.fc44.x86_64.rpm", "sha256-g", "glibc"),
+		newRPM("tzdata", "tzdata-2025b-1.fc44.noarch.rpm", "sha256-h"),
+		newRPM("zlib-ng", "zlib-ng-2.2.3-1.fc44.x86_64.rpm", "sha256-i"),
+	}}
+
+	changes, err := Compare(before, after)
+	g.Expect(err).Should(BeNil())
+	kinds := map[string]Kind{}
+	for _, change := range changes {
+		kinds[change.Name] = change.Kind
+	}
+	g.Expect(kinds).Should(Equal(map[string]Kind{
+		"bash":         DependenciesChanged,
+		"glibc":        Upgraded,
+		"ncurses-libs": Removed,
+		"openssl-libs": Added,
+		"tzdata":       Rebuilt,
+		"zlib-ng":      Downgraded,
+	}))
+	g.Expect(changes[0].AddedDependencies).Should(Equal([]string{"openssl-libs"}))
+	g.Expect(changes[1].Old.NEVRA()).Should(Equal("glibc-2.41-3.fc44.x86_64"))
+	g.Expect(changes[1].New.NEVRA()).Should(Equal("glibc-2.42-1.fc44.x86_64"))
+
+	changes, err = Compare(before, before)
+	g.Expect(err).Should(BeNil())
+	g.Expect(changes).Should(BeEmpty())
+}
+
+func TestCompareMultiArch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	before := &bazeldnf.Config{Arches: map[string]*bazeldnf.ArchConfig{
+		"x86_64":  {RPMs: []*bazeldnf.RPM{newRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-a")}},
+		"aarch64": {RPMs: []*bazeldnf.RPM{newRPM("glibc", "glibc-2.41-3.fc44.aarch64.rpm", "sha256-b")}},
+	}}
+	after := &bazeldnf.Config{Arches: map[string]*bazeldnf.ArchConfig{
+		"x86_64":  {RPMs: []*bazeldnf.RPM{newRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-a")}},
+		"aarch64": {RPMs: []*bazeldnf.RPM{newRPM("glibc", "glibc-2.42-1.fc44.aarch64.rpm", "sha256-c")}},
+	}}
+
+	changes, err := Compare(before, after)
+	g.Expect(err).Should(BeNil())
+	g.Expect(changes).Should(HaveLen(1))
+	g.Expect(changes[0].LockArch).Should(Equal("aarch64"))
+	g.Expect(changes[0].Kind).Should(Equal(Upgraded))
+}
+
+func TestCompareEpochs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	withMetadata := func(file, epoch, version, release string) *bazeldnf.Config {
+		rpm := newRPM("libfoo", file, "sha256-"+epoch)
+		rpm.Epoch, rpm.Version, rpm.Release, rpm.Arch = epoch, version, release, "x86_64"
+		return &bazeldnf.Config{SchemaVersion: bazeldnf.SchemaVersion2, RPMs: []*bazeldnf.RPM{rpm}}
+	}
+
+	// the file names don't contain the epoch, it is raised with a lower version
+	changes, err := Compare(
+		withMetadata("libfoo-2.0-1.x86_64.rpm", "1", "2.0", "1"),
+		withMetadata("libfoo-1.0-1.x86_64.rpm", "2", "1.0", "1"),
+	)
+	g.Expect(err).Should(BeNil())
+	g.Expect(changes).Should(HaveLen(1))
+	g.Expect(changes[0].Kind).Should(Equal(Upgraded))
+	g.Expect(changes[0].Old.NEVRA()).Should(Equal("libfoo-1:2.0-1.x86_64"))
+	g.Expect(changes[0].New.NEVRA()).Should(Equal("libfoo-2:1.0-1.x86_64"))
+
+	// only the epoch changed
+	changes, err = Compare(
+		withMetadata("libfoo-1.0-1.x86_64.rpm", "1", "1.0", "1"),
+		withMetadata("libfoo-1.0-1.x86_64.rpm", "2", "1.0", "1"),
+	)
+	g.Expect(err).Should(BeNil())
+	g.Expect(changes).Should(HaveLen(1))
+	g.Expect(changes[0].Kind).Should(Equal(Upgraded))
+
+	// the epoch of lock files without metadata is unknown
+	changes, err = Compare(
+		&bazeldnf.Config{RPMs: []*bazeldnf.RPM{newRPM("libfoo", "libfoo-1.0-1.x86_64.rpm", "sha256-a")}},
+		withMetadata("libfoo-1.0-1.x86_64.rpm", "2", "1.0", "1"),
+	)
+	g.Expect(err).Should(BeNil())
+	g.Expect(changes).Should(HaveLen(1))
+	g.Expect(changes[0].Kind).Should(Equal(Rebuilt))
+}
+
+func TestChangelogSince(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	changelog := []api.ChangelogEntry{
+		{Author: "Jane Doe <jane@example.com> - 2.42-1", Text: "- Update to 2.42"},
+		{Author: "Release Engineering <releng@example.com>", Text: "- Rebuilt"},
+		{Author: "John Doe <john@example.com> - 2.41-4", Text: "- Fix CVE-2025-0001"},
+		{Author: "John Doe <john@example.com> - 2.41-3", Text: "- Fix build"},
+		{Author: "John Doe <john@example.com> - 2.41-2", Text: "- Older"},
+	}
+
+	entries := ChangelogSince(changelog, api.Version{Ver: "2.41", Rel: "3.fc44"}, api.Version{Epoch: "0", Ver: "2.42", Rel: "1.fc44"})
+	g.Expect(entries).Should(Equal(changelog[:3]))
+
+	// the epoch was raised to go back to an older upstream version
+	changelog = []api.ChangelogEntry{
+		{Author: "Jane Doe <jane@example.com> - 1.0-2", Text: "- Fix CVE-2025-0002"},
+		{Author: "Jane Doe <jane@example.com> - 1.0-1", Text: "- Go back to 1.0"},
+		{Author: "John Doe <john@example.com> - 2.0-1", Text: "- Update to 2.0"},
+	}
+	entries = ChangelogSince(changelog, api.Version{Epoch: "1", Ver: "2.0", Rel: "1"}, api.Version{Epoch: "2", Ver: "1.0", Rel: "2"})
+	g.Expect(entries).Should(Equal(changelog[:2]))
+	entries = ChangelogSince(changelog, api.Version{Epoch: "2", Ver: "1.0", Rel: "1"}, api.Version{Epoch: "2", Ver: "1.0", Rel: "2"})
+	g.Expect(entries).Should(Equal(changelog[:1]))
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	changes, err := Compare(
+		&bazeldnf.Config{RPMs: []*bazeldnf.RPM{newRPM("glibc", "glibc-2.41-3.fc44.x86_64.rpm", "sha256-a")}},
+		&bazeldnf.Config{RPMs: []*bazeldnf.RPM{newRPM("glibc", "glibc-2.42-1.fc44.x86_64.rpm", "sha256-b")}},
+	)
+	g.Expect(err).Should(BeNil())
+	changes[0].Changelog = []api.ChangelogEntry{{Author: "Jane Doe <jane@example.com> - 2.42-1", Date: 1760745600, Text: "- Update to 2.42"}}
+
+	out := &bytes.Buffer{}
+	g.Expect(WriteMarkdown(out, changes)).Should(Succeed())
+	g.Expect(out.String()).Should(Equal("### Upgraded (1)\n\n" +
+		"| Package | Old | New | Dependencies |\n" +
+		"|---|---|---|---|\n" +
+		"| glibc | `glibc-2.41-3.fc44.x86_64` | `glibc-2.42-1.fc44.x86_64` |  |\n" +
+		"\n<details><summary>Changelog of glibc</summary>\n\n```\n" +
+		"* Sat Oct 18 2025 Jane Doe <jane@example.com> - 2.42-1\n" +
+		"- Update to 2.42\n\n" +
+		"```\n\n</details>\n"))
+}
@@ -0,0 +1,159 @@
+package lockdiff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+var titles = map[Kind]string{
+	Added:               "Added",
+	Removed:             "Removed",
+	Upgraded:            "Upgraded",
+	Downgraded:          "Downgraded",
+	Rebuilt:             "Rebuilt",
+	DependenciesChanged: "Dependencies changed",
+}
+
+func byKind(changes []*Change) map[Kind][]*Change {
+	grouped := map[Kind][]*Change{}
+	for _, change := range changes {
+		grouped[change.Kind] = append(grouped[change.Kind], change)
+	}
+	return grouped
+}
+
+func (c *Change) label() string {
+	if c.LockArch == "" {
+		return c.Name
+	}
+	return c.Name + " (" + c.LockArch + ")"
+}
+
+func (c *Change) versions() string {
+	switch {
+	case c.Old == nil:
+		return c.New.NEVRA()
+	case c.New == nil:
+		return c.Old.NEVRA()
+	case c.Old.NEVRA() == c.New.NEVRA():
+		return c.New.NEVRA()
+	}
+	return c.Old.NEVRA() + " -> " + c.New.NEVRA()
+}
+
+func (c *Change) dependencies() string {
+	parts := []string{}
+	if len(c.AddedDependencies) > 0 {
+		parts = append(parts, "added "+strings.Join(c.AddedDependencies, ", "))
+	}
+	if len(c.RemovedDependencies) > 0 {
+		parts = append(parts, "removed "+strings.Join(c.RemovedDependencies, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func formatChangelogEntry(entry api.ChangelogEntry) (header string, lines []string) {
+	date := time.Unix(entry.Date, 0).UTC().Format("Mon Jan 02 2006")
+	return fmt.Sprintf("* %s %s", date, entry.Author), strings.Split(strings.TrimSpace(entry.Text), "\n")
+}
+
+// WriteText writes the changes grouped by their kind as plain text
+func WriteText(w io.Writer, changes []*Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+	grouped := byKind(changes)
+	first := true
+	for _, kind := range Kinds {
+		if len(grouped[kind]) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "%s:\n", titles[kind])
+		for _, change := range grouped[kind] {
+			if change.LockArch != "" {
+				fmt.Fprintf(w, "  %s: %s\n", change.LockArch, change.versions())
+			} else {
+				fmt.Fprintf(w, "  %s\n", change.versions())
+			}
+			if dependencies := change.dependencies(); dependencies != "" {
+				fmt.Fprintf(w, "    dependencies: %s\n", dependencies)
+			}
+			for _, entry := range change.Changelog {
+				header, lines := formatChangelogEntry(entry)
+				fmt.Fprintf(w, "    %s\n", header)
+				for _, line := range lines {
+					fmt.Fprintf(w, "      %s\n", line)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// WriteMarkdown writes the changes as markdown tables, suitable for pull
+// request descriptions. Changelogs are placed in collapsed sections.
+func WriteMarkdown(w io.Writer, changes []*Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No package changes.")
+		return err
+	}
+	grouped := byKind(changes)
+	first := true
+	for _, kind := range Kinds {
+		if len(grouped[kind]) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "### %s (%d)\n\n", titles[kind], len(grouped[kind]))
+		switch kind {
+		case Added, Removed:
+			fmt.Fprintln(w, "| Package | NEVRA | Dependencies |")
+			fmt.Fprintln(w, "|---|---|---|")
+		default:
+			fmt.Fprintln(w, "| Package | Old | New | Dependencies |")
+			fmt.Fprintln(w, "|---|---|---|---|")
+		}
+		for _, change := range grouped[kind] {
+			switch kind {
+			case Added:
+				fmt.Fprintf(w, "| %s | `%s` | %s |\n", change.label(), change.New.NEVRA(), markdownCell(strings.Join(change.New.Dependencies, ", ")))
+			case Removed:
+				fmt.Fprintf(w, "| %s | `%s` | %s |\n", change.label(), change.Old.NEVRA(), markdownCell(strings.Join(change.Old.Dependencies, ", ")))
+			default:
+				fmt.Fprintf(w, "| %s | `%s` | `%s` | %s |\n", change.label(), change.Old.NEVRA(), change.New.NEVRA(), markdownCell(change.dependencies()))
+			}
+		}
+		for _, change := range grouped[kind] {
+			if len(change.Changelog) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\n<details><summary>Changelog of %s</summary>\n\n```\n", change.label())
+			for _, entry := range change.Changelog {
+				header, lines := formatChangelogEntry(entry)
+				fmt.Fprintln(w, header)
+				for _, line := range lines {
+					fmt.Fprintln(w, line)
+				}
+				fmt.Fprintln(w)
+			}
+			fmt.Fprint(w, "```\n\n</details>\n")
+		}
+	}
+	return nil
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,217 @@
+package order
+
+import (
+	"slices"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/sirupsen/logrus"
+)
+
+// relation is a requirement of a package on another package of the same set.
+// pre is set if the requirement is needed by a scriptlet, like
+// `Requires(pre)` or `Requires(post)`.
+type relation struct {
+	pre bool
+}
+
+// InstallOrder sorts packages in the order rpm would install them: required
+// packages are installed before the packages which require them. Dependency
+// loops are broken like rpm does, by dropping plain requirements before
+// requirements of scriptlets. Requirements on packages outside of the given
+// set are ignored. Packages which don't depend on each other keep their
+// relative order.
+func InstallOrder(pkgs []*api.Package) []*api.Package {
+	providers := map[string][]int{}
+	for i, pkg := range pkgs {
+		for _, entry := range pkg.Format.Provides.Entries {
+			providers[entry.Name] = append(providers[entry.Name], i)
+		}
+		for _, file := range pkg.Format.Files {
+			providers[file.Text] = append(providers[file.Text], i)
+		}
+	}
+
+	relations := make([]map[int]*relation, len(pkgs))
+	for i, pkg := range pkgs {
+		relations[i] = map[int]*relation{}
+		for _, entry := range pkg.Format.Requires.Entries {
+			for _, j := range providers[entry.Name] {
+				if i == j {
+					continue
+				}
+				if relations[i][j] == nil {
+					relations[i][j] = &relation{}
+				}
+				relations[i][j].pre = relations[i][j].pre || entry.Pre == "1"
+			}
+		}
+	}
+
+	sorted := []*api.Package{}
+	for _, component := range orderComponents(stronglyConnected(relations), relations) {
+		for _, i := range breakLoops(pkgs, component, relations) {
+			sorted = append(sorted, pkgs[i])
+		}
+	}
+	return sorted
+}
+
+// stronglyConnected returns the strongly connected components of the
+// relations with Tarjan's algorithm. Packages of a component depend on each
+// other in a loop.
+func stronglyConnected(relations []map[int]*relation) [][]int {
+	index := make([]int, len(relations))
+	lowlink := make([]int, len(relations))
+	onStack := make([]bool, len(relations))
+	for i := range index {
+		index[i] = -1
+	}
+	stack := []int{}
+	components := [][]int{}
+	next := 0
+
+	var connect func(v int)
+	connect = func(v int) {
+		index[v] = next
+		lowlink[v] = next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range sortedTargets(relations[v]) {
+			if index[w] == -1 {
+				connect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+		if lowlink[v] == index[v] {
+			component := []int{}
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			slices.Sort(component)
+			components = append(components, component)
+		}
+	}
+	for v := range relations {
+		if index[v] == -1 {
+			connect(v)
+		}
+	}
+	return components
+}
+
+// orderComponents sorts the components so that required components come
+// first. Of all components which can be installed next, the one containing
+// the earliest package is picked.
+func orderComponents(components [][]int, relations []map[int]*relation) [][]int {
+	componentOf := make([]int, len(relations))
+	for c, members := range components {
+		for _, i := range members {
+			componentOf[i] = c
+		}
+	}
+
+	// requires contains the components which have to be installed before a component
+	requires := make([]map[int]bool, len(components))
+	requiredBy := make([][]int, len(components))
+	for c, members := range components {
+		requires[c] = map[int]bool{}
+		for _, i := range members {
+			for _, j := range sortedTargets(relations[i]) {
+				d := componentOf[j]
+				if d != c && !requires[c][d] {
+					requires[c][d] = true
+					requiredBy[d] = append(requiredBy[d], c)
+				}
+			}
+		}
+	}
+
+	ready := []int{}
+	for c := range components {
+		if len(requires[c]) == 0 {
+			ready = append(ready, c)
+		}
+	}
+	sorted := [][]int{}
+	for len(ready) > 0 {
+		// the packages of the components are sorted, compare their first package
+		next := 0
+		for k := range ready {
+			if components[ready[k]][0] < components[ready[next]][0] {
+				next = k
+			}
+		}
+		c := ready[next]
+		ready = slices.Delete(ready, next, next+1)
+		sorted = append(sorted, components[c])
+		for _, d := range requiredBy[c] {
+			delete(requires[d], c)
+			if len(requires[d]) == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	return sorted
+}
+
+// breakLoops orders the packages of a component. The package with the least
+// unsatisfied requirements is installed next, preferring to break plain
+// requirements over requirements of scriptlets.
+func breakLoops(pkgs []*api.Package, component []int, relations []map[int]*relation) []int {
+	if len(component) == 1 {
+		return component
+	}
+	remaining := map[int]bool{}
+	for _, i := range component {
+		remaining[i] = true
+	}
+	sorted := []int{}
+	for len(remaining) > 0 {
+		best, bestPre, bestPlain := -1, 0, 0
+		for _, i := range component {
+			if !remaining[i] {
+				continue
+			}
+			pre, plain := 0, 0
+			for j, rel := range relations[i] {
+				if !remaining[j] {
+					continue
+				}
+				if rel.pre {
+					pre++
+				} else {
+					plain++
+				}
+			}
+			if best == -1 || pre < bestPre || pre == bestPre && plain < bestPlain {
+				best, bestPre, bestPlain = i, pre, plain
+			}
+		}
+		if bestPre > 0 {
+			logrus.Warnf("Breaking dependency loop of %s, %d scriptlet requirements are not installed before it", pkgs[best].String(), bestPre)
+		} else if bestPlain > 0 {
+			logrus.Debugf("Breaking dependency loop of %s, %d requirements are not installed before it", pkgs[best].String(), bestPlain)
+		}
+		sorted = append(sorted, best)
+		delete(remaining, best)
+	}
+	return sorted
+}
+
+func sortedTargets(relations map[int]*relation) []int {
+	targets := make([]int, 0, len(relations))
+	for j := range relations {
+		targets = append(targets, j)
+	}
+	slices.Sort(targets)
+	return targets
+}
@@ -0,0 +1,85 @@
+package order
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+// newPackage creates a package which provides its name and requires the
+// given resources. Requirements prefixed with `pre:` are scriptlet requirements.
+func newPackage(name string, requires ...string) *api.Package {
+	pkg := &api.Package{Name: name}
+	pkg.Format.Provides.Entries = []api.Entry{{Name: name}}
+	for _, req := range requires {
+		entry := api.Entry{Name: strings.TrimPrefix(req, "pre:")}
+		if strings.HasPrefix(req, "pre:") {
+			entry.Pre = "1"
+		}
+		pkg.Format.Requires.Entries = append(pkg.Format.Requires.Entries, entry)
+	}
+	return pkg
+}
+
+func names(pkgs []*api.Package) []string {
+	names := []string{}
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+func TestInstallOrder(t *testing.T) {
+	withFile := newPackage("bash")
+	withFile.Format.Files = []api.ProvidedFile{{Text: "/usr/bin/sh"}}
+
+	tests := []struct {
+		name     string
+		pkgs     []*api.Package
+		expected []string
+	}{
+		{
+			name:     "should install required packages first",
+			pkgs:     []*api.Package{newPackage("a", "b"), newPackage("b", "c"), newPackage("c")},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:     "should keep the order of independent packages",
+			pkgs:     []*api.Package{newPackage("z"), newPackage("a", "m"), newPackage("m")},
+			expected: []string{"z", "m", "a"},
+		},
+		{
+			name:     "should resolve file requirements and ignore packages outside of the set",
+			pkgs:     []*api.Package{newPackage("script", "/usr/bin/sh", "glibc"), withFile},
+			expected: []string{"bash", "script"},
+		},
+		{
+			name:     "should break plain requirements of loops before scriptlet requirements",
+			pkgs:     []*api.Package{newPackage("a", "pre:b"), newPackage("b", "a")},
+			expected: []string{"b", "a"},
+		},
+		{
+			name: "should install loops after their requirements",
+			pkgs: []*api.Package{
+				newPackage("app", "lib1"),
+				newPackage("lib1", "lib2", "base"),
+				newPackage("lib2", "pre:lib1"),
+				newPackage("base"),
+			},
+			expected: []string{"base", "lib1", "lib2", "app"},
+		},
+		{
+			name:     "should ignore requirements on itself",
+			pkgs:     []*api.Package{newPackage("a", "a")},
+			expected: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			g.Expect(names(InstallOrder(tt.pkgs))).To(Equal(tt.expected))
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package outdated
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/lockdiff"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+)
+
+var cvePattern = regexp.MustCompile(`CVE-[0-9]{4}-[0-9]{4,}`)
+
+// Locked is a locked RPM. The epoch of its version is empty if it is taken
+// from the file name.
+type Locked struct {
+	Name    string
+	Arch    string
+	Version api.Version
+}
+
+// Entry reports a locked package for which a newer version is available
+type Entry struct {
+	Name       string `json:"name"`
+	Arch       string `json:"arch"`
+	Current    string `json:"current"`
+	Latest     string `json:"latest"`
+	Repository string `json:"repository"`
+	// BlockedBy explains which requirements prevent updating the package on
+	// its own. The package can only be updated together with other packages.
+	BlockedBy []string `json:"blocked-by,omitempty"`
+	// CVEs contains the CVEs mentioned in the changelog since the current version
+	CVEs []string `json:"cves,omitempty"`
+
+	current api.Version
+	latest  *api.Package
+}
+
+// Blocked returns true if the package can't be updated on its own
+func (e *Entry) Blocked() bool {
+	return len(e.BlockedBy) > 0
+}
+
+// Security returns true if the update fixes CVEs
+func (e *Entry) Security() bool {
+	return len(e.CVEs) > 0
+}
+
+// LatestPackage returns the newest available package
+func (e *Entry) LatestPackage() *api.Package {
+	return e.latest
+}
+
+// SetChangelog records the CVEs which are mentioned in the changelog of the
+// latest version since the current version
+func (e *Entry) SetChangelog(changelog []api.ChangelogEntry) {
+	since, current := rpm.ParseVersion(e.Current), rpm.ParseVersion(e.Latest)
+	if e.latest != nil {
+		since, current = e.current, e.latest.Version
+	}
+	e.CVEs = nil
+	for _, entry := range lockdiff.ChangelogSince(changelog, since, current) {
+		for _, cve := range cvePattern.FindAllString(entry.Text, -1) {
+			if !slices.Contains(e.CVEs, cve) {
+				e.CVEs = append(e.CVEs, cve)
+			}
+		}
+	}
+	slices.Sort(e.CVEs)
+}
+
+// Check compares the locked packages with the available packages and returns
+// an entry for each locked package with a newer version, sorted by name and
+// architecture. An update is blocked if the newest version requires something
+// which neither the other locked packages nor a new package from the
+// repositories provide, or if other locked packages require something only
+// the current version provides. The requirements are checked for each
+// architecture of the locked packages on its own.
+func Check(locked []*Locked, available []*api.Package) []*Entry {
+	byName := map[string][]*api.Package{}
+	for _, pkg := range available {
+		byName[pkg.Name] = append(byName[pkg.Name], pkg)
+	}
+
+	// matched contains the available packages matching the locked ones
+	matched := []*api.Package{}
+	lockedNames := map[string]bool{}
+	arches := []string{}
+	for _, l := range locked {
+		lockedNames[l.Name] = true
+		if l.Arch != "noarch" && !slices.Contains(arches, l.Arch) {
+			arches = append(arches, l.Arch)
+		}
+		for _, pkg := range byName[l.Name] {
+			if pkg.Arch == l.Arch && sameVersion(pkg.Version, l.Version) {
+				matched = append(matched, pkg)
+				break
+			}
+		}
+	}
+	if len(arches) == 0 {
+		arches = append(arches, "noarch")
+	}
+	slices.Sort(arches)
+
+	// installed and addable contain the locked packages and the packages
+	// which are not locked yet per architecture, noarch packages belong to
+	// every architecture
+	installed := map[string][]*api.Package{}
+	addable := map[string][]*api.Package{}
+	for _, arch := range arches {
+		for _, pkg := range matched {
+			if compatibleArch(pkg.Arch, arch) {
+				installed[arch] = append(installed[arch], pkg)
+			}
+		}
+		for _, pkg := range available {
+			if !lockedNames[pkg.Name] && compatibleArch(pkg.Arch, arch) {
+				addable[arch] = append(addable[arch], pkg)
+			}
+		}
+	}
+
+	entries := []*Entry{}
+	for _, l := range locked {
+		var latest *api.Package
+		for _, pkg := range byName[l.Name] {
+			if pkg.Arch != l.Arch {
+				continue
+			}
+			if latest == nil || rpm.Compare(pkg.Version, latest.Version) > 0 {
+				latest = pkg
+			}
+		}
+		current := lockedVersion(l, matched)
+		if latest == nil || compareLocked(latest.Version, current) <= 0 {
+			continue
+		}
+		entry := &Entry{
+			Name:       l.Name,
+			Arch:       l.Arch,
+			Current:    rpm.EVR(current),
+			Latest:     rpm.EVR(latest.Version),
+			Repository: latest.Repository.Name,
+			current:    current,
+			latest:     latest,
+		}
+		for _, arch := range arches {
+			if !compatibleArch(l.Arch, arch) {
+				continue
+			}
+			for _, blocker := range blockers(l, latest, installed[arch], addable[arch]) {
+				if !slices.Contains(entry.BlockedBy, blocker) {
+					entry.BlockedBy = append(entry.BlockedBy, blocker)
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Arch, b.Arch))
+	})
+	return entries
+}
+
+// compatibleArch returns true if a package of the given architecture can be
+// installed on the target architecture
+func compatibleArch(arch string, target string) bool {
+	return arch == target || arch == "noarch" || target == "noarch"
+}
+
+// blockers returns the requirements which break if the locked package is
+// replaced by the latest version while all other packages stay. Requirements
+// which packages that are not locked yet provide don't block the update, the
+// resolver adds these packages. Newer versions of other locked packages
+// aren't considered, the update depends on their update then.
+func blockers(l *Locked, latest *api.Package, installed []*api.Package, addable []*api.Package) []string {
+	others := []*api.Package{}
+	var current *api.Package
+	for _, pkg := range installed {
+		if pkg.Name == l.Name && pkg.Arch == l.Arch {
+			current = pkg
+			continue
+		}
+		others = append(others, pkg)
+	}
+	after := append(slices.Clone(others), latest)
+
+	blocked := []string{}
+	for _, req := range latest.Format.Requires.Entries {
+		if ignoredRequirement(req) || providedBy(req, after) || providedBy(req, addable) {
+			continue
+		}
+		blocked = append(blocked, "requires "+formatEntry(req))
+	}
+	if current == nil {
+		return blocked
+	}
+	for _, pkg := range others {
+		for _, req := range pkg.Format.Requires.Entries {
+			if ignoredRequirement(req) || !providedBy(req, []*api.Package{current}) || providedBy(req, after) || providedBy(req, addable) {
+				continue
+			}
+			blocked = append(blocked, fmt.Sprintf("%s requires %s", pkg.Name, formatEntry(req)))
+		}
+	}
+	return blocked
+}
+
+// ignoredRequirement returns true for requirements which can't be checked
+// with the primary metadata, like rpmlib features and most files
+func ignoredRequirement(req api.Entry) bool {
+	return strings.HasPrefix(req.Name, "rpmlib(") || strings.HasPrefix(req.Name, "/")
+}
+
+func providedBy(req api.Entry, pkgs []*api.Package) bool {
+	for _, pkg := range pkgs {
+		for _, provide := range pkg.Format.Provides.Entries {
+			if provide.Name == req.Name && satisfies(req, provide) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// satisfies compares a versioned requirement with a provide, like the
+// resolver does. Versions without a release match all releases.
+func satisfies(req api.Entry, provide api.Entry) bool {
+	if req.Flags == "" || provide.Flags == "" {
+		return true
+	}
+	reqVer := api.Version{Epoch: req.Epoch, Ver: req.Ver, Rel: req.Rel}
+	provideVer := api.Version{Epoch: provide.Epoch, Ver: provide.Ver, Rel: provide.Rel}
+	if reqVer.Epoch == "" {
+		reqVer.Epoch = "0"
+	}
+	if provideVer.Epoch == "" {
+		provideVer.Epoch = "0"
+	}
+	if reqVer.Rel == "" || provideVer.Rel == "" {
+		reqVer.Rel = ""
+		provideVer.Rel = ""
+	}
+	c := rpm.Compare(provideVer, reqVer)
+	switch req.Flags {
+	case "EQ":
+		return c == 0
+	case "LE":
+		return c <= 0
+	case "GE":
+		return c >= 0
+	case "LT":
+		return c < 0
+	case "GT":
+		return c > 0
+	}
+	return false
+}
+
+// sameVersion compares the version of a package with a locked version, the
+// epoch is only compared if it is known
+func sameVersion(version api.Version, locked api.Version) bool {
+	if locked.Epoch != "" && cmp.Or(version.Epoch, "0") != locked.Epoch {
+		return false
+	}
+	return version.Ver == locked.Ver && version.Rel == locked.Rel
+}
+
+// lockedVersion returns the locked version with the epoch. File names don't
+// contain it, it is taken from the matching available package then.
+func lockedVersion(l *Locked, installed []*api.Package) api.Version {
+	version := l.Version
+	if version.Epoch != "" {
+		return version
+	}
+	for _, pkg := range installed {
+		if pkg.Name == l.Name && pkg.Arch == l.Arch {
+			version.Epoch = cmp.Or(pkg.Version.Epoch, "0")
+			break
+		}
+	}
+	return version
+}
+
+// compareLocked compares a version with a locked version, the epoch is only
+// compared if it is known
+func compareLocked(version api.Version, locked api.Version) int {
+	if locked.Epoch == "" {
+		version.Epoch = ""
+	} else {
+		version.Epoch = cmp.Or(version.Epoch, "0")
+	}
+	return rpm.Compare(version, locked)
+}
+
+func formatEntry(entry api.Entry) string {
+	operators := map[string]string{"EQ": "=", "LE": "<=", "GE": ">=", "LT": "<", "GT": ">"}
+	operator, exists := operators[entry.Flags]
+	if !exists {
+		return entry.Name
+	}
+	version := entry.Ver
+	if entry.Epoch != "" && entry.Epoch != "0" {
+		version = entry.Epoch + ":" + version
+	}
+	if entry.Rel != "" {
+		version += "-" + entry.Rel
+	}
+	return fmt.Sprintf("%s %s %s", entry.Name, operator, version)
+}
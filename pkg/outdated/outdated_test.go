@@ -0,0 +1,148 @@
+package outdated
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func newPackage(name, ver, rel string, requires ...api.Entry) *api.Package {
+	pkg := &api.Package{
+		Name:       name,
+		Arch:       "x86_64",
+		Version:    api.Version{Epoch: "0", Ver: ver, Rel: rel},
+		Repository: &bazeldnf.Repository{Name: "fedora"},
+	}
+	pkg.Format.Provides.Entries = []api.Entry{{Name: name, Flags: "EQ", Epoch: "0", Ver: ver, Rel: rel}}
+	pkg.Format.Requires.Entries = requires
+	return pkg
+}
+
+func TestCheck(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	locked := []*Locked{
+		{Name: "bash", Arch: "x86_64", Version: api.Version{Ver: "5.2.37", Rel: "1.fc44"}},
+		{Name: "openssl", Arch: "x86_64", Version: api.Version{Ver: "3.5.0", Rel: "2.fc44"}},
+		{Name: "openssl-libs", Arch: "x86_64", Version: api.Version{Ver: "3.5.0", Rel: "2.fc44"}},
+	}
+	requiresLibs := func(rel string) api.Entry {
+		return api.Entry{Name: "openssl-libs", Flags: "EQ", Epoch: "0", Ver: "3.5.0", Rel: rel}
+	}
+	available := []*api.Package{
+		newPackage("bash", "5.2.37", "1.fc44"),
+		newPackage("bash", "5.3.0", "1.fc44"),
+		newPackage("openssl", "3.5.0", "2.fc44", requiresLibs("2.fc44")),
+		newPackage("openssl", "3.5.0", "3.fc44", requiresLibs("3.fc44")),
+		newPackage("openssl-libs", "3.5.0", "2.fc44"),
+		newPackage("openssl-libs", "3.5.0", "3.fc44"),
+	}
+
+	entries := Check(locked, available)
+	g.Expect(entries).Should(HaveLen(3))
+	g.Expect(entries[0].Name).Should(Equal("bash"))
+	g.Expect(entries[0].Current).Should(Equal("5.2.37-1.fc44"))
+	g.Expect(entries[0].Latest).Should(Equal("5.3.0-1.fc44"))
+	g.Expect(entries[0].Blocked()).Should(BeFalse())
+	// the new openssl requires the new openssl-libs
+	g.Expect(entries[1].BlockedBy).Should(Equal([]string{"requires openssl-libs = 3.5.0-3.fc44"}))
+	// the locked openssl requires the old openssl-libs
+	g.Expect(entries[2].BlockedBy).Should(Equal([]string{"openssl requires openssl-libs = 3.5.0-2.fc44"}))
+}
+
+func TestCheckNewRequirements(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	locked := []*Locked{
+		{Name: "curl", Arch: "x86_64", Version: api.Version{Ver: "8.11.1", Rel: "1.fc44"}},
+		{Name: "wget", Arch: "x86_64", Version: api.Version{Ver: "1.25.0", Rel: "1.fc44"}},
+	}
+	available := []*api.Package{
+		newPackage("curl", "8.11.1", "1.fc44"),
+		newPackage("curl", "8.12.0", "1.fc44", api.Entry{Name: "libpsl"}),
+		newPackage("libpsl", "0.21.5", "1.fc44"),
+		newPackage("wget", "1.25.0", "1.fc44"),
+		newPackage("wget", "1.26.0", "1.fc44", api.Entry{Name: "libmetalink"}),
+	}
+
+	entries := Check(locked, available)
+	g.Expect(entries).Should(HaveLen(2))
+	// the new requirement is provided by a package which is not locked yet
+	g.Expect(entries[0].Name).Should(Equal("curl"))
+	g.Expect(entries[0].Blocked()).Should(BeFalse())
+	// no package of the repositories provides the new requirement
+	g.Expect(entries[1].Name).Should(Equal("wget"))
+	g.Expect(entries[1].BlockedBy).Should(Equal([]string{"requires libmetalink"}))
+}
+
+func TestCheckArchitectures(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newArchPackage := func(name, arch, rel string, requires ...api.Entry) *api.Package {
+		pkg := newPackage(name, "1.0", rel, requires...)
+		pkg.Arch = arch
+		return pkg
+	}
+	locked := []*Locked{
+		{Name: "tool", Arch: "x86_64", Version: api.Version{Ver: "1.0", Rel: "1.fc44"}},
+		{Name: "tool", Arch: "aarch64", Version: api.Version{Ver: "1.0", Rel: "1.fc44"}},
+		{Name: "libbar", Arch: "x86_64", Version: api.Version{Ver: "1.0", Rel: "1.fc44"}},
+		{Name: "tool-data", Arch: "noarch", Version: api.Version{Ver: "1.0", Rel: "1.fc44"}},
+	}
+	available := []*api.Package{
+		newArchPackage("tool", "x86_64", "1.fc44"),
+		newArchPackage("tool", "x86_64", "2.fc44", api.Entry{Name: "libbar"}),
+		newArchPackage("tool", "aarch64", "1.fc44"),
+		newArchPackage("tool", "aarch64", "2.fc44", api.Entry{Name: "libbar"}),
+		newArchPackage("libbar", "x86_64", "1.fc44"),
+		newArchPackage("tool-data", "noarch", "1.fc44"),
+		newArchPackage("tool-data", "noarch", "2.fc44", api.Entry{Name: "libbar"}),
+	}
+
+	entries := Check(locked, available)
+	g.Expect(entries).Should(HaveLen(3))
+	// the locked libbar of x86_64 doesn't provide the requirement for aarch64
+	g.Expect(entries[0].Name).Should(Equal("tool"))
+	g.Expect(entries[0].Arch).Should(Equal("aarch64"))
+	g.Expect(entries[0].BlockedBy).Should(Equal([]string{"requires libbar"}))
+	g.Expect(entries[1].Arch).Should(Equal("x86_64"))
+	g.Expect(entries[1].Blocked()).Should(BeFalse())
+	// noarch packages are checked for every architecture
+	g.Expect(entries[2].Name).Should(Equal("tool-data"))
+	g.Expect(entries[2].BlockedBy).Should(Equal([]string{"requires libbar"}))
+}
+
+func TestCheckEpochs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	raised := newPackage("libfoo", "1.0", "1.fc44")
+	raised.Version.Epoch = "2"
+	available := []*api.Package{newPackage("libfoo", "2.0", "1.fc44"), raised}
+
+	// the epoch of the file name is taken from the available package
+	entries := Check([]*Locked{{Name: "libfoo", Arch: "x86_64", Version: api.Version{Ver: "2.0", Rel: "1.fc44"}}}, available)
+	g.Expect(entries).Should(HaveLen(1))
+	g.Expect(entries[0].Current).Should(Equal("2.0-1.fc44"))
+	g.Expect(entries[0].Latest).Should(Equal("2:1.0-1.fc44"))
+
+	// the epoch of the lock file metadata
+	entries = Check([]*Locked{{Name: "libfoo", Arch: "x86_64", Version: api.Version{Epoch: "0", Ver: "2.0", Rel: "1.fc44"}}}, available)
+	g.Expect(entries).Should(HaveLen(1))
+	entries = Check([]*Locked{{Name: "libfoo", Arch: "x86_64", Version: api.Version{Epoch: "2", Ver: "1.0", Rel: "1.fc44"}}}, available)
+	g.Expect(entries).Should(BeEmpty())
+}
+
+func TestSetChangelog(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	entry := &Entry{Name: "glibc", Current: "2.41-3.fc44", Latest: "2.41-5.fc44"}
+	entry.SetChangelog([]api.ChangelogEntry{
+		{Author: "Jane Doe <jane@example.com> - 2.41-5", Text: "- Fix CVE-2025-4802 and CVE-2025-0395"},
+		{Author: "Jane Doe <jane@example.com> - 2.41-4", Text: "- Fix CVE-2025-4802 regression"},
+		{Author: "Jane Doe <jane@example.com> - 2.41-3", Text: "- Fix CVE-2024-2961"},
+	})
+	g.Expect(entry.Security()).Should(BeTrue())
+	g.Expect(entry.CVEs).Should(Equal([]string{"CVE-2025-0395", "CVE-2025-4802"}))
+}
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/fixup"
 	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
 )
 
 type ReducerPackageLoader interface {
@@ -31,6 +34,12 @@ type RepoLoader struct {
 	architectures []string
 	repos         *bazeldnf.Repositories
 	cacheHelper   repo.RepoCache
+	// priorityMasking hides all packages of a repository if a repository with
+	// a higher priority contains a package with the same name, like dnf does
+	priorityMasking bool
+	// fixer changes the metadata of packages with known quirks, the built-in
+	// fixups are used if it is not set
+	fixer *fixup.Fixer
 }
 
 func (r RepoLoader) Load() (*packageInfo, error) {
@@ -73,12 +82,32 @@ func (r RepoLoader) Load() (*packageInfo, error) {
 				logrus.Infof("Excluding %s", p.String())
 				continue
 			}
+			if included, err := include(&p, loaded.Spec); err != nil {
+				return nil, err
+			} else if !included {
+				logrus.Debugf("Not including %s", p.String())
+				continue
+			}
 			packageInfo.packages = append(packageInfo.packages, loaded.Repo.Packages[i])
 		}
 	}
 
+	if r.priorityMasking {
+		packageInfo.packages = maskByPriority(packageInfo.packages)
+	}
+
+	if r.repos != nil && len(r.repos.FromRepo) > 0 {
+		if packageInfo.packages, err = filterFromRepo(packageInfo.packages, r.repos); err != nil {
+			return nil, err
+		}
+	}
+
+	fixer := r.fixer
+	if fixer == nil {
+		fixer = fixup.Builtin()
+	}
 	for i, _ := range packageInfo.packages {
-		FixPackages(&packageInfo.packages[i])
+		fixer.Apply(&packageInfo.packages[i])
 	}
 
 	for i, p := range packageInfo.packages {
@@ -101,24 +130,6 @@ func (r RepoLoader) Load() (*packageInfo, error) {
 	return packageInfo, nil
 }
 
-// FixPackages contains hacks which should probably not have to exist
-func FixPackages(p *api.Package) {
-	// FIXME: This is not a proper modules support for python. We should properly resolve `alternative(python)` and
-	// not have to add such a hack. On the other hand this seems to have been reverted in fedora and only exists in centos stream.
-	if p.Name == "platform-python" {
-		p.Format.Provides.Entries = append(p.Format.Provides.Entries, api.Entry{
-			Name: "/usr/libexec/platform-python",
-		})
-		var requires []api.Entry
-		for _, entry := range p.Format.Requires.Entries {
-			if entry.Name != "/usr/libexec/platform-python" {
-				requires = append(requires, entry)
-			}
-		}
-		p.Format.Requires.Entries = requires
-	}
-}
-
 func skip(arch string, arches []string) bool {
 	skip := true
 	for _, a := range arches {
@@ -141,3 +152,99 @@ func exclude(p *api.Package, spec *bazeldnf.Repository) (bool, error) {
 	}
 	return false, nil
 }
+
+func include(p *api.Package, spec *bazeldnf.Repository) (bool, error) {
+	if len(spec.IncludePkgs) == 0 {
+		return true, nil
+	}
+	name := p.MatchableString()
+	for _, rex := range spec.IncludePkgs {
+		if match, err := regexp.MatchString(rex, name); err != nil {
+			return false, fmt.Errorf("failed to match package with regex '%v': %v", rex, err)
+		} else if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// maskByPriority removes all packages for which a package with the same name
+// exists in a repository with a higher priority. Packages which don't belong
+// to a repository are never masked.
+func maskByPriority(packages []api.Package) []api.Package {
+	best := map[string]int{}
+	for _, p := range packages {
+		if p.Repository == nil {
+			continue
+		}
+		if priority, exists := best[p.Name]; !exists || p.Repository.EffectivePriority() < priority {
+			best[p.Name] = p.Repository.EffectivePriority()
+		}
+	}
+	masked := []api.Package{}
+	for i, p := range packages {
+		if p.Repository != nil && p.Repository.EffectivePriority() > best[p.Name] {
+			logrus.Debugf("Masking %s because of a repository with higher priority", p.String())
+			continue
+		}
+		masked = append(masked, packages[i])
+	}
+	return masked
+}
+
+// filterFromRepo removes all candidates of pinned package names which don't
+// come from the repository they are pinned to
+func filterFromRepo(packages []api.Package, repos *bazeldnf.Repositories) ([]api.Package, error) {
+	for name, repoName := range repos.FromRepo {
+		if !slices.ContainsFunc(repos.Repositories, func(r bazeldnf.Repository) bool { return r.Name == repoName }) {
+			return nil, fmt.Errorf("package %s is pinned to unknown repository %s", name, repoName)
+		}
+	}
+	pointers := []*api.Package{}
+	for i := range packages {
+		pointers = append(pointers, &packages[i])
+	}
+	filtered, err := FilterFromRepo(pointers, repos.FromRepo)
+	if err != nil {
+		return nil, err
+	}
+	result := []api.Package{}
+	for _, p := range filtered {
+		result = append(result, *p)
+	}
+	return result, nil
+}
+
+// FilterFromRepo removes all candidates of pinned package names which don't
+// come from the repository they are pinned to. It fails if a pinned package
+// has candidates, but none of them is in the repository it is pinned to.
+func FilterFromRepo(packages []*api.Package, fromRepo map[string]string) ([]*api.Package, error) {
+	if len(fromRepo) == 0 {
+		return packages, nil
+	}
+	filtered := []*api.Package{}
+	rejected := map[string][]string{}
+	found := map[string]bool{}
+	for _, p := range packages {
+		repoName, pinned := fromRepo[p.Name]
+		if !pinned {
+			filtered = append(filtered, p)
+			continue
+		}
+		if p.Repository != nil && p.Repository.Name == repoName {
+			found[p.Name] = true
+			filtered = append(filtered, p)
+			continue
+		}
+		logrus.Debugf("Dropping %s because %s is pinned to repository %s", p.String(), p.Name, repoName)
+		rejected[p.Name] = append(rejected[p.Name], p.String())
+	}
+	names := maps.Keys(rejected)
+	slices.Sort(names)
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("package %s is pinned to repository %s, but it has no candidate there, available candidates are: %s", name, fromRepo[name], strings.Join(rejected[name], ", "))
+		}
+	}
+	return filtered, nil
+}
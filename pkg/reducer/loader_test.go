@@ -152,7 +152,7 @@ func TestLoaderManyRepoManyPackages(t *testing.T) {
 	g.Expect(len(packageInfo.provides)).Should(BeZero())
 }
 
-func TestLoaderFixPackages(t *testing.T) {
+func TestLoaderFixups(t *testing.T) {
 	g := NewGomegaWithT(t)
 	dep := "/usr/libexec/platform-python"
 
@@ -319,3 +319,110 @@ func TestLoaderRepositoryExcludes(t *testing.T) {
 	g.Expect(err).Should(BeNil())
 	g.Expect(packageInfo.packages).Should(ConsistOf(newPackageList("bir", "bar")))
 }
+
+func TestLoaderRepositoryIncludePkgs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	packageInfo, err := load(
+		t,
+		nil,
+		[]string{"x86_64"},
+		MockCacheHelper{
+			loaded: []repo.LoadedPrimary{
+				{
+					&bazeldnf.Repository{
+						IncludePkgs: []string{"^foo-", "^bar-"},
+					},
+					&api.Repository{
+						Packages: newPackageList("foo", "bir", "bar"),
+					},
+				},
+				{
+					&bazeldnf.Repository{},
+					&api.Repository{
+						Packages: newPackageList("bor"),
+					},
+				},
+			},
+		},
+	)
+
+	g.Expect(err).Should(BeNil())
+	g.Expect(packageInfo.packages).Should(ConsistOf(newPackageList("foo", "bar", "bor")))
+}
+
+func TestLoaderPriorityMasking(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	override := &bazeldnf.Repository{Name: "override", Priority: 10}
+	fedora := &bazeldnf.Repository{Name: "fedora"}
+	withRepo := func(spec *bazeldnf.Repository, packages []api.Package) []api.Package {
+		for i := range packages {
+			packages[i].Repository = spec
+		}
+		return packages
+	}
+	overridePackages := withRepo(override, newPackageList("foo"))
+	overridePackages[0].Version = api.Version{Ver: "1"}
+	fedoraPackages := withRepo(fedora, newPackageList("foo", "bar"))
+	fedoraPackages[0].Version = api.Version{Ver: "2"}
+
+	cacheHelper := MockCacheHelper{
+		loaded: []repo.LoadedPrimary{
+			{override, &api.Repository{Packages: overridePackages}},
+			{fedora, &api.Repository{Packages: fedoraPackages}},
+		},
+	}
+
+	loader := &RepoLoader{
+		architectures:   []string{"x86_64"},
+		cacheHelper:     cacheHelper,
+		priorityMasking: true,
+	}
+	packageInfo, err := loader.Load()
+	g.Expect(err).Should(BeNil())
+	g.Expect(packageInfo.packages).Should(ConsistOf(overridePackages[0], fedoraPackages[1]))
+
+	loader.priorityMasking = false
+	packageInfo, err = loader.Load()
+	g.Expect(err).Should(BeNil())
+	g.Expect(packageInfo.packages).Should(HaveLen(3))
+}
+
+func TestLoaderFromRepo(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	internal := &bazeldnf.Repository{Name: "internal"}
+	fedora := &bazeldnf.Repository{Name: "fedora"}
+	internalPackages := newPackageList("openssl")
+	internalPackages[0].Repository = internal
+	fedoraPackages := newPackageList("openssl", "bash")
+	for i := range fedoraPackages {
+		fedoraPackages[i].Repository = fedora
+	}
+
+	loader := &RepoLoader{
+		architectures: []string{"x86_64"},
+		repos: &bazeldnf.Repositories{
+			Repositories: []bazeldnf.Repository{*internal, *fedora},
+			FromRepo:     map[string]string{"openssl": "internal"},
+		},
+		cacheHelper: MockCacheHelper{
+			loaded: []repo.LoadedPrimary{
+				{Spec: internal, Repo: &api.Repository{Packages: internalPackages}},
+				{Spec: fedora, Repo: &api.Repository{Packages: fedoraPackages}},
+			},
+		},
+	}
+	packageInfo, err := loader.Load()
+	g.Expect(err).Should(BeNil())
+	g.Expect(packageInfo.packages).Should(ConsistOf(internalPackages[0], fedoraPackages[1]))
+
+	loader.repos.FromRepo = map[string]string{"bash": "internal"}
+	_, err = loader.Load()
+	g.Expect(err).Should(MatchError(ContainSubstring("package bash is pinned to repository internal, but it has no candidate there")))
+
+	loader.repos.FromRepo = map[string]string{"bash": "unknown"}
+	_, err = loader.Load()
+	g.Expect(err).Should(MatchError("package bash is pinned to unknown repository unknown"))
+}
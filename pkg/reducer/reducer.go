@@ -6,6 +6,7 @@ import (
 
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/fixup"
 	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/sirupsen/logrus"
 )
@@ -14,6 +15,8 @@ type RepoReducer struct {
 	packageInfo      *packageInfo
 	implicitRequires []string
 	loader           ReducerPackageLoader
+	// names maps package names to all packages with that name, in the order of the loaded packages
+	names map[string][]*api.Package
 }
 
 func (r *RepoReducer) Load() error {
@@ -22,6 +25,10 @@ func (r *RepoReducer) Load() error {
 		return err
 	}
 	r.packageInfo = packageInfo
+	r.names = map[string][]*api.Package{}
+	for i, p := range packageInfo.packages {
+		r.names[p.Name] = append(r.names[p.Name], &packageInfo.packages[i])
+	}
 	return nil
 }
 
@@ -29,47 +36,55 @@ func (r *RepoReducer) PackageCount() int {
 	return len(r.packageInfo.packages)
 }
 
-// Checks if user-provided string requesting top-level package matches given package.
+// PackageMatchesString checks if user-provided string requesting top-level package matches given package.
 // There are various possible matching methods:
 // - <package name>
 // - <package name>-<version> (version could be also any, possibly empty prefix of package's version)
 // - <package name>.<arch>
 // - <package name>-<version>.<arch> (needs full version)
-func packageMatchesString(pkg *api.Package, req string) bool {
+func PackageMatchesString(pkg *api.Package, req string) bool {
 	return req == pkg.Name ||
 		strings.HasPrefix(fmt.Sprintf("%s-%s", pkg.Name, pkg.Version.String()), req) && len(req) > len(pkg.Name) ||
 		req == fmt.Sprintf("%s.%s", pkg.Name, pkg.Arch) ||
 		req == fmt.Sprintf("%s.%s-%s", pkg.Name, pkg.Arch, pkg.Version.String())
 }
 
+// candidates returns all packages with the shortest name which match the
+// requested string. Since the package name is always a prefix of a matching
+// request, only the prefixes of the request have to be looked up.
+func (r *RepoReducer) candidates(req string) []*api.Package {
+	for i := 1; i <= len(req); i++ {
+		var candidates []*api.Package
+		for _, p := range r.names[req[:i]] {
+			if PackageMatchesString(p, req) {
+				candidates = append(candidates, p)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates
+		}
+	}
+	return nil
+}
+
 func (r *RepoReducer) Resolve(packages []string, ignoreMissing bool) (matched []string, involved []*api.Package, err error) {
 	packages = append(packages, r.implicitRequires...)
 	discovered := map[api.PackageKey]*api.Package{}
+	// order contains the keys of the discovered packages in the order they were discovered
+	order := []api.PackageKey{}
 	pinned := map[string]*api.Package{}
 	for _, req := range packages {
-		found := false
-		name := ""
-		var candidates []*api.Package
-		for i, p := range r.packageInfo.packages {
-			if packageMatchesString(&p, req) {
-				if !found || len(p.Name) < len(name) {
-					candidates = []*api.Package{&r.packageInfo.packages[i]}
-					name = p.Name
-					found = true
-				} else if p.Name == name {
-					candidates = append(candidates, &r.packageInfo.packages[i])
-				}
-			}
-		}
-		if !found && !ignoreMissing {
+		candidates := r.candidates(req)
+		if len(candidates) == 0 && !ignoreMissing {
 			return nil, nil, fmt.Errorf("Package %s does not exist", req)
 		}
 
 		for i, p := range candidates {
 			if selected, ok := discovered[p.Key()]; !ok {
 				discovered[p.Key()] = candidates[i]
+				order = append(order, p.Key())
 			} else {
-				if selected.Repository.Priority > p.Repository.Priority {
+				if selected.Repository.EffectivePriority() > p.Repository.EffectivePriority() {
 					discovered[p.Key()] = candidates[i]
 				}
 			}
@@ -84,33 +99,29 @@ func (r *RepoReducer) Resolve(packages []string, ignoreMissing bool) (matched []
 		pinned[v.Name] = v
 	}
 
-	for {
-		current := []api.PackageKey{}
-		for k := range discovered {
-			current = append(current, k)
-		}
-		for _, p := range current {
-			for _, newFound := range r.requires(discovered[p]) {
-				if _, exists := discovered[newFound.Key()]; !exists {
-					if _, exists := pinned[newFound.Name]; !exists {
-						discovered[newFound.Key()] = newFound
-					} else {
-						logrus.Debugf("excluding %s because of pinned dependency %s", newFound.String(), pinned[newFound.Name].String())
-					}
-				}
+	// the packages after the current position form the worklist of packages
+	// whose requirements were not looked at yet
+	for i := 0; i < len(order); i++ {
+		for _, newFound := range r.requires(discovered[order[i]]) {
+			if _, exists := discovered[newFound.Key()]; exists {
+				continue
 			}
-		}
-		if len(current) == len(discovered) {
-			break
+			if _, exists := pinned[newFound.Name]; exists {
+				logrus.Debugf("excluding %s because of pinned dependency %s", newFound.String(), pinned[newFound.Name].String())
+				continue
+			}
+			discovered[newFound.Key()] = newFound
+			order = append(order, newFound.Key())
 		}
 	}
 
 	required := map[string]struct{}{}
-	for i, pkg := range discovered {
+	for _, key := range order {
+		pkg := discovered[key]
 		for _, req := range pkg.Format.Requires.Entries {
 			required[req.Name] = struct{}{}
 		}
-		involved = append(involved, discovered[i])
+		involved = append(involved, pkg)
 	}
 	// remove all provides which are not required in the reduced set
 	for i, pkg := range involved {
@@ -129,11 +140,13 @@ func (r *RepoReducer) Resolve(packages []string, ignoreMissing bool) (matched []
 func (r *RepoReducer) requires(p *api.Package) (wants []*api.Package) {
 	for _, requires := range p.Format.Requires.Entries {
 		if val, exists := r.packageInfo.provides[requires.Name]; exists {
-			var packages []string
-			for _, p := range val {
-				packages = append(packages, p.Name)
+			if logrus.IsLevelEnabled(logrus.DebugLevel) {
+				var packages []string
+				for _, p := range val {
+					packages = append(packages, p.Name)
+				}
+				logrus.Debugf("%s wants %v because of %v\n", p.Name, packages, requires)
 			}
-			logrus.Debugf("%s wants %v because of %v\n", p.Name, packages, requires)
 			wants = append(wants, val...)
 		} else {
 			logrus.Debugf("%s requires %v which can't be satisfied\n", p.Name, requires)
@@ -143,7 +156,7 @@ func (r *RepoReducer) requires(p *api.Package) (wants []*api.Package) {
 	return wants
 }
 
-func NewRepoReducer(repos *bazeldnf.Repositories, repoFiles []string, baseSystem string, architectures []string, cacheHelper *repo.CacheHelper) *RepoReducer {
+func NewRepoReducer(repos *bazeldnf.Repositories, repoFiles []string, baseSystem string, architectures []string, priorityMasking bool, fixer *fixup.Fixer, cacheHelper *repo.CacheHelper) *RepoReducer {
 	implicitRequires := make([]string, 0, 1)
 	if baseSystem != "" {
 		implicitRequires = append(implicitRequires, baseSystem)
@@ -152,16 +165,18 @@ func NewRepoReducer(repos *bazeldnf.Repositories, repoFiles []string, baseSystem
 		packageInfo:      nil,
 		implicitRequires: implicitRequires,
 		loader: RepoLoader{
-			repoFiles:     repoFiles,
-			architectures: architectures,
-			repos:         repos,
-			cacheHelper:   cacheHelper,
+			repoFiles:       repoFiles,
+			architectures:   architectures,
+			repos:           repos,
+			cacheHelper:     cacheHelper,
+			priorityMasking: priorityMasking,
+			fixer:           fixer,
 		},
 	}
 }
 
-func Resolve(repos *bazeldnf.Repositories, repoFiles []string, baseSystem string, architectures []string, packages []string, ignoreMissing bool) (matched []string, involved []*api.Package, err error) {
-	repoReducer := NewRepoReducer(repos, repoFiles, baseSystem, architectures, repo.NewCacheHelper())
+func Resolve(repos *bazeldnf.Repositories, repoFiles []string, baseSystem string, architectures []string, packages []string, ignoreMissing bool, priorityMasking bool, fixer *fixup.Fixer) (matched []string, involved []*api.Package, err error) {
+	repoReducer := NewRepoReducer(repos, repoFiles, baseSystem, architectures, priorityMasking, fixer, repo.NewCacheHelper())
 	logrus.Info("Loading packages.")
 	if err := repoReducer.Load(); err != nil {
 		return nil, nil, err
@@ -0,0 +1,54 @@
+package reducer
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// reducedRepos are repositories written by `bazeldnf reduce`
+var reducedRepos = map[string]string{
+	"libvirt-devel-el8":   "libvirt-devel",
+	"libvirt-daemon-fc32": "libvirt-daemon",
+}
+
+func loadReducedRepo(b *testing.B, name string) *RepoReducer {
+	repoReducer := &RepoReducer{
+		loader: &RepoLoader{
+			repoFiles:     []string{filepath.Join("..", "sat", "testdata", name+".xml")},
+			architectures: []string{"x86_64", "noarch"},
+			cacheHelper:   MockCacheHelper{},
+		},
+	}
+	if err := repoReducer.Load(); err != nil {
+		b.Fatal(err)
+	}
+	return repoReducer
+}
+
+func BenchmarkResolve(b *testing.B) {
+	for name, target := range reducedRepos {
+		repoReducer := loadReducedRepo(b, name)
+		names := []string{}
+		for _, p := range repoReducer.packageInfo.packages {
+			if !slices.Contains(names, p.Name) {
+				names = append(names, p.Name)
+			}
+		}
+
+		b.Run(name+"/single", func(b *testing.B) {
+			for b.Loop() {
+				if _, _, err := repoReducer.Resolve([]string{target}, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/all", func(b *testing.B) {
+			for b.Loop() {
+				if _, _, err := repoReducer.Resolve(names, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
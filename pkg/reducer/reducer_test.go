@@ -303,6 +303,22 @@ func TestRepositoryPriorityWithVersion(t *testing.T) {
 	g.Expect(involved).Should(ConsistOf(&packages[1], &packages[2]))
 }
 
+func TestRepositoryWithoutPriority(t *testing.T) {
+	g := NewGomegaWithT(t)
+	packages := withRepository(newPackageList("bar", "bar", "bar"))
+	packages[0].Summary = "I'm the one"
+	packages[2].Version = api.Version{Epoch: "3"}
+
+	packageInfo := packageInfo{packages: packages}
+
+	matched, involved, err := resolve(&packageInfo, []string{"bar"}, []string{}, false)
+
+	// all repositories have the default priority, the first candidate is kept
+	g.Expect(err).Should(BeNil())
+	g.Expect(matched).Should(ConsistOf("bar"))
+	g.Expect(involved).Should(ConsistOf(&packages[0], &packages[2]))
+}
+
 func TestSpecifyVersion(t *testing.T) {
 	g := NewGomegaWithT(t)
 	packages := withRepository(newPackageList("foo", "foo", "foo", "bar", "baz", "baz"))
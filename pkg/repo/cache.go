@@ -126,7 +126,7 @@ func (r *CacheHelper) UnmarshalFromRepoDir(repo *bazeldnf.Repository, name strin
 	return xml.NewDecoder(reader).Decode(obj)
 }
 
-func (r *CacheHelper) getCompressFileReader(filename string, stream io.Reader) (io.ReadCloser, error) {
+func getCompressFileReader(filename string, stream io.Reader) (io.ReadCloser, error) {
 	if strings.HasSuffix(filename, ".gz") {
 		return gzip.NewReader(stream)
 	}
@@ -163,7 +163,7 @@ func (r *CacheHelper) CurrentPrimary(repo *bazeldnf.Repository) (*api.Repository
 	}
 	defer file.Close()
 
-	rc, err := r.getCompressFileReader(primaryName, file)
+	rc, err := getCompressFileReader(primaryName, file)
 	if err != nil {
 		return nil, err
 	}
@@ -217,7 +217,7 @@ func (r *CacheHelper) CurrentFilelistsForPackages(repo *bazeldnf.Repository, arc
 	}
 	defer file.Close()
 
-	reader, err := r.getCompressFileReader(filelists.Location.Href, file)
+	reader, err := getCompressFileReader(filelists.Location.Href, file)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -297,8 +297,64 @@ func (r *CacheHelper) CurrentFilelistsForPackages(repo *bazeldnf.Repository, arc
 	return filelistpkgs, remaining, nil
 }
 
+// CurrentChangelogs returns the changelogs of the packages with the given
+// names from the cached other.xml of the repository. Fails if no other.xml
+// was fetched.
+func (r *CacheHelper) CurrentChangelogs(repo *bazeldnf.Repository, names map[string]bool) ([]*api.OtherPackage, error) {
+	repomd := &api.Repomd{}
+	if err := r.UnmarshalFromRepoDir(repo, "repomd.xml", repomd); err != nil {
+		return nil, err
+	}
+	other := repomd.File(api.OtherFileType)
+	if other == nil {
+		return nil, fmt.Errorf("repository %s has no other.xml", repo.Name)
+	}
+	otherName := filepath.Base(other.Location.Href)
+	file, err := r.OpenFromRepoDir(repo, otherName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := getCompressFileReader(otherName, file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	pkgs := []*api.OtherPackage{}
+	d := xml.NewDecoder(reader)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Error decoding token: %s", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "name" && names[attr.Value] {
+				pkg := &api.OtherPackage{}
+				if err := d.DecodeElement(pkg, &start); err != nil {
+					return nil, fmt.Errorf("Error decoding item: %s", err)
+				}
+				pkgs = append(pkgs, pkg)
+				break
+			}
+		}
+	}
+	return pkgs, nil
+}
+
 func (r *CacheHelper) CurrentPrimaries(repos *bazeldnf.Repositories, architectures []string) (primaries []LoadedPrimary, err error) {
 	for i, repo := range repos.Repositories {
+		if repo.Disabled {
+			logrus.Infof("Ignoring disabled repository %s", repo.Name)
+			continue
+		}
 		if repo.Arch != "" && !slices.Contains(architectures, repo.Arch) {
 			logrus.Infof("Ignoring primary for %s - %s", repo.Name, repo.Arch)
 			continue
@@ -0,0 +1,348 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	repodataDir   = "repodata"
+	commonXmlns   = "http://linux.duke.edu/metadata/common"
+	rpmXmlns      = "http://linux.duke.edu/metadata/rpm"
+	repoXmlns     = "http://linux.duke.edu/metadata/repo"
+	filelistXmlns = "http://linux.duke.edu/metadata/filelists"
+)
+
+// RepoCreator generates rpm-md repository metadata (repomd.xml, primary.xml
+// and optionally filelists.xml) for a directory containing RPMs.
+type RepoCreator struct {
+	Dir       string
+	Filelists bool
+	// Update reuses the metadata of RPMs which did not change since the
+	// existing repodata was generated, instead of reading them again.
+	Update bool
+	now    func() time.Time
+}
+
+func NewRepoCreator(dir string, filelists bool, update bool) *RepoCreator {
+	return &RepoCreator{
+		Dir:       dir,
+		Filelists: filelists,
+		Update:    update,
+		now:       time.Now,
+	}
+}
+
+type createdPackage struct {
+	primary *api.Package
+	files   *api.FileListPackage
+}
+
+func (r *RepoCreator) Create() error {
+	rpms, err := r.findRPMs()
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]createdPackage{}
+	existingFiles := []string{}
+	if r.Update {
+		existing, existingFiles, err = r.loadExisting()
+		if err != nil {
+			return err
+		}
+	}
+
+	created := []createdPackage{}
+	for _, href := range rpms {
+		file := filepath.Join(r.Dir, filepath.FromSlash(href))
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if pkg, exists := existing[href]; exists && r.unchanged(pkg, info) {
+			logrus.Debugf("Reusing metadata for %s", href)
+			created = append(created, pkg)
+			continue
+		}
+		logrus.Infof("Reading %s", href)
+		pkg, err := readCreatedPackage(file, href, info)
+		if err != nil {
+			return err
+		}
+		created = append(created, pkg)
+	}
+
+	slices.SortStableFunc(created, func(a, b createdPackage) int {
+		return rpm.ComparePackageKey(a.primary.Key(), b.primary.Key())
+	})
+
+	repository := &api.Repository{
+		Xmlns:        commonXmlns,
+		Rpm:          rpmXmlns,
+		PackageCount: strconv.Itoa(len(created)),
+	}
+	filelists := &api.Filelists{
+		Xmlns:    filelistXmlns,
+		Packages: strconv.Itoa(len(created)),
+	}
+	for _, pkg := range created {
+		repository.Packages = append(repository.Packages, *pkg.primary)
+		if r.Filelists {
+			filelists.Package = append(filelists.Package, *pkg.files)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(r.Dir, repodataDir), 0755); err != nil {
+		return fmt.Errorf("failed to create repodata directory: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(r.now().Unix(), 10)
+	repomd := &api.Repomd{
+		Xmlns:    repoXmlns,
+		Rpm:      rpmXmlns,
+		Revision: timestamp,
+	}
+	primaryData, err := r.writeMetadata(api.PrimaryFileType, repository, timestamp)
+	if err != nil {
+		return err
+	}
+	repomd.Data = append(repomd.Data, *primaryData)
+	if r.Filelists {
+		filelistsData, err := r.writeMetadata(api.FilelistsFileType, filelists, timestamp)
+		if err != nil {
+			return err
+		}
+		repomd.Data = append(repomd.Data, *filelistsData)
+	}
+
+	if err := r.writeRepomd(repomd); err != nil {
+		return err
+	}
+
+	// only remove files which were referenced by the previous repomd.xml and are now stale
+	for _, old := range existingFiles {
+		if slices.ContainsFunc(repomd.Data, func(d api.Data) bool { return d.Location.Href == old }) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.Dir, filepath.FromSlash(old))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale metadata %s: %v", old, err)
+		}
+	}
+	logrus.Infof("Wrote metadata for %d packages to %s", len(created), filepath.Join(r.Dir, repodataDir))
+	return nil
+}
+
+// findRPMs returns the slash separated paths of all RPMs relative to the repository directory
+func (r *RepoCreator) findRPMs() ([]string, error) {
+	rpms := []string{}
+	err := filepath.WalkDir(r.Dir, func(file string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == repodataDir {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".rpm") {
+			return nil
+		}
+		rel, err := filepath.Rel(r.Dir, file)
+		if err != nil {
+			return err
+		}
+		rpms = append(rpms, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find RPMs in %s: %v", r.Dir, err)
+	}
+	slices.Sort(rpms)
+	return rpms, nil
+}
+
+// loadExisting loads the metadata of a previous run, indexed by the location of the RPMs.
+// A missing repomd.xml is not an error, it just means that there is nothing to reuse.
+func (r *RepoCreator) loadExisting() (map[string]createdPackage, []string, error) {
+	existing := map[string]createdPackage{}
+	repomd := &api.Repomd{}
+	f, err := os.Open(filepath.Join(r.Dir, repodataDir, "repomd.xml"))
+	if os.IsNotExist(err) {
+		return existing, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	if err := xml.NewDecoder(f).Decode(repomd); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode existing repomd.xml: %v", err)
+	}
+
+	referenced := []string{}
+	for _, data := range repomd.Data {
+		referenced = append(referenced, data.Location.Href)
+	}
+
+	primaryData := repomd.File(api.PrimaryFileType)
+	if primaryData == nil {
+		return existing, referenced, nil
+	}
+	repository := &api.Repository{}
+	if err := r.readMetadata(primaryData, repository); err != nil {
+		return nil, nil, err
+	}
+
+	files := map[string]*api.FileListPackage{}
+	if filelistsData := repomd.File(api.FilelistsFileType); filelistsData != nil && r.Filelists {
+		filelists := &api.Filelists{}
+		if err := r.readMetadata(filelistsData, filelists); err != nil {
+			return nil, nil, err
+		}
+		for i, pkg := range filelists.Package {
+			files[pkg.Pkgid] = &filelists.Package[i]
+		}
+	}
+
+	for i, pkg := range repository.Packages {
+		existing[pkg.Location.Href] = createdPackage{
+			primary: &repository.Packages[i],
+			files:   files[pkg.Checksum.Text],
+		}
+	}
+	return existing, referenced, nil
+}
+
+// unchanged checks if the RPM on disk still matches the existing metadata
+func (r *RepoCreator) unchanged(pkg createdPackage, info os.FileInfo) bool {
+	if r.Filelists && pkg.files == nil {
+		return false
+	}
+	return pkg.primary.Size.Package == int(info.Size()) &&
+		pkg.primary.Time.File == strconv.FormatInt(info.ModTime().Unix(), 10)
+}
+
+func (r *RepoCreator) readMetadata(data *api.Data, obj interface{}) error {
+	file := filepath.Join(r.Dir, filepath.FromSlash(data.Location.Href))
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open existing metadata: %v", err)
+	}
+	defer f.Close()
+	reader, err := getCompressFileReader(file, f)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(reader).Decode(obj); err != nil {
+		return fmt.Errorf("failed to decode existing metadata %s: %v", data.Location.Href, err)
+	}
+	return nil
+}
+
+func (r *RepoCreator) writeMetadata(fileType string, obj interface{}, timestamp string) (*api.Data, error) {
+	content, err := xml.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %v", fileType, err)
+	}
+	content = append([]byte(xml.Header), content...)
+
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	if _, err := writer.Write(content); err != nil {
+		return nil, fmt.Errorf("failed to compress %s: %v", fileType, err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress %s: %v", fileType, err)
+	}
+
+	checksum := sha256.Sum256(compressed.Bytes())
+	openChecksum := sha256.Sum256(content)
+	name := fmt.Sprintf("%s-%s.xml.gz", hex.EncodeToString(checksum[:]), fileType)
+	href := path.Join(repodataDir, name)
+	if err := os.WriteFile(filepath.Join(r.Dir, repodataDir, name), compressed.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", href, err)
+	}
+
+	data := &api.Data{
+		Type:      fileType,
+		Timestamp: timestamp,
+		Size:      strconv.Itoa(compressed.Len()),
+		OpenSize:  strconv.Itoa(len(content)),
+	}
+	data.Checksum.Type = "sha256"
+	data.Checksum.Text = hex.EncodeToString(checksum[:])
+	data.OpenChecksum.Type = "sha256"
+	data.OpenChecksum.Text = hex.EncodeToString(openChecksum[:])
+	data.Location.Href = href
+	return data, nil
+}
+
+func (r *RepoCreator) writeRepomd(repomd *api.Repomd) error {
+	content, err := xml.MarshalIndent(repomd, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal repomd.xml: %v", err)
+	}
+	content = append([]byte(xml.Header), content...)
+
+	// write to a temporary file first so that readers never observe a partially written repomd.xml
+	target := filepath.Join(r.Dir, repodataDir, "repomd.xml")
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write repomd.xml: %v", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("failed to write repomd.xml: %v", err)
+	}
+	return nil
+}
+
+func readCreatedPackage(file string, href string, info os.FileInfo) (createdPackage, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return createdPackage{}, fmt.Errorf("failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	sha := sha256.New()
+	pkg, files, err := rpm.ReadPackage(io.TeeReader(f, sha))
+	if err != nil {
+		return createdPackage{}, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	if _, err := io.Copy(sha, f); err != nil {
+		return createdPackage{}, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+
+	pkg.Checksum = api.Checksum{
+		Type:  "sha256",
+		Pkgid: "YES",
+		Text:  toHex(sha),
+	}
+	pkg.Location = api.Location{Href: href}
+	pkg.Size.Package = int(info.Size())
+	pkg.Time.File = strconv.FormatInt(info.ModTime().Unix(), 10)
+
+	return createdPackage{
+		primary: pkg,
+		files: &api.FileListPackage{
+			Pkgid:   pkg.Checksum.Text,
+			Name:    pkg.Name,
+			Arch:    pkg.Arch,
+			Version: pkg.Version,
+			File:    files,
+		},
+	}, nil
+}
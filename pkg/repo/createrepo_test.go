@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func copyTestRPMs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir("testdata/rpms")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		content, err := os.ReadFile(path.Join("testdata/rpms", entry.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if err := os.WriteFile(path.Join(dir, entry.Name()), content, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	return dir
+}
+
+func fetchCreatedRepo(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	repo := bazeldnf.Repository{Name: "local", Baseurl: "file://" + dir}
+	cacheHelper := NewCacheHelper(t.TempDir())
+	fetcher := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      &getterImpl{},
+		CacheHelper: cacheHelper,
+	}
+	if err := fetcher.Fetch(); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	primary, err := cacheHelper.CurrentPrimary(&repo)
+	if err != nil {
+		t.Fatalf("CurrentPrimary failed: %v", err)
+	}
+	packages := map[string]string{}
+	for _, pkg := range primary.Packages {
+		packages[pkg.Name] = pkg.Location.Href
+	}
+	return packages
+}
+
+func TestCreateRepo(t *testing.T) {
+	dir := copyTestRPMs(t)
+	if err := NewRepoCreator(dir, true, false).Create(); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	packages := fetchCreatedRepo(t, dir)
+	expected := map[string]string{
+		"one-epoch": "one-epoch-0.1-1.x86_64.rpm",
+		"simple":    "simple-1.0.1-1.i386.rpm",
+	}
+	if len(packages) != len(expected) {
+		t.Fatalf("expected packages %v, but got %v", expected, packages)
+	}
+	for name, href := range expected {
+		if packages[name] != href {
+			t.Fatalf("expected %s at %s, but got %q", name, href, packages[name])
+		}
+	}
+}
+
+func TestCreateRepoUpdate(t *testing.T) {
+	dir := copyTestRPMs(t)
+	creator := NewRepoCreator(dir, true, true)
+	creator.now = func() time.Time { return time.Unix(1, 0) }
+	if err := creator.Create(); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := os.Remove(path.Join(dir, "simple-1.0.1-1.i386.rpm")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	// unchanged RPMs must not be read again, so garbage with the same size and mtime is not detected
+	rpmFile := path.Join(dir, "one-epoch-0.1-1.x86_64.rpm")
+	info, err := os.Stat(rpmFile)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := os.WriteFile(rpmFile, make([]byte, info.Size()), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.Chtimes(rpmFile, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	creator.now = func() time.Time { return time.Unix(2, 0) }
+	if err := creator.Create(); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	packages := fetchCreatedRepo(t, dir)
+	if len(packages) != 1 || packages["one-epoch"] == "" {
+		t.Fatalf("expected only one-epoch to remain, but got %v", packages)
+	}
+
+	metadata, err := filepath.Glob(path.Join(dir, "repodata", "*.xml.gz"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(metadata) != 2 {
+		t.Fatalf("expected stale metadata to be removed, but found %v", metadata)
+	}
+}
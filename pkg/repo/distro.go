@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed distros/*.yaml
+var builtinDistros embed.FS
+
+// Distro is a template for the repositories of a distribution. All string
+// fields of the repositories are go templates which can reference `.Release`
+// and `.Arch`.
+type Distro struct {
+	Name         string                `json:"name"`
+	Description  string                `json:"description,omitempty"`
+	BaseSystem   string                `json:"basesystem,omitempty"`
+	Arches       []string              `json:"arches,omitempty"`
+	Releases     []string              `json:"releases,omitempty"`
+	Repositories []bazeldnf.Repository `json:"repositories"`
+}
+
+type distroValues struct {
+	Release string
+	Arch    string
+}
+
+// DistroCatalog holds all known distribution templates by name
+type DistroCatalog struct {
+	distros map[string]*Distro
+}
+
+// NewDistroCatalog loads the builtin distribution templates and afterwards the
+// given template files. Templates from files replace builtin templates with
+// the same name.
+func NewDistroCatalog(templateFiles ...string) (*DistroCatalog, error) {
+	catalog := &DistroCatalog{distros: map[string]*Distro{}}
+	entries, err := builtinDistros.ReadDir("distros")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		data, err := builtinDistros.ReadFile(path.Join("distros", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if err := catalog.add(entry.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range templateFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read distribution template %s: %v", file, err)
+		}
+		if err := catalog.add(file, data); err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
+
+func (c *DistroCatalog) add(source string, data []byte) error {
+	distro := &Distro{}
+	if err := yaml.Unmarshal(data, distro); err != nil {
+		return fmt.Errorf("failed to parse distribution template %s: %v", source, err)
+	}
+	if distro.Name == "" {
+		return fmt.Errorf("distribution template %s has no name", source)
+	}
+	if len(distro.Repositories) == 0 {
+		return fmt.Errorf("distribution template %s has no repositories", source)
+	}
+	c.distros[distro.Name] = distro
+	return nil
+}
+
+// Names returns the sorted names of all known distributions
+func (c *DistroCatalog) Names() []string {
+	names := []string{}
+	for name := range c.distros {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (c *DistroCatalog) Get(name string) (*Distro, error) {
+	distro, exists := c.distros[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown distribution %q, known distributions are: %s", name, strings.Join(c.Names(), ", "))
+	}
+	return distro, nil
+}
+
+// Render creates the repositories of the distribution for a given release and architecture
+func (d *Distro) Render(release string, arch string) ([]bazeldnf.Repository, error) {
+	if len(d.Arches) > 0 && !slices.Contains(d.Arches, arch) {
+		return nil, fmt.Errorf("distribution %s does not support architecture %s, supported architectures are: %s", d.Name, arch, strings.Join(d.Arches, ", "))
+	}
+	if len(d.Releases) > 0 && !slices.Contains(d.Releases, release) {
+		return nil, fmt.Errorf("distribution %s does not support release %q, supported releases are: %s", d.Name, release, strings.Join(d.Releases, ", "))
+	}
+
+	values := distroValues{Release: release, Arch: arch}
+	render := func(text string) (string, error) {
+		if release == "" && strings.Contains(text, ".Release") {
+			return "", fmt.Errorf("distribution %s requires a release", d.Name)
+		}
+		tmpl, err := template.New(d.Name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid template in distribution %s: %v", d.Name, err)
+		}
+		out := &bytes.Buffer{}
+		if err := tmpl.Execute(out, values); err != nil {
+			return "", fmt.Errorf("failed to render distribution %s: %v", d.Name, err)
+		}
+		return out.String(), nil
+	}
+
+	repos := []bazeldnf.Repository{}
+	for _, tmpl := range d.Repositories {
+		repo := tmpl
+		repo.Mirrors = nil
+		fields := []*string{&repo.Name, &repo.Arch, &repo.Metalink, &repo.Baseurl, &repo.GPGKey}
+		for _, field := range fields {
+			rendered, err := render(*field)
+			if err != nil {
+				return nil, err
+			}
+			*field = rendered
+		}
+		for _, mirror := range tmpl.Mirrors {
+			rendered, err := render(mirror)
+			if err != nil {
+				return nil, err
+			}
+			repo.Mirrors = append(repo.Mirrors, rendered)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
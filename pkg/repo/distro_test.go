@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBuiltinDistros(t *testing.T) {
+	catalog, err := NewDistroCatalog()
+	if err != nil {
+		t.Fatalf("NewDistroCatalog failed: %v", err)
+	}
+	for _, name := range catalog.Names() {
+		distro, err := catalog.Get(name)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", name, err)
+		}
+		release := "1"
+		if len(distro.Releases) > 0 {
+			release = distro.Releases[0]
+		}
+		repos, err := distro.Render(release, distro.Arches[0])
+		if err != nil {
+			t.Fatalf("Render %s failed: %v", name, err)
+		}
+		for _, repo := range repos {
+			if repo.Metalink == "" && repo.Baseurl == "" {
+				t.Fatalf("repository %s of %s has neither a metalink nor a baseurl", repo.Name, name)
+			}
+		}
+	}
+}
+
+func TestRenderFedora(t *testing.T) {
+	catalog, err := NewDistroCatalog()
+	if err != nil {
+		t.Fatalf("NewDistroCatalog failed: %v", err)
+	}
+	distro, err := catalog.Get("fedora")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	repos, err := distro.Render("42", "aarch64")
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expected 2 repositories, but got %d", len(repos))
+	}
+	if repos[0].Name != "42-aarch64-primary-repo" || repos[0].Arch != "aarch64" {
+		t.Fatalf("unexpected repository %+v", repos[0])
+	}
+	if repos[1].Metalink != "https://mirrors.fedoraproject.org/metalink?repo=updates-released-f42&arch=aarch64" {
+		t.Fatalf("unexpected metalink %s", repos[1].Metalink)
+	}
+}
+
+func TestRenderValidation(t *testing.T) {
+	catalog, err := NewDistroCatalog()
+	if err != nil {
+		t.Fatalf("NewDistroCatalog failed: %v", err)
+	}
+	if _, err := catalog.Get("unknown"); err == nil {
+		t.Fatalf("expected unknown distribution to fail")
+	}
+	distro, err := catalog.Get("centos-stream")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := distro.Render("10", "i686"); err == nil {
+		t.Fatalf("expected unsupported architecture to fail")
+	}
+	if _, err := distro.Render("7", "x86_64"); err == nil {
+		t.Fatalf("expected unsupported release to fail")
+	}
+	fedora, err := catalog.Get("fedora")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := fedora.Render("", "x86_64"); err == nil {
+		t.Fatalf("expected missing release to fail")
+	}
+}
+
+func TestUserTemplateReplacesBuiltin(t *testing.T) {
+	template := path.Join(t.TempDir(), "fedora.yaml")
+	content := `name: fedora
+repositories:
+- name: "internal-{{.Release}}"
+  arch: "{{.Arch}}"
+  baseurl: "https://mirror.example.com/fedora/{{.Release}}/{{.Arch}}/"
+`
+	if err := os.WriteFile(template, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	catalog, err := NewDistroCatalog(template)
+	if err != nil {
+		t.Fatalf("NewDistroCatalog failed: %v", err)
+	}
+	distro, err := catalog.Get("fedora")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	repos, err := distro.Render("42", "riscv64")
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(repos) != 1 || repos[0].Baseurl != "https://mirror.example.com/fedora/42/riscv64/" {
+		t.Fatalf("unexpected repositories %+v", repos)
+	}
+}
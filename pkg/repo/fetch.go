@@ -34,10 +34,16 @@ type RepoFetcherImpl struct {
 	Getter      Getter
 	Repos       []bazeldnf.Repository
 	CacheHelper *CacheHelper
+	// Changelogs enables fetching other.xml, which contains the changelogs
+	Changelogs bool
 }
 
 func (r *RepoFetcherImpl) Fetch() (err error) {
 	for _, repo := range r.Repos {
+		if repo.Disabled {
+			log.Infof("Ignoring disabled repository %s", repo.Name)
+			continue
+		}
 		sha256sum := []string{}
 		var repomdURLs = []string{}
 		if repo.Metalink != "" {
@@ -61,6 +67,13 @@ func (r *RepoFetcherImpl) Fetch() (err error) {
 		if err != nil {
 			return fmt.Errorf("failed to fetch primary.xml for %s: %v", repo.Name, err)
 		}
+		if r.Changelogs {
+			if repomd.File(api.OtherFileType) == nil {
+				log.Warnf("Repository %s has no other.xml, changelogs are not available", repo.Name)
+			} else if err = r.fetchFile(api.OtherFileType, &repo, repomd, mirror); err != nil {
+				return fmt.Errorf("failed to fetch other.xml for %s: %v", repo.Name, err)
+			}
+		}
 		/* not used right now, save some bandwidth
 		err = r.fetchFile(api.FilelistsFileType, &repo, repomd, mirror)
 		if err != nil {
@@ -71,7 +84,7 @@ func (r *RepoFetcherImpl) Fetch() (err error) {
 	return nil
 }
 
-func NewRemoteRepoFetcher(repos []bazeldnf.Repository) RepoFetcher {
+func NewRemoteRepoFetcher(repos []bazeldnf.Repository) *RepoFetcherImpl {
 	return &RepoFetcherImpl{
 		Repos:       repos,
 		Getter:      &getterImpl{},
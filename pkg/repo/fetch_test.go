@@ -2,6 +2,7 @@ package repo
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 )
 
 const retryAttempts = 5
@@ -160,3 +162,40 @@ password %s`, user, password)
 		t.Fatalf("We've set NETRC so the server should reply with 200 but got %d", resp.StatusCode)
 	}
 }
+
+func TestCurrentChangelogs(t *testing.T) {
+	cacheDir := t.TempDir()
+	repo := &bazeldnf.Repository{Name: "fedora"}
+	if err := os.MkdirAll(path.Join(cacheDir, repo.Name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repomd := `<repomd><data type="other"><location href="repodata/abc-other.xml.gz"/></data></repomd>`
+	other := `<otherdata packages="2">
+<package pkgid="a" name="bash" arch="x86_64"><version epoch="0" ver="5.2.37" rel="1.fc44"/>
+<changelog author="Jane Doe &lt;jane@example.com&gt; - 5.2.37-1" date="1760745600">- Update to 5.2.37</changelog>
+</package>
+<package pkgid="b" name="glibc" arch="x86_64"><version epoch="0" ver="2.42" rel="1.fc44"/></package>
+</otherdata>`
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	if _, err := writer.Write([]byte(other)); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	for name, content := range map[string][]byte{"repomd.xml": []byte(repomd), "abc-other.xml.gz": compressed.Bytes()} {
+		if err := os.WriteFile(path.Join(cacheDir, repo.Name, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkgs, err := NewCacheHelper(cacheDir).CurrentChangelogs(repo, map[string]bool{"bash": true})
+	if err != nil {
+		t.Fatalf("CurrentChangelogs failed: %v", err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Name != "bash" || len(pkgs[0].Changelog) != 1 {
+		t.Fatalf("expected the changelog of bash, got %v", pkgs)
+	}
+	if author := pkgs[0].Changelog[0].Author; author != "Jane Doe <jane@example.com> - 5.2.37-1" {
+		t.Fatalf("unexpected author %q", author)
+	}
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/spf13/cobra"
+)
+
+type repoFilterOpts struct {
+	enable  []string
+	disable []string
+}
+
+var repoFilterValues = repoFilterOpts{}
+
+func AddRepoFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&repoFilterValues.enable, "enablerepo", []string{}, "enable repositories matching this glob pattern, even if they are disabled in the repository file. Can be specified multiple times")
+	cmd.Flags().StringArrayVar(&repoFilterValues.disable, "disablerepo", []string{}, "disable repositories matching this glob pattern. Can be specified multiple times")
+}
+
+// LoadRepoFilesWithFilters loads the repository files and applies the
+// --enablerepo and --disablerepo overrides from the command line
+func LoadRepoFilesWithFilters(files []string) (*bazeldnf.Repositories, error) {
+	repos, err := LoadRepoFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if err := FilterRepos(repos, repoFilterValues.enable, repoFilterValues.disable); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// FilterRepos changes the `disabled` state of all repositories whose name
+// matches one of the given glob patterns. Disable patterns are applied first,
+// so that `--disablerepo '*' --enablerepo fedora` only keeps fedora enabled.
+func FilterRepos(repos *bazeldnf.Repositories, enable []string, disable []string) error {
+	if err := setDisabled(repos, disable, true); err != nil {
+		return err
+	}
+	return setDisabled(repos, enable, false)
+}
+
+func setDisabled(repos *bazeldnf.Repositories, patterns []string, disabled bool) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid repository pattern %q: %v", pattern, err)
+		}
+		matched := false
+		for i, repo := range repos.Repositories {
+			if ok, _ := path.Match(pattern, repo.Name); ok {
+				repos.Repositories[i].Disabled = disabled
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("no repository matches %q", pattern)
+		}
+	}
+	return nil
+}
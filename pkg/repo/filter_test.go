@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func testRepos() *bazeldnf.Repositories {
+	return &bazeldnf.Repositories{
+		Repositories: []bazeldnf.Repository{
+			{Name: "fedora"},
+			{Name: "updates"},
+			{Name: "updates-testing", Disabled: true},
+		},
+	}
+}
+
+func disabledRepos(repos *bazeldnf.Repositories) map[string]bool {
+	disabled := map[string]bool{}
+	for _, repo := range repos.Repositories {
+		disabled[repo.Name] = repo.Disabled
+	}
+	return disabled
+}
+
+func TestFilterRepos(t *testing.T) {
+	tests := []struct {
+		name     string
+		enable   []string
+		disable  []string
+		expected map[string]bool
+	}{
+		{
+			name:     "no overrides",
+			expected: map[string]bool{"fedora": false, "updates": false, "updates-testing": true},
+		},
+		{
+			name:     "enable disabled repo",
+			enable:   []string{"updates-testing"},
+			expected: map[string]bool{"fedora": false, "updates": false, "updates-testing": false},
+		},
+		{
+			name:     "disable with glob",
+			disable:  []string{"updates*"},
+			expected: map[string]bool{"fedora": false, "updates": true, "updates-testing": true},
+		},
+		{
+			name:     "enable wins over disable",
+			enable:   []string{"*testing"},
+			disable:  []string{"*"},
+			expected: map[string]bool{"fedora": true, "updates": true, "updates-testing": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := testRepos()
+			if err := FilterRepos(repos, tt.enable, tt.disable); err != nil {
+				t.Fatalf("FilterRepos failed: %v", err)
+			}
+			disabled := disabledRepos(repos)
+			for name, expected := range tt.expected {
+				if disabled[name] != expected {
+					t.Fatalf("expected disabled state of %s to be %v, but got %v", name, expected, disabled[name])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterReposErrors(t *testing.T) {
+	if err := FilterRepos(testRepos(), []string{"epel*"}, nil); err == nil {
+		t.Fatalf("expected a pattern without matches to fail")
+	}
+	if err := FilterRepos(testRepos(), nil, []string{"[fedora"}); err == nil {
+		t.Fatalf("expected an invalid pattern to fail")
+	}
+}
+
+func TestCurrentPrimariesSkipsDisabledRepos(t *testing.T) {
+	// the disabled repository was never fetched, loading it would fail
+	repos := &bazeldnf.Repositories{
+		Repositories: []bazeldnf.Repository{
+			{Name: "disabled", Disabled: true, Arch: "x86_64"},
+		},
+	}
+	primaries, err := NewCacheHelper(t.TempDir()).CurrentPrimaries(repos, []string{"x86_64"})
+	if err != nil {
+		t.Fatalf("CurrentPrimaries failed: %v", err)
+	}
+	if len(primaries) != 0 {
+		t.Fatalf("expected no primaries, but got %d", len(primaries))
+	}
+}
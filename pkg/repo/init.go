@@ -4,18 +4,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
+	"path/filepath"
 
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"sigs.k8s.io/yaml"
 )
 
 type RepoInit struct {
-	OS                 string
-	Arch               string
-	PrimaryMetaLinkURL string
-	UpdateMetaLinkURL  string
-	RepoFile           string
+	Repositories []bazeldnf.Repository
+	RepoFile     string
 }
 
 func (r *RepoInit) Init() error {
@@ -25,22 +22,7 @@ func (r *RepoInit) Init() error {
 		return fmt.Errorf("repository file %s already exists.", r.RepoFile)
 	}
 	repos := &bazeldnf.Repositories{
-		Repositories: []bazeldnf.Repository{
-			{
-				Name:     fmt.Sprintf("%s-%s-primary-repo", r.OS, r.Arch),
-				Disabled: false,
-				Metalink: r.PrimaryMetaLinkURL,
-				Baseurl:  "",
-				Arch:     r.Arch,
-			},
-			{
-				Name:     fmt.Sprintf("%s-%s-update-repo", r.OS, r.Arch),
-				Disabled: false,
-				Metalink: r.UpdateMetaLinkURL,
-				Baseurl:  "",
-				Arch:     r.Arch,
-			},
-		},
+		Repositories: r.Repositories,
 	}
 	data, err := yaml.Marshal(repos)
 	if err != nil {
@@ -49,15 +31,21 @@ func (r *RepoInit) Init() error {
 	return ioutil.WriteFile(r.RepoFile, data, 0660)
 }
 
-func NewRemoteInit(os string, arch string, repoFile string) *RepoInit {
-	os = strings.TrimPrefix(os, "f")
-	return &RepoInit{
-		OS:                 os,
-		Arch:               arch,
-		RepoFile:           repoFile,
-		PrimaryMetaLinkURL: fmt.Sprintf("https://mirrors.fedoraproject.org/metalink?repo=fedora-%s&arch=%s", os, arch),
-		UpdateMetaLinkURL:  fmt.Sprintf("https://mirrors.fedoraproject.org/metalink?repo=updates-released-f%s&arch=%s", os, arch),
+// NewDistroInit renders the repositories of all given distributions for the
+// same release and architecture into a single repository file.
+func NewDistroInit(distros []*Distro, release string, arch string, repoFile string) (*RepoInit, error) {
+	repos := []bazeldnf.Repository{}
+	for _, distro := range distros {
+		rendered, err := distro.Render(release, arch)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, rendered...)
 	}
+	return &RepoInit{
+		Repositories: repos,
+		RepoFile:     repoFile,
+	}, nil
 }
 
 func LoadRepoFile(file string) (*bazeldnf.Repositories, error) {
@@ -81,6 +69,98 @@ func LoadRepoFiles(files []string) (*bazeldnf.Repositories, error) {
 			return nil, err
 		}
 		repos.Repositories = append(repos.Repositories, tmp.Repositories...)
+		for name, repo := range tmp.FromRepo {
+			if repos.FromRepo == nil {
+				repos.FromRepo = map[string]string{}
+			}
+			repos.FromRepo[name] = repo
+		}
+		for _, constraints := range tmp.Constraints {
+			if !filepath.IsAbs(constraints) {
+				constraints = filepath.Join(filepath.Dir(files[i]), constraints)
+			}
+			repos.Constraints = append(repos.Constraints, constraints)
+		}
+		for _, fixups := range tmp.Fixups {
+			if !filepath.IsAbs(fixups) {
+				fixups = filepath.Join(filepath.Dir(files[i]), fixups)
+			}
+			repos.Fixups = append(repos.Fixups, fixups)
+		}
+		repos.Prefer = append(repos.Prefer, tmp.Prefer...)
+		repos.Avoid = append(repos.Avoid, tmp.Avoid...)
 	}
 	return repos, nil
 }
+
+// LoadConstraintsFiles loads and merges the constraints of all given files
+func LoadConstraintsFiles(files []string) (*bazeldnf.Constraints, error) {
+	constraints := &bazeldnf.Constraints{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read constraints file %s: %v", file, err)
+		}
+		tmp := &bazeldnf.Constraints{}
+		if err := yaml.Unmarshal(data, tmp); err != nil {
+			return nil, fmt.Errorf("failed to parse constraints file %s: %v", file, err)
+		}
+		for _, constraint := range tmp.Constraints {
+			if constraint.Name == "" {
+				return nil, fmt.Errorf("constraint without a package name in %s", file)
+			}
+			if constraint.Allow == "" && constraint.Exclude == "" {
+				return nil, fmt.Errorf("constraint for %s in %s has neither allow nor exclude set", constraint.Name, file)
+			}
+		}
+		constraints.Constraints = append(constraints.Constraints, tmp.Constraints...)
+	}
+	return constraints, nil
+}
+
+// LoadFixupsFiles loads and merges the fixups of all given files
+func LoadFixupsFiles(files []string) (*bazeldnf.Fixups, error) {
+	fixups := &bazeldnf.Fixups{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixups file %s: %v", file, err)
+		}
+		tmp := &bazeldnf.Fixups{}
+		if err := yaml.Unmarshal(data, tmp); err != nil {
+			return nil, fmt.Errorf("failed to parse fixups file %s: %v", file, err)
+		}
+		for _, fixup := range tmp.Fixups {
+			if fixup.Name == "" {
+				return nil, fmt.Errorf("fixup without a name in %s", file)
+			}
+			if fixup.Package == "" && !fixup.Disabled {
+				return nil, fmt.Errorf("fixup %s in %s has no package pattern", fixup.Name, file)
+			}
+		}
+		fixups.Fixups = append(fixups.Fixups, tmp.Fixups...)
+	}
+	return fixups, nil
+}
+
+// LoadLicensePolicyFiles loads and merges the license policies of all given files
+func LoadLicensePolicyFiles(files []string) (*bazeldnf.LicensePolicy, error) {
+	policy := &bazeldnf.LicensePolicy{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read license policy file %s: %v", file, err)
+		}
+		tmp := &bazeldnf.LicensePolicy{}
+		if err := yaml.Unmarshal(data, tmp); err != nil {
+			return nil, fmt.Errorf("failed to parse license policy file %s: %v", file, err)
+		}
+		if len(tmp.Allow) == 0 && len(tmp.Deny) == 0 {
+			return nil, fmt.Errorf("license policy file %s has neither allow nor deny set", file)
+		}
+		policy.Allow = append(policy.Allow, tmp.Allow...)
+		policy.Deny = append(policy.Deny, tmp.Deny...)
+		policy.AllowNonSPDX = policy.AllowNonSPDX || tmp.AllowNonSPDX
+	}
+	return policy, nil
+}
@@ -0,0 +1,111 @@
+package rpm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/sassoftware/go-rpmutils/cpio"
+	log "github.com/sirupsen/logrus"
+)
+
+// ConflictMode decides what happens if two packages contain the same path
+// with a different content or mode
+type ConflictMode string
+
+const (
+	// ConflictModeWarn keeps the file of the first package and logs a warning
+	ConflictModeWarn ConflictMode = "warn"
+	// ConflictModeFail aborts, like rpm does when installing conflicting packages
+	ConflictModeFail ConflictMode = "fail"
+	// ConflictModeOrder takes the file of the package which comes last in the
+	// package order, like it would be after installing the packages one after
+	// another with `--replacefiles`
+	ConflictModeOrder ConflictMode = "order"
+)
+
+// ConflictModes contains all supported conflict modes
+var ConflictModes = []ConflictMode{ConflictModeWarn, ConflictModeFail, ConflictModeOrder}
+
+// FileConflict describes a path which is contained in two packages with a
+// different content or mode
+type FileConflict struct {
+	Path     string
+	Packages []string
+	Picked   string
+}
+
+// createdPath remembers which package wrote a path and what it looked like
+type createdPath struct {
+	pkg  string
+	mode int
+	// digest is the sha256 of the content of regular files and the target of
+	// symlinks. It is empty if the content is unknown, like for hard links.
+	digest string
+}
+
+func sha256Hex(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// readDuplicate reads the content of an entry whose path was already written,
+// to be able to compare it with the existing path
+func readDuplicate(entry *cpio.CpioEntry, current *createdPath) ([]byte, error) {
+	switch entry.Header.Mode() &^ 0o7777 {
+	case cpio.S_ISLNK:
+		content, err := io.ReadAll(entry.Payload)
+		if err != nil {
+			return nil, err
+		}
+		current.digest = string(content)
+		return content, nil
+	case cpio.S_ISREG:
+		if entry.Header.Nlink() > 1 && entry.Header.Filesize() == 0 {
+			// the content of hard links is only known after the last link
+			return nil, nil
+		}
+		content, err := io.ReadAll(entry.Payload)
+		if err != nil {
+			return nil, err
+		}
+		current.digest = sha256Hex(content)
+		return content, nil
+	}
+	return nil, nil
+}
+
+// checkConflict compares a path which is about to be written with the path
+// which was already written by another package. It returns true if the new
+// entry should be written on top of the existing one.
+func (c *Collector) checkConflict(name string, existing *createdPath, current *createdPath) (bool, error) {
+	sameType := existing.mode&^0o7777 == current.mode&^0o7777
+	if sameType && current.mode&^0o7777 == cpio.S_ISDIR {
+		// directories are shared between packages
+		return false, nil
+	}
+	if sameType && (existing.digest == "" || current.digest == "") {
+		log.Debugf("Skipping duplicate tar entry %s of %s, its content is unknown", name, current.pkg)
+		return false, nil
+	}
+	if existing.mode == current.mode && existing.digest == current.digest {
+		log.Debugf("Skipping identical duplicate tar entry %s of %s", name, current.pkg)
+		return false, nil
+	}
+
+	conflict := FileConflict{Path: name, Packages: []string{existing.pkg, current.pkg}, Picked: existing.pkg}
+	switch c.conflictMode {
+	case ConflictModeFail:
+		c.conflicts = append(c.conflicts, conflict)
+		return false, fmt.Errorf("file %s conflicts between packages %s and %s", name, existing.pkg, current.pkg)
+	case ConflictModeOrder:
+		conflict.Picked = current.pkg
+		c.conflicts = append(c.conflicts, conflict)
+		log.Infof("File %s conflicts between packages %s and %s, using the file of %s", name, existing.pkg, current.pkg, current.pkg)
+		return true, nil
+	}
+	c.conflicts = append(c.conflicts, conflict)
+	log.Warnf("File %s conflicts between packages %s and %s, keeping the file of %s", name, existing.pkg, current.pkg, existing.pkg)
+	return false, nil
+}
@@ -0,0 +1,152 @@
+package rpm
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/sassoftware/go-rpmutils/cpio"
+)
+
+type cpioFile struct {
+	name    string
+	mode    int
+	content string
+}
+
+// newc encodes files as a cpio archive in the newc format which is used by RPMs
+func newc(files ...cpioFile) []byte {
+	buf := &bytes.Buffer{}
+	pad := func() {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	files = append(files, cpioFile{name: cpio.TRAILER})
+	for i, f := range files {
+		fmt.Fprintf(buf, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			i+1, f.mode, 0, 0, 1, 0, len(f.content), 0, 0, 0, 0, len(f.name)+1, 0)
+		buf.WriteString(f.name)
+		buf.WriteByte(0)
+		pad()
+		buf.WriteString(f.content)
+		pad()
+	}
+	return buf.Bytes()
+}
+
+func tarEntries(content []byte) (map[string]string, []string) {
+	entries := map[string]string{}
+	names := []string{}
+	reader := tar.NewReader(bytes.NewReader(content))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		data, _ := io.ReadAll(reader)
+		entries[header.Name] = string(data) + header.Linkname
+		names = append(names, header.Name)
+	}
+	return entries, names
+}
+
+func TestFileConflicts(t *testing.T) {
+	first := newc(
+		cpioFile{name: "./etc", mode: cpio.S_ISDIR | 0o755},
+		cpioFile{name: "./etc/shared.conf", mode: cpio.S_ISREG | 0o644, content: "same"},
+		cpioFile{name: "./etc/conflict.conf", mode: cpio.S_ISREG | 0o644, content: "first"},
+		cpioFile{name: "./usr/bin/tool", mode: cpio.S_ISLNK | 0o777, content: "tool-1"},
+	)
+	second := newc(
+		cpioFile{name: "./etc", mode: cpio.S_ISDIR | 0o700},
+		cpioFile{name: "./etc/shared.conf", mode: cpio.S_ISREG | 0o644, content: "same"},
+		cpioFile{name: "./etc/conflict.conf", mode: cpio.S_ISREG | 0o644, content: "second"},
+		cpioFile{name: "./usr/bin/tool", mode: cpio.S_ISLNK | 0o777, content: "tool-2"},
+	)
+	modeOnly := newc(
+		cpioFile{name: "./etc/shared.conf", mode: cpio.S_ISREG | 0o600, content: "same"},
+	)
+
+	tests := []struct {
+		name      string
+		mode      ConflictMode
+		second    []byte
+		expected  map[string]string
+		entries   int
+		conflicts []FileConflict
+		wantErr   bool
+	}{
+		{
+			name:   "should keep the first file and report conflicts",
+			mode:   ConflictModeWarn,
+			second: second,
+			expected: map[string]string{
+				"./etc/shared.conf":   "same",
+				"./etc/conflict.conf": "first",
+				"./usr/bin/tool":      "tool-1",
+			},
+			entries: 4,
+			conflicts: []FileConflict{
+				{Path: "./etc/conflict.conf", Packages: []string{"a", "b"}, Picked: "a"},
+				{Path: "./usr/bin/tool", Packages: []string{"a", "b"}, Picked: "a"},
+			},
+		},
+		{
+			name:   "should take the file of the last package",
+			mode:   ConflictModeOrder,
+			second: second,
+			expected: map[string]string{
+				"./etc/shared.conf":   "same",
+				"./etc/conflict.conf": "second",
+				"./usr/bin/tool":      "tool-2",
+			},
+			// the files of the later package are appended
+			entries: 6,
+			conflicts: []FileConflict{
+				{Path: "./etc/conflict.conf", Packages: []string{"a", "b"}, Picked: "b"},
+				{Path: "./usr/bin/tool", Packages: []string{"a", "b"}, Picked: "b"},
+			},
+		},
+		{
+			name:    "should fail on conflicting content",
+			mode:    ConflictModeFail,
+			second:  second,
+			wantErr: true,
+		},
+		{
+			name:    "should fail on conflicting modes",
+			mode:    ConflictModeFail,
+			second:  modeOnly,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			collector := NewCollector()
+			collector.SetConflictMode(tt.mode)
+			buf := &bytes.Buffer{}
+			writer := tar.NewWriter(buf)
+			g.Expect(Tar(bytes.NewReader(first), writer, false, nil, nil, collector, "a")).To(Succeed())
+			err := Tar(bytes.NewReader(tt.second), writer, false, nil, nil, collector, "b")
+			if tt.wantErr {
+				g.Expect(err).To(MatchError(ContainSubstring("conflicts between packages a and b")))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(writer.Close()).To(Succeed())
+
+			// later entries win when the tar archive is extracted
+			entries, names := tarEntries(buf.Bytes())
+			for name, content := range tt.expected {
+				g.Expect(entries).To(HaveKeyWithValue(name, content))
+			}
+			g.Expect(names).To(HaveLen(tt.entries))
+			g.Expect(collector.Conflicts()).To(Equal(tt.conflicts))
+		})
+	}
+}
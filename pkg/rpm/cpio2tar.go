@@ -18,6 +18,9 @@ package rpm
 
 import (
 	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -26,11 +29,11 @@ import (
 
 	"github.com/rmohr/bazeldnf/pkg/xattr"
 	"github.com/sassoftware/go-rpmutils/cpio"
-	"github.com/sirupsen/logrus"
 )
 
-// Extract the contents of a cpio stream from and writes it as a tar file into the provided writer
-func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities map[string][]string, selinuxLabels map[string]string, createdPaths map[string]struct{}) error {
+// Extract the contents of a cpio stream from and writes it as a tar file into the provided writer.
+// Paths which were already written by previous packages of the collector are checked for conflicts.
+func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities map[string][]string, selinuxLabels map[string]string, collector *Collector, pkg string) error {
 	hardLinks := map[int][]*tar.Header{}
 	inodes := map[int]string{}
 
@@ -46,12 +49,24 @@ func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities
 			break
 		}
 
+		var payload io.Reader = entry.Payload
+		current := &createdPath{pkg: pkg, mode: entry.Header.Mode()}
 		if entry.Header.Filename() != "" {
-			if _, exists := createdPaths[entry.Header.Filename()]; exists {
-				logrus.Debugf("Skipping duplicate tar entry %s\n", entry.Header.Filename())
-				continue
+			if existing, exists := collector.createdPaths[entry.Header.Filename()]; exists {
+				content, err := readDuplicate(entry, current)
+				if err != nil {
+					return fmt.Errorf("could not read %v: %v", entry.Header.Filename(), err)
+				}
+				overwrite, err := collector.checkConflict(entry.Header.Filename(), existing, current)
+				if err != nil {
+					return err
+				}
+				if !overwrite {
+					continue
+				}
+				payload = bytes.NewReader(content)
 			}
-			createdPaths[entry.Header.Filename()] = struct{}{}
+			collector.createdPaths[entry.Header.Filename()] = current
 		}
 
 		pax := map[string]string{}
@@ -78,7 +93,7 @@ func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities
 			PAXRecords: pax,
 		}
 
-		var payload io.Reader
+		var body io.Reader
 		switch entry.Header.Mode() &^ 0o7777 {
 		case cpio.S_ISCHR:
 			tarHeader.Typeflag = tar.TypeChar
@@ -92,15 +107,17 @@ func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities
 		case cpio.S_ISFIFO:
 			tarHeader.Typeflag = tar.TypeFifo
 		case cpio.S_ISLNK:
+			buf, err := ioutil.ReadAll(payload)
+			if err != nil {
+				return err
+			}
+			// remember the target even if the symlink is not written, to detect conflicts
+			current.digest = string(buf)
 			if noSymlinksAndDirs {
 				continue
 			}
 			tarHeader.Typeflag = tar.TypeSymlink
 			tarHeader.Size = 0
-			buf, err := ioutil.ReadAll(entry.Payload)
-			if err != nil {
-				return err
-			}
 			tarHeader.Linkname = string(buf)
 		case cpio.S_ISREG:
 			if entry.Header.Nlink() > 1 && entry.Header.Filesize() == 0 {
@@ -110,7 +127,7 @@ func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities
 				continue
 			}
 			tarHeader.Typeflag = tar.TypeReg
-			payload = entry.Payload
+			body = payload
 			inodes[entry.Header.Ino()] = entry.Header.Filename()
 		default:
 			return fmt.Errorf("unknown file mode 0%o for %s",
@@ -119,14 +136,16 @@ func Tar(rs io.Reader, tarfile *tar.Writer, noSymlinksAndDirs bool, capabilities
 		if err := tarfile.WriteHeader(tarHeader); err != nil {
 			return fmt.Errorf("could not write tar header for %v: %v", tarHeader.Name, err)
 		}
-		if payload != nil {
-			written, err := io.Copy(tarfile, entry.Payload)
+		if body != nil {
+			hash := sha256.New()
+			written, err := io.Copy(tarfile, io.TeeReader(body, hash))
 			if err != nil {
 				return fmt.Errorf("could not write body for %v: %v", tarHeader.Name, err)
 			}
 			if written != int64(entry.Header.Filesize()) {
 				return fmt.Errorf("short write body for %v", tarHeader.Name)
 			}
+			current.digest = hex.EncodeToString(hash.Sum(nil))
 		}
 	}
 	// write hardlinks
@@ -0,0 +1,295 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/sassoftware/go-rpmutils"
+)
+
+// header tags which are not exposed by go-rpmutils
+const (
+	tagConflictFlags    = 1053
+	tagConflictName     = 1054
+	tagConflictVersion  = 1055
+	tagRecommendName    = 5046
+	tagRecommendVersion = 5047
+	tagRecommendFlags   = 5048
+	tagSuggestName      = 5049
+	tagSuggestVersion   = 5050
+	tagSuggestFlags     = 5051
+	tagSupplementName   = 5052
+	tagSupplementVer    = 5053
+	tagSupplementFlags  = 5054
+	tagEnhanceName      = 5055
+	tagEnhanceVersion   = 5056
+	tagEnhanceFlags     = 5057
+)
+
+// dependency flags which are not exposed by go-rpmutils
+const (
+	senseLess       = 1 << 1
+	senseGreater    = 1 << 2
+	senseEqual      = 1 << 3
+	sensePreReq     = 1 << 6
+	senseScriptPre  = 1 << 9
+	senseScriptPost = 1 << 10
+	senseRPMLib     = 1 << 24
+)
+
+// magic numbers of the RPM lead and of header structures
+const (
+	rpmLeadMagic   = 0xedabeedb
+	rpmHeaderMagic = 0x8eade801
+)
+
+const fileTypeMask = 0o170000
+const fileTypeDir = 0o040000
+
+// countingReader keeps track of how many bytes were consumed from the underlying reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// ReadPackage reads the header of a RPM and converts it into the package
+// representation used in primary.xml files. Only files which would also be
+// listed in a primary.xml are attached to the package, the complete file list
+// is returned separately. Fields which depend on the RPM file itself, like
+// the checksum, the location or the package size, are left empty.
+func ReadPackage(rpmReader io.Reader) (*api.Package, []api.ProvidedFile, error) {
+	counter := &countingReader{reader: rpmReader}
+	header, err := rpmutils.ReadHeader(counter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rpm header: %v", err)
+	}
+	pkg, files, err := packageFromHeader(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg.Format.HeaderRange.Start = strconv.Itoa(header.OriginalSignatureHeaderSize())
+	pkg.Format.HeaderRange.End = strconv.FormatInt(counter.count, 10)
+	return pkg, files, nil
+}
+
+// ReadDatabaseHeader converts a header blob as stored in the rpm database of
+// an installed system into a package. Public keys are stored as pseudo
+// packages without an architecture, for those nil is returned.
+func ReadDatabaseHeader(blob []byte) (*api.Package, []api.ProvidedFile, error) {
+	// the database only contains the main header without the introduction,
+	// prepend a lead and an empty signature header to read it like a RPM file
+	lead := make([]byte, 96)
+	binary.BigEndian.PutUint32(lead, rpmLeadMagic)
+	intro := make([]byte, 8)
+	binary.BigEndian.PutUint32(intro, rpmHeaderMagic)
+	emptySignature := append(append([]byte{}, intro...), make([]byte, 8)...)
+
+	stream := io.MultiReader(bytes.NewReader(lead), bytes.NewReader(emptySignature), bytes.NewReader(intro), bytes.NewReader(blob))
+	header, err := rpmutils.ReadHeader(stream)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rpm database header: %v", err)
+	}
+	if !header.HasTag(rpmutils.ARCH) {
+		return nil, nil, nil
+	}
+	return packageFromHeader(header)
+}
+
+func packageFromHeader(header *rpmutils.RpmHeader) (*api.Package, []api.ProvidedFile, error) {
+	nevra, err := header.GetNEVRA()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rpm name: %v", err)
+	}
+
+	pkg := &api.Package{
+		Type: "rpm",
+		Name: nevra.Name,
+		Arch: nevra.Arch,
+		Version: api.Version{
+			Epoch: nevra.Epoch,
+			Ver:   nevra.Version,
+			Rel:   nevra.Release,
+		},
+	}
+	if !header.HasTag(rpmutils.SOURCERPM) {
+		pkg.Arch = "src"
+	}
+	pkg.Summary = optionalString(header, rpmutils.SUMMARY)
+	pkg.Description = optionalString(header, rpmutils.DESCRIPTION)
+	pkg.Packager = optionalString(header, rpmutils.PACKAGER)
+	pkg.URL = optionalString(header, rpmutils.URL)
+	pkg.Format.License = optionalString(header, rpmutils.LICENSE)
+	pkg.Format.Vendor = optionalString(header, rpmutils.VENDOR)
+	pkg.Format.Group = optionalString(header, rpmutils.GROUP)
+	pkg.Format.Buildhost = optionalString(header, rpmutils.BUILDHOST)
+	pkg.Format.Sourcerpm = optionalString(header, rpmutils.SOURCERPM)
+	if buildTime, err := header.GetInt(rpmutils.BUILDTIME); err == nil {
+		pkg.Time.Build = strconv.Itoa(buildTime)
+	}
+	if installed, err := header.InstalledSize(); err == nil {
+		pkg.Size.Installed = int(installed)
+	}
+	if archive, err := header.PayloadSize(); err == nil {
+		pkg.Size.Archive = int(archive)
+	}
+	deps := []struct {
+		target                   *api.Dependencies
+		nameTag, flagTag, verTag int
+	}{
+		{&pkg.Format.Provides, rpmutils.PROVIDENAME, rpmutils.PROVIDEFLAGS, rpmutils.PROVIDEVERSION},
+		{&pkg.Format.Requires, rpmutils.REQUIRENAME, rpmutils.REQUIREFLAGS, rpmutils.REQUIREVERSION},
+		{&pkg.Format.Conflicts, tagConflictName, tagConflictFlags, tagConflictVersion},
+		{&pkg.Format.Obsoletes, rpmutils.OBSOLETENAME, rpmutils.OBSOLETEFLAGS, rpmutils.OBSOLETEVERSION},
+		{&pkg.Format.Recommends, tagRecommendName, tagRecommendFlags, tagRecommendVersion},
+		{&pkg.Format.Suggests, tagSuggestName, tagSuggestFlags, tagSuggestVersion},
+		{&pkg.Format.Supplements, tagSupplementName, tagSupplementFlags, tagSupplementVer},
+		{&pkg.Format.Enhances, tagEnhanceName, tagEnhanceFlags, tagEnhanceVersion},
+	}
+	for _, dep := range deps {
+		entries, err := readDependencies(header, dep.nameTag, dep.flagTag, dep.verTag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read dependencies of %s: %v", nevra.Name, err)
+		}
+		dep.target.Entries = entries
+	}
+
+	files, err := readFiles(header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read files of %s: %v", nevra.Name, err)
+	}
+	for _, file := range files {
+		if isPrimaryFile(file.Text) {
+			pkg.Format.Files = append(pkg.Format.Files, file)
+		}
+	}
+
+	return pkg, files, nil
+}
+
+// ParseVersion parses a version string of the form [epoch:]version[-release]
+func ParseVersion(evr string) api.Version {
+	version := api.Version{}
+	if idx := strings.Index(evr, ":"); idx != -1 {
+		version.Epoch = evr[:idx]
+		evr = evr[idx+1:]
+	}
+	if idx := strings.LastIndex(evr, "-"); idx != -1 {
+		version.Rel = evr[idx+1:]
+		evr = evr[:idx]
+	}
+	version.Ver = evr
+	return version
+}
+
+func optionalString(header *rpmutils.RpmHeader, tag int) string {
+	value, err := header.GetString(tag)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+func readDependencies(header *rpmutils.RpmHeader, nameTag, flagTag, verTag int) ([]api.Entry, error) {
+	if !header.HasTag(nameTag) {
+		return nil, nil
+	}
+	names, err := header.GetStrings(nameTag)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := header.GetInts(flagTag)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := header.GetStrings(verTag)
+	if err != nil {
+		return nil, err
+	}
+	if len(flags) != len(names) || len(versions) != len(names) {
+		return nil, fmt.Errorf("inconsistent dependency information for tag %d", nameTag)
+	}
+
+	var entries []api.Entry
+	seen := map[api.Entry]bool{}
+	for i, name := range names {
+		if flags[i]&senseRPMLib != 0 || strings.HasPrefix(name, "rpmlib(") {
+			continue
+		}
+		entry := api.Entry{Name: name}
+		if versions[i] != "" {
+			entry.Flags = toFlags(flags[i])
+			version := ParseVersion(versions[i])
+			entry.Epoch = version.Epoch
+			if entry.Epoch == "" {
+				entry.Epoch = "0"
+			}
+			entry.Ver = version.Ver
+			entry.Rel = version.Rel
+		}
+		if flags[i]&(sensePreReq|senseScriptPre|senseScriptPost) != 0 {
+			entry.Pre = "1"
+		}
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func toFlags(flags int) string {
+	switch flags & (senseLess | senseGreater | senseEqual) {
+	case senseLess:
+		return "LT"
+	case senseGreater:
+		return "GT"
+	case senseEqual:
+		return "EQ"
+	case senseLess | senseEqual:
+		return "LE"
+	case senseGreater | senseEqual:
+		return "GE"
+	}
+	return ""
+}
+
+func readFiles(header *rpmutils.RpmHeader) ([]api.ProvidedFile, error) {
+	if !header.HasTag(rpmutils.BASENAMES) && !header.HasTag(rpmutils.OLDFILENAMES) {
+		return nil, nil
+	}
+	infos, err := header.GetFiles()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]api.ProvidedFile, 0, len(infos))
+	for _, info := range infos {
+		file := api.ProvidedFile{Text: info.Name()}
+		if info.Flags()&rpmutils.RPMFILE_GHOST != 0 {
+			file.Type = "ghost"
+		} else if info.Mode()&fileTypeMask == fileTypeDir {
+			file.Type = "dir"
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// isPrimaryFile applies the same filter as createrepo to decide if a file is
+// listed in primary.xml and not only in filelists.xml
+func isPrimaryFile(name string) bool {
+	return strings.HasPrefix(name, "/etc/") ||
+		strings.Contains(name, "bin/") ||
+		name == "/usr/lib/sendmail"
+}
@@ -0,0 +1,59 @@
+package rpm
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// LockedPackage returns the name, version and architecture of a locked RPM.
+// They are taken from the package metadata of lock files with schema version 2
+// and newer and from the file name otherwise, which doesn't contain the epoch.
+func LockedPackage(r *bazeldnf.RPM) (*api.PackageKey, error) {
+	if r.Version != "" && r.Release != "" && r.Arch != "" {
+		epoch := r.Epoch
+		if epoch == "" {
+			epoch = "0"
+		}
+		return &api.PackageKey{Name: r.Name, Version: api.Version{Epoch: epoch, Ver: r.Version, Rel: r.Release}, Arch: r.Arch}, nil
+	}
+	if len(r.URLs) == 0 {
+		return nil, fmt.Errorf("RPM %s has no URLs", r.Name)
+	}
+	return ParseFileName(r.URLs[0])
+}
+
+// ParseFileName parses RPM file names or URLs like `name-version-release.arch.rpm`.
+// The epoch of the returned version is empty, file names don't contain it.
+func ParseFileName(u string) (*api.PackageKey, error) {
+	file := path.Base(u)
+	nvra, found := strings.CutSuffix(file, ".rpm")
+	if !found {
+		return nil, fmt.Errorf("%s is not an RPM file", file)
+	}
+	archIdx := strings.LastIndex(nvra, ".")
+	if archIdx == -1 {
+		return nil, fmt.Errorf("can't determine the architecture of %s", file)
+	}
+	parts := strings.Split(nvra[:archIdx], "-")
+	if len(parts) < 3 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return nil, fmt.Errorf("can't determine the version of %s", file)
+	}
+	return &api.PackageKey{
+		Name:    strings.Join(parts[:len(parts)-2], "-"),
+		Version: api.Version{Ver: parts[len(parts)-2], Rel: parts[len(parts)-1]},
+		Arch:    nvra[archIdx+1:],
+	}, nil
+}
+
+// EVR returns the version and release, with the epoch if it is known and not 0
+func EVR(version api.Version) string {
+	evr := version.Ver + "-" + version.Rel
+	if version.Epoch != "" && version.Epoch != "0" {
+		evr = version.Epoch + ":" + evr
+	}
+	return evr
+}
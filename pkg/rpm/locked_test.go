@@ -0,0 +1,40 @@
+package rpm
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestParseFileName(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	key, err := ParseFileName("https://example.com/Packages/l/libvirt-daemon-driver-qemu-11.0.0-1.fc42.x86_64.rpm")
+	g.Expect(err).Should(BeNil())
+	g.Expect(key).Should(Equal(&api.PackageKey{Name: "libvirt-daemon-driver-qemu", Version: api.Version{Ver: "11.0.0", Rel: "1.fc42"}, Arch: "x86_64"}))
+
+	_, err = ParseFileName("https://example.com/libvirt.tar.gz")
+	g.Expect(err).Should(HaveOccurred())
+	_, err = ParseFileName("https://example.com/libvirt.x86_64.rpm")
+	g.Expect(err).Should(HaveOccurred())
+}
+
+func TestLockedPackage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	locked := &bazeldnf.RPM{Name: "libfoo", URLs: []string{"Packages/libfoo-1.0-1.fc44.x86_64.rpm"}}
+	key, err := LockedPackage(locked)
+	g.Expect(err).Should(BeNil())
+	g.Expect(key).Should(Equal(&api.PackageKey{Name: "libfoo", Version: api.Version{Ver: "1.0", Rel: "1.fc44"}, Arch: "x86_64"}))
+
+	locked.Epoch, locked.Version, locked.Release, locked.Arch = "2", "1.0", "1.fc44", "x86_64"
+	key, err = LockedPackage(locked)
+	g.Expect(err).Should(BeNil())
+	g.Expect(key.Version).Should(Equal(api.Version{Epoch: "2", Ver: "1.0", Rel: "1.fc44"}))
+	g.Expect(EVR(key.Version)).Should(Equal("2:1.0-1.fc44"))
+
+	_, err = LockedPackage(&bazeldnf.RPM{Name: "libfoo"})
+	g.Expect(err).Should(HaveOccurred())
+}
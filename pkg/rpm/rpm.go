@@ -11,7 +11,7 @@ import (
 func ComparePackage(a *api.Package, b *api.Package, archOrder []string) int {
 	return cmp.Or(
 		CompareArch(a.Arch, b.Arch, archOrder),
-		b.Repository.Priority-a.Repository.Priority,
+		b.Repository.EffectivePriority()-a.Repository.EffectivePriority(),
 		Compare(a.Version, b.Version),
 	)
 }
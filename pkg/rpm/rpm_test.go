@@ -1,10 +1,12 @@
 package rpm
 
 import (
+	"cmp"
 	"reflect"
 	"testing"
 
 	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 )
 
 func TestTokenizer_NextToken(t *testing.T) {
@@ -204,3 +206,56 @@ func TestCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestComparePackage(t *testing.T) {
+	newPackage := func(ver string, repository *bazeldnf.Repository) *api.Package {
+		return &api.Package{Name: "bash", Arch: "x86_64", Version: api.Version{Epoch: "0", Ver: ver}, Repository: repository}
+	}
+	fedora := &bazeldnf.Repository{Name: "fedora"}
+	updates := &bazeldnf.Repository{Name: "updates"}
+	tests := []struct {
+		name string
+		a    *api.Package
+		b    *api.Package
+		want int
+	}{
+		{
+			// without priorities only the version decides, like before priorities defaulted to 99
+			name: "No priorities, version less",
+			a:    newPackage("5.1", fedora),
+			b:    newPackage("5.2", updates),
+			want: -1,
+		},
+		{
+			name: "No priorities, version equal",
+			a:    newPackage("5.2", fedora),
+			b:    newPackage("5.2", updates),
+			want: 0,
+		},
+		{
+			name: "No priorities, installed package",
+			a:    newPackage("5.2", nil),
+			b:    newPackage("5.1", updates),
+			want: 1,
+		},
+		{
+			name: "Default priority",
+			a:    newPackage("5.1", &bazeldnf.Repository{Name: "fedora", Priority: bazeldnf.DefaultPriority}),
+			b:    newPackage("5.2", updates),
+			want: -1,
+		},
+		{
+			name: "Higher priority than the default",
+			a:    newPackage("5.1", &bazeldnf.Repository{Name: "internal", Priority: 10}),
+			b:    newPackage("5.2", updates),
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComparePackage(tt.a, tt.b, []string{"x86_64"}); cmp.Compare(got, 0) != tt.want {
+				t.Errorf("ComparePackage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
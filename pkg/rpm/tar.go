@@ -16,25 +16,44 @@ import (
 )
 
 type Collector struct {
-	createdPaths map[string]struct{}
+	createdPaths map[string]*createdPath
+	conflictMode ConflictMode
+	conflicts    []FileConflict
 }
 
 func NewCollector() *Collector {
 	return &Collector{
-		createdPaths: make(map[string]struct{}),
+		createdPaths: make(map[string]*createdPath),
+		conflictMode: ConflictModeWarn,
 	}
 }
 
+// SetConflictMode decides how files which are contained in multiple packages
+// with a different content or mode are handled
+func (c *Collector) SetConflictMode(mode ConflictMode) {
+	c.conflictMode = mode
+}
+
+// Conflicts returns all file conflicts which were found so far
+func (c *Collector) Conflicts() []FileConflict {
+	return c.conflicts
+}
+
 func (c *Collector) RPMToTar(rpmReader io.Reader, tarWriter *tar.Writer, noSymlinksAndDirs bool, capabilities map[string][]string, selinuxLabels map[string]string) error {
 	rpm, err := rpmutils.ReadRpm(rpmReader)
 	if err != nil {
 		return fmt.Errorf("failed to read rpm: %s", err)
 	}
+	nevra, err := rpm.Header.GetNEVRA()
+	if err != nil {
+		return fmt.Errorf("failed to read rpm name: %s", err)
+	}
 	payloadReader, err := rpm.RawUncompressedRPMPayloadReader()
 	if err != nil {
 		return fmt.Errorf("failed to open the payload reader: %s", err)
 	}
-	return Tar(payloadReader, tarWriter, noSymlinksAndDirs, capabilities, selinuxLabels, c.createdPaths)
+	pkg := fmt.Sprintf("%s-%s-%s.%s", nevra.Name, nevra.Version, nevra.Release, nevra.Arch)
+	return Tar(payloadReader, tarWriter, noSymlinksAndDirs, capabilities, selinuxLabels, c, pkg)
 }
 
 func RPMToCPIO(rpmReader io.Reader) (*cpio.CpioStream, error) {
@@ -0,0 +1,127 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// layout of BerkeleyDB hash databases, see dbinc/db_page.h in BerkeleyDB
+const (
+	bdbHashMagic = 0x061561
+
+	bdbPageHeaderSize = 26
+
+	bdbPageTypeHashUnsorted = 2
+	bdbPageTypeOverflow     = 7
+	bdbPageTypeHash         = 13
+
+	bdbItemKeyData = 1
+	bdbItemOffPage = 3
+)
+
+// readBdb returns the header blobs of all packages in a BerkeleyDB Packages
+// file. Every package is stored under its header number as key, the header
+// itself is stored on overflow pages. The record with the header number 0
+// holds the next free header number and is skipped.
+func readBdb(content []byte) ([][]byte, error) {
+	if len(content) < 512 {
+		return nil, fmt.Errorf("file is too small for a BerkeleyDB database")
+	}
+	// the byte order depends on the host which created the database
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(content[12:]) == bdbHashMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(content[12:]) == bdbHashMagic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a BerkeleyDB hash database")
+	}
+	pageSize := int(order.Uint32(content[20:]))
+	if pageSize < 512 {
+		return nil, fmt.Errorf("invalid BerkeleyDB page size %d", pageSize)
+	}
+	if content[24] != 0 {
+		return nil, fmt.Errorf("encrypted BerkeleyDB databases are not supported")
+	}
+	lastPage := int(order.Uint32(content[32:]))
+
+	page := func(pageNo int) ([]byte, error) {
+		start := pageNo * pageSize
+		if pageNo <= 0 || pageNo > lastPage || start+pageSize > len(content) {
+			return nil, fmt.Errorf("BerkeleyDB page %d is out of range", pageNo)
+		}
+		return content[start : start+pageSize], nil
+	}
+
+	blobs := [][]byte{}
+	for pageNo := 1; pageNo <= lastPage; pageNo++ {
+		current, err := page(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		pageType := current[25]
+		if pageType != bdbPageTypeHash && pageType != bdbPageTypeHashUnsorted {
+			continue
+		}
+		entries := int(order.Uint16(current[20:]))
+		if bdbPageHeaderSize+2*entries > pageSize {
+			return nil, fmt.Errorf("BerkeleyDB page %d has too many entries", pageNo)
+		}
+		// entries alternate between keys and values
+		for i := 1; i < entries; i += 2 {
+			keyOffset := int(order.Uint16(current[bdbPageHeaderSize+2*(i-1):]))
+			offset := int(order.Uint16(current[bdbPageHeaderSize+2*i:]))
+			if offset >= pageSize || keyOffset+5 > pageSize {
+				return nil, fmt.Errorf("BerkeleyDB entry %d of page %d is out of range", i, pageNo)
+			}
+			if current[keyOffset] == bdbItemKeyData && order.Uint32(current[keyOffset+1:]) == 0 {
+				continue
+			}
+			switch current[offset] {
+			case bdbItemOffPage:
+				if offset+12 > pageSize {
+					return nil, fmt.Errorf("BerkeleyDB entry %d of page %d is out of range", i, pageNo)
+				}
+				blob, err := readBdbOverflow(page, order, int(order.Uint32(current[offset+4:])), int(order.Uint32(current[offset+8:])))
+				if err != nil {
+					return nil, err
+				}
+				blobs = append(blobs, blob)
+			case bdbItemKeyData:
+				// items are stored from the end of the page, a value ends where its key starts
+				end := int(order.Uint16(current[bdbPageHeaderSize+2*(i-1):]))
+				if end < offset+1 || end > pageSize {
+					return nil, fmt.Errorf("BerkeleyDB entry %d of page %d is out of range", i, pageNo)
+				}
+				blobs = append(blobs, current[offset+1:end])
+			}
+		}
+	}
+	return blobs, nil
+}
+
+// readBdbOverflow concatenates the content of a chain of overflow pages
+func readBdbOverflow(page func(int) ([]byte, error), order binary.ByteOrder, pageNo int, length int) ([]byte, error) {
+	blob := make([]byte, 0, length)
+	for pageNo != 0 {
+		current, err := page(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		if current[25] != bdbPageTypeOverflow {
+			return nil, fmt.Errorf("BerkeleyDB page %d is not an overflow page", pageNo)
+		}
+		// the free area offset holds the number of used bytes on overflow pages
+		used := int(order.Uint16(current[22:]))
+		if bdbPageHeaderSize+used > len(current) {
+			return nil, fmt.Errorf("BerkeleyDB overflow page %d is out of range", pageNo)
+		}
+		blob = append(blob, current[bdbPageHeaderSize:bdbPageHeaderSize+used]...)
+		pageNo = int(order.Uint32(current[16:]))
+	}
+	if len(blob) != length {
+		return nil, fmt.Errorf("expected %d bytes in BerkeleyDB overflow pages, but got %d", length, len(blob))
+	}
+	return blob, nil
+}
@@ -0,0 +1,303 @@
+package rpmdb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+// databaseDirs are the locations of the rpm database in an image, in the order
+// of preference. Newer distributions keep the database in /usr and link it to
+// /var/lib/rpm.
+var databaseDirs = []string{"usr/lib/sysimage/rpm", "var/lib/rpm"}
+
+// databaseFiles are the database files of the supported backends, in the
+// order of preference
+var databaseFiles = []string{"rpmdb.sqlite", "Packages.db", "Packages"}
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// archive gives access to the files of an image tarball or an OCI layout directory
+type archive interface {
+	open(name string) (io.ReadCloser, error)
+}
+
+type dirArchive string
+
+func (d dirArchive) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+type tarArchive string
+
+// open scans the tarball for the file. Tarballs of images only have a few
+// entries and archive/tar seeks over the content of skipped entries.
+func (t tarArchive) open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(string(t))
+	if err != nil {
+		return nil, err
+	}
+	reader := tar.NewReader(f)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read %s: %v", t, err)
+		}
+		if cleanPath(header.Name) == name {
+			return struct {
+				io.Reader
+				io.Closer
+			}{reader, f}, nil
+		}
+	}
+	f.Close()
+	return nil, fmt.Errorf("%s not found in %s", name, t)
+}
+
+type dockerManifest struct {
+	Layers []string `json:"Layers"`
+}
+
+type ociDescriptor struct {
+	MediaType string       `json:"mediaType"`
+	Digest    string       `json:"digest"`
+	Platform  *ociPlatform `json:"platform,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// ociArchitectures maps rpm architectures to the names used in OCI platforms
+var ociArchitectures = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"i686":    "386",
+}
+
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func readJSON(a archive, name string, v interface{}) error {
+	reader, err := a.open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+func blobPath(digest string) (string, error) {
+	algorithm, hash, ok := strings.Cut(digest, ":")
+	if !ok || algorithm == "" || hash == "" {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return path.Join("blobs", algorithm, hash), nil
+}
+
+// layers returns the layers of the image in the order in which they are applied.
+// Docker archives are described by manifest.json, OCI layouts by index.json.
+func layers(a archive, arch string) ([]string, error) {
+	manifests := []dockerManifest{}
+	if err := readJSON(a, "manifest.json", &manifests); err == nil {
+		if len(manifests) != 1 {
+			return nil, fmt.Errorf("expected exactly one image in the archive, but found %d", len(manifests))
+		}
+		return manifests[0].Layers, nil
+	}
+
+	index := ociIndex{}
+	if err := readJSON(a, "index.json", &index); err != nil {
+		return nil, fmt.Errorf("neither manifest.json nor index.json could be read: %v", err)
+	}
+	// follow nested indexes until the manifest of the image is reached
+	for len(index.Manifests) > 0 {
+		descriptor, err := selectManifest(index.Manifests, arch)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := blobPath(descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+		index = ociIndex{}
+		if err := readJSON(a, blob, &index); err != nil {
+			return nil, err
+		}
+	}
+	paths := []string{}
+	for _, layer := range index.Layers {
+		blob, err := blobPath(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, blob)
+	}
+	return paths, nil
+}
+
+func selectManifest(manifests []ociDescriptor, arch string) (*ociDescriptor, error) {
+	if len(manifests) == 1 {
+		return &manifests[0], nil
+	}
+	platform := ociArchitectures[arch]
+	for i, manifest := range manifests {
+		if manifest.Platform != nil && manifest.Platform.Architecture == platform {
+			return &manifests[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no image for architecture %s found in the index", arch)
+}
+
+// isDatabaseFile returns true for the files of all supported database backends
+func isDatabaseFile(name string) bool {
+	dir, file := path.Split(name)
+	for _, d := range databaseDirs {
+		if strings.TrimSuffix(dir, "/") == d {
+			for _, f := range databaseFiles {
+				if f == file {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// decompress detects the compression of a layer by its magic bytes
+func decompress(reader io.Reader) (io.Reader, func(), error) {
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zst, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zst, zst.Close, nil
+	}
+	return buffered, func() {}, nil
+}
+
+// applyLayer applies the database files and whiteouts of a layer to the
+// files of the lower layers
+func applyLayer(files map[string][]byte, layer io.Reader) error {
+	reader, closer, err := decompress(layer)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	added := map[string][]byte{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		name := cleanPath(header.Name)
+		dir, file := path.Split(name)
+		if file == whiteoutOpaque {
+			for existing := range files {
+				if strings.HasPrefix(existing, dir) {
+					delete(files, existing)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(file, whiteoutPrefix) {
+			removed := dir + strings.TrimPrefix(file, whiteoutPrefix)
+			for existing := range files {
+				if existing == removed || strings.HasPrefix(existing, removed+"/") {
+					delete(files, existing)
+				}
+			}
+			continue
+		}
+		if header.Typeflag != tar.TypeReg || !isDatabaseFile(name) {
+			continue
+		}
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return err
+		}
+		added[name] = content
+	}
+	for name, content := range added {
+		files[name] = content
+	}
+	return nil
+}
+
+// extractDatabase returns the files of the rpm database of the image
+func extractDatabase(a archive, arch string) (map[string][]byte, error) {
+	layerPaths, err := layers(a, arch)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, layerPath := range layerPaths {
+		logrus.Debugf("Applying layer %s", layerPath)
+		reader, err := a.open(layerPath)
+		if err != nil {
+			return nil, err
+		}
+		err = applyLayer(files, reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer %s: %v", layerPath, err)
+		}
+	}
+	return files, nil
+}
+
+func openImage(image string) (archive, error) {
+	info, err := os.Stat(image)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirArchive(image), nil
+	}
+	return tarArchive(image), nil
+}
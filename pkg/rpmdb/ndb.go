@@ -0,0 +1,67 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// layout of the ndb backend of rpm, see lib/backend/ndb/rpmpkg.c in rpm
+const (
+	ndbHeaderMagic = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	ndbSlotMagic   = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	ndbBlobMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+
+	ndbVersion         = 0
+	ndbSlotPageSize    = 4096
+	ndbSlotSize        = 16
+	ndbBlockSize       = 16
+	ndbBlobHeaderSize  = 16
+	ndbFileHeaderSlots = 2
+)
+
+// readNdb returns the header blobs of all packages in a Packages.db file
+func readNdb(content []byte) ([][]byte, error) {
+	if len(content) < ndbSlotPageSize {
+		return nil, fmt.Errorf("file is too small for a ndb database")
+	}
+	le := binary.LittleEndian
+	if le.Uint32(content[0:]) != ndbHeaderMagic {
+		return nil, fmt.Errorf("invalid ndb header magic")
+	}
+	if version := le.Uint32(content[4:]); version != ndbVersion {
+		return nil, fmt.Errorf("unsupported ndb version %d", version)
+	}
+	slotPages := int(le.Uint32(content[12:]))
+	if slotPages*ndbSlotPageSize > len(content) {
+		return nil, fmt.Errorf("ndb database is truncated")
+	}
+
+	blobs := [][]byte{}
+	// the first slots of the first page are occupied by the file header
+	for slot := ndbFileHeaderSlots; slot < slotPages*ndbSlotPageSize/ndbSlotSize; slot++ {
+		entry := content[slot*ndbSlotSize:]
+		if le.Uint32(entry[0:]) != ndbSlotMagic {
+			return nil, fmt.Errorf("invalid ndb slot magic in slot %d", slot)
+		}
+		pkgIndex := le.Uint32(entry[4:])
+		if pkgIndex == 0 {
+			// unused slot
+			continue
+		}
+		offset := int(le.Uint32(entry[8:])) * ndbBlockSize
+		if offset+ndbBlobHeaderSize > len(content) {
+			return nil, fmt.Errorf("ndb blob of package %d is out of range", pkgIndex)
+		}
+		blobHeader := content[offset:]
+		if le.Uint32(blobHeader[0:]) != ndbBlobMagic || le.Uint32(blobHeader[4:]) != pkgIndex {
+			return nil, fmt.Errorf("invalid ndb blob header of package %d", pkgIndex)
+		}
+		length := int(le.Uint32(blobHeader[12:]))
+		start := offset + ndbBlobHeaderSize
+		if start+length > len(content) {
+			return nil, fmt.Errorf("ndb blob of package %d is truncated", pkgIndex)
+		}
+		blobs = append(blobs, content[start:start+length])
+	}
+	return blobs, nil
+}
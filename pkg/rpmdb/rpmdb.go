@@ -0,0 +1,96 @@
+// Package rpmdb reads the packages which are installed in a container image
+// from its rpm database. The sqlite, ndb and BerkeleyDB backends of rpm are
+// supported.
+package rpmdb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
+)
+
+// ReadImage returns the packages installed in an image. The image can be an
+// image tarball as written by `docker save` or `podman save`, or an OCI layout
+// as a directory or a tarball. For multi architecture images the image of the
+// given architecture is used.
+func ReadImage(image string, arch string) ([]*api.Package, error) {
+	a, err := openImage(image)
+	if err != nil {
+		return nil, err
+	}
+	files, err := extractDatabase(a, arch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %v", image, err)
+	}
+	return readDatabaseFiles(files, "image "+image)
+}
+
+// ReadTar returns the packages installed in a root filesystem tarball, like
+// the tarball of a single image layer. The tarball may be compressed.
+func ReadTar(tarball string) ([]*api.Package, error) {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	files := map[string][]byte{}
+	if err := applyLayer(files, f); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", tarball, err)
+	}
+	return readDatabaseFiles(files, tarball)
+}
+
+// readDatabaseFiles reads the preferred rpm database of the extracted files
+func readDatabaseFiles(files map[string][]byte, source string) ([]*api.Package, error) {
+	for _, dir := range databaseDirs {
+		for _, file := range databaseFiles {
+			name := path.Join(dir, file)
+			if content, exists := files[name]; exists {
+				logrus.Infof("Reading rpm database /%s of %s", name, source)
+				return ReadDatabase(file, content)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s contains no rpm database", source)
+}
+
+// ReadDatabase returns the packages of a rpm database. The backend is detected
+// from the file name: rpmdb.sqlite, Packages.db (ndb) or Packages (BerkeleyDB).
+func ReadDatabase(file string, content []byte) ([]*api.Package, error) {
+	var blobs [][]byte
+	var err error
+	switch file {
+	case "rpmdb.sqlite":
+		blobs, err = readSqlite(content)
+	case "Packages.db":
+		blobs, err = readNdb(content)
+	case "Packages":
+		blobs, err = readBdb(content)
+	default:
+		return nil, fmt.Errorf("unsupported rpm database %s", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rpm database %s: %v", file, err)
+	}
+
+	packages := []*api.Package{}
+	for _, blob := range blobs {
+		pkg, _, err := rpm.ReadDatabaseHeader(blob)
+		if err != nil {
+			return nil, err
+		}
+		if pkg == nil {
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].String() < packages[j].String()
+	})
+	return packages, nil
+}
@@ -0,0 +1,391 @@
+package rpmdb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+type headerTag struct {
+	tag     int32
+	strings []string
+	ints    []int32
+}
+
+// header encodes a header blob like rpm stores it in its database
+func header(tags ...headerTag) []byte {
+	entries := &bytes.Buffer{}
+	data := &bytes.Buffer{}
+	for _, t := range tags {
+		dataType, count := int32(6), len(t.strings)
+		if t.ints != nil {
+			dataType, count = 4, len(t.ints)
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+		} else if len(t.strings) > 1 || t.tag == 1047 || t.tag == 1113 {
+			dataType = 8
+		}
+		binary.Write(entries, binary.BigEndian, []int32{t.tag, dataType, int32(data.Len()), int32(count)})
+		for _, s := range t.strings {
+			data.WriteString(s)
+			data.WriteByte(0)
+		}
+		binary.Write(data, binary.BigEndian, t.ints)
+	}
+	blob := &bytes.Buffer{}
+	binary.Write(blob, binary.BigEndian, []int32{int32(len(tags)), int32(data.Len())})
+	blob.Write(entries.Bytes())
+	blob.Write(data.Bytes())
+	return blob.Bytes()
+}
+
+func packageHeader(name, version, release string) []byte {
+	return header(
+		headerTag{tag: 1000, strings: []string{name}},
+		headerTag{tag: 1001, strings: []string{version}},
+		headerTag{tag: 1002, strings: []string{release}},
+		headerTag{tag: 1022, strings: []string{"x86_64"}},
+		headerTag{tag: 1044, strings: []string{fmt.Sprintf("%s-%s-%s.src.rpm", name, version, release)}},
+		headerTag{tag: 1047, strings: []string{name}},
+		headerTag{tag: 1112, ints: []int32{8}},
+		headerTag{tag: 1113, strings: []string{version + "-" + release}},
+	)
+}
+
+func publicKeyHeader() []byte {
+	return header(
+		headerTag{tag: 1000, strings: []string{"gpg-pubkey"}},
+		headerTag{tag: 1001, strings: []string{"abcdef"}},
+		headerTag{tag: 1002, strings: []string{"1"}},
+	)
+}
+
+// ndb encodes a Packages.db file with the given header blobs
+func ndb(blobs ...[]byte) []byte {
+	db := make([]byte, ndbSlotPageSize)
+	le := binary.LittleEndian
+	le.PutUint32(db[0:], ndbHeaderMagic)
+	le.PutUint32(db[12:], 1)
+	for slot := ndbFileHeaderSlots; slot < ndbSlotPageSize/ndbSlotSize; slot++ {
+		le.PutUint32(db[slot*ndbSlotSize:], ndbSlotMagic)
+	}
+	for i, blob := range blobs {
+		slot := db[(ndbFileHeaderSlots+i)*ndbSlotSize:]
+		le.PutUint32(slot[4:], uint32(i+1))
+		le.PutUint32(slot[8:], uint32(len(db)/ndbBlockSize))
+		blobHeader := make([]byte, ndbBlobHeaderSize)
+		le.PutUint32(blobHeader[0:], ndbBlobMagic)
+		le.PutUint32(blobHeader[4:], uint32(i+1))
+		le.PutUint32(blobHeader[12:], uint32(len(blob)))
+		db = append(db, blobHeader...)
+		db = append(db, blob...)
+		for len(db)%ndbBlockSize != 0 {
+			db = append(db, 0)
+		}
+	}
+	return db
+}
+
+// bdb encodes a BerkeleyDB hash database with one hash page. The first blob
+// is stored inline, all others on overflow pages.
+func bdb(blobs ...[]byte) []byte {
+	const pageSize = 512
+	order := binary.BigEndian
+	pages := [][]byte{make([]byte, pageSize), make([]byte, pageSize)}
+	meta, hash := pages[0], pages[1]
+	order.PutUint32(meta[12:], bdbHashMagic)
+	order.PutUint32(meta[20:], pageSize)
+	hash[25] = bdbPageTypeHash
+
+	free := pageSize
+	addItem := func(index int, item []byte) {
+		free -= len(item)
+		copy(hash[free:], item)
+		order.PutUint16(hash[bdbPageHeaderSize+2*index:], uint16(free))
+	}
+	for i, blob := range blobs {
+		key := make([]byte, 5)
+		key[0] = bdbItemKeyData
+		order.PutUint32(key[1:], uint32(i+1))
+		addItem(2*i, key)
+		if i == 0 {
+			addItem(2*i+1, append([]byte{bdbItemKeyData}, blob...))
+			continue
+		}
+		offPage := make([]byte, 12)
+		offPage[0] = bdbItemOffPage
+		order.PutUint32(offPage[4:], uint32(len(pages)))
+		order.PutUint32(offPage[8:], uint32(len(blob)))
+		addItem(2*i+1, offPage)
+		for len(blob) > 0 {
+			overflow := make([]byte, pageSize)
+			overflow[25] = bdbPageTypeOverflow
+			used := copy(overflow[bdbPageHeaderSize:], blob)
+			blob = blob[used:]
+			order.PutUint16(overflow[22:], uint16(used))
+			if len(blob) > 0 {
+				order.PutUint32(overflow[16:], uint32(len(pages)+1))
+			}
+			pages = append(pages, overflow)
+		}
+	}
+	order.PutUint16(hash[20:], uint16(2*len(blobs)))
+	order.PutUint32(meta[32:], uint32(len(pages)-1))
+	return bytes.Join(pages, nil)
+}
+
+func names(packages []*api.Package) []string {
+	result := []string{}
+	for _, pkg := range packages {
+		result = append(result, pkg.String())
+	}
+	return result
+}
+
+func TestReadDatabase(t *testing.T) {
+	// a package with a summary which spans multiple overflow pages
+	large := header(
+		headerTag{tag: 1000, strings: []string{"large"}},
+		headerTag{tag: 1001, strings: []string{"1.0"}},
+		headerTag{tag: 1002, strings: []string{"1.fc44"}},
+		headerTag{tag: 1004, strings: []string{string(bytes.Repeat([]byte("large "), 300))}},
+		headerTag{tag: 1022, strings: []string{"x86_64"}},
+		headerTag{tag: 1044, strings: []string{"large-1.0-1.fc44.src.rpm"}},
+	)
+
+	tests := []struct {
+		name     string
+		file     string
+		content  []byte
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "should read a ndb database",
+			file:     "Packages.db",
+			content:  ndb(packageHeader("bash", "5.2.37", "1.fc44"), publicKeyHeader(), packageHeader("glibc", "2.41", "3.fc44")),
+			expected: []string{"bash-0:5.2.37-1.fc44.x86_64", "glibc-0:2.41-3.fc44.x86_64"},
+		},
+		{
+			name:     "should read a BerkeleyDB database",
+			file:     "Packages",
+			content:  bdb(packageHeader("bash", "5.2.37", "1.fc44"), large, publicKeyHeader()),
+			expected: []string{"bash-0:5.2.37-1.fc44.x86_64", "large-0:1.0-1.fc44.x86_64"},
+		},
+		{
+			name:    "should fail on an unknown database",
+			file:    "Packages.db",
+			content: bdb(packageHeader("bash", "5.2.37", "1.fc44")),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			packages, err := ReadDatabase(tt.file, tt.content)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(names(packages)).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestReadSqliteFixture(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// testdata/rpmdb.sqlite was created with the sqlite3 CLI and a page size
+	// of 1024. It contains the packages pkg00 to pkg49 in version 1.<n>, where
+	// every tenth package has a summary which does not fit on a page, and a
+	// public key.
+	content, err := os.ReadFile("testdata/rpmdb.sqlite")
+	g.Expect(err).ToNot(HaveOccurred())
+	packages, err := ReadDatabase("rpmdb.sqlite", content)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(packages).To(HaveLen(50))
+	for i, pkg := range packages {
+		g.Expect(pkg.Name).To(Equal(fmt.Sprintf("pkg%02d", i)))
+		g.Expect(pkg.Version).To(Equal(api.Version{Epoch: "0", Ver: fmt.Sprintf("1.%d", i), Rel: "1.fc44"}))
+		g.Expect(pkg.Format.Provides.Entries).To(ConsistOf(api.Entry{Name: pkg.Name, Flags: "EQ", Epoch: "0", Ver: pkg.Version.Ver, Rel: "1.fc44"}))
+	}
+	g.Expect(packages[10].Summary).To(HavePrefix("large package"))
+}
+
+// The ndb and BerkeleyDB fixtures were written by rpm itself and are taken
+// from the testdata of github.com/knqyf263/go-rpmdb (MIT licensed).
+func TestReadRPMFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		packages int
+		expected api.Version
+		pkgName  string
+		license  string
+	}{
+		{
+			// SLE 15 BCI base image
+			name:     "should read a ndb database written by rpm",
+			file:     "Packages.db",
+			packages: 35,
+			pkgName:  "bash",
+			expected: api.Version{Epoch: "0", Ver: "4.4", Rel: "19.6.1"},
+			license:  "GPL-3.0-or-later",
+		},
+		{
+			// RHEL 8 with only libuuid installed, contains the record with the
+			// next free header number
+			name:     "should read a BerkeleyDB database written by rpm",
+			file:     "Packages",
+			packages: 1,
+			pkgName:  "libuuid",
+			expected: api.Version{Epoch: "0", Ver: "2.32.1", Rel: "42.el8_8"},
+			license:  "BSD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			g.Expect(err).ToNot(HaveOccurred())
+			packages, err := ReadDatabase(tt.file, content)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(packages).To(HaveLen(tt.packages))
+			var pkg *api.Package
+			for _, p := range packages {
+				if p.Name == tt.pkgName {
+					pkg = p
+				}
+			}
+			g.Expect(pkg).ToNot(BeNil())
+			g.Expect(pkg.Version).To(Equal(tt.expected))
+			g.Expect(pkg.Arch).To(Equal("x86_64"))
+			g.Expect(pkg.Format.License).To(Equal(tt.license))
+			g.Expect(pkg.Format.Provides.Entries).ToNot(BeEmpty())
+		})
+	}
+}
+
+type tarFile struct {
+	name    string
+	content []byte
+}
+
+func tarball(files ...tarFile) []byte {
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+	for _, f := range files {
+		writer.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg})
+		writer.Write(f.content)
+	}
+	writer.Close()
+	return buf.Bytes()
+}
+
+func gzipped(content []byte) []byte {
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	writer.Write(content)
+	writer.Close()
+	return buf.Bytes()
+}
+
+func mustJSON(v interface{}) []byte {
+	content, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// testLayers returns a base layer with a database in /var/lib/rpm, and a layer
+// which removes it and adds a database in /usr/lib/sysimage/rpm
+func testLayers() [][]byte {
+	return [][]byte{
+		gzipped(tarball(
+			tarFile{"var/lib/rpm/Packages.db", ndb(packageHeader("bash", "5.2.37", "1.fc44"))},
+			tarFile{"etc/os-release", []byte("ID=fedora")},
+		)),
+		tarball(
+			tarFile{"var/lib/rpm/.wh.Packages.db", nil},
+			tarFile{"./usr/lib/sysimage/rpm/Packages.db", ndb(packageHeader("bash", "5.2.37", "2.fc44"), packageHeader("glibc", "2.41", "3.fc44"))},
+		),
+	}
+}
+
+func TestReadImage(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir := t.TempDir()
+	expected := []string{"bash-0:5.2.37-2.fc44.x86_64", "glibc-0:2.41-3.fc44.x86_64"}
+
+	// docker save archive
+	files := []tarFile{}
+	layerNames := []string{}
+	for i, layer := range testLayers() {
+		name := fmt.Sprintf("layer%d/layer.tar", i)
+		layerNames = append(layerNames, name)
+		files = append(files, tarFile{name, layer})
+	}
+	files = append(files, tarFile{"manifest.json", mustJSON([]dockerManifest{{Layers: layerNames}})})
+	dockerArchive := filepath.Join(dir, "docker.tar")
+	g.Expect(os.WriteFile(dockerArchive, tarball(files...), 0644)).To(Succeed())
+	packages, err := ReadImage(dockerArchive, "x86_64")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(names(packages)).To(Equal(expected))
+
+	// multi architecture OCI layout
+	layout := filepath.Join(dir, "oci")
+	writeBlob := func(content []byte) string {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+		path := filepath.Join(layout, "blobs", "sha256", digest[len("sha256:"):])
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		g.Expect(os.WriteFile(path, content, 0644)).To(Succeed())
+		return digest
+	}
+	manifest := ociIndex{}
+	for _, layer := range testLayers() {
+		manifest.Layers = append(manifest.Layers, ociDescriptor{Digest: writeBlob(layer)})
+	}
+	armManifest := ociIndex{Layers: []ociDescriptor{{Digest: writeBlob(tarball())}}}
+	imageIndex := ociIndex{Manifests: []ociDescriptor{
+		{Digest: writeBlob(mustJSON(armManifest)), Platform: &ociPlatform{Architecture: "arm64", OS: "linux"}},
+		{Digest: writeBlob(mustJSON(manifest)), Platform: &ociPlatform{Architecture: "amd64", OS: "linux"}},
+	}}
+	index := ociIndex{Manifests: []ociDescriptor{{Digest: writeBlob(mustJSON(imageIndex))}}}
+	g.Expect(os.WriteFile(filepath.Join(layout, "index.json"), mustJSON(index), 0644)).To(Succeed())
+	packages, err = ReadImage(layout, "x86_64")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(names(packages)).To(Equal(expected))
+
+	_, err = ReadImage(layout, "aarch64")
+	g.Expect(err).To(MatchError(ContainSubstring("contains no rpm database")))
+	_, err = ReadImage(layout, "s390x")
+	g.Expect(err).To(MatchError(ContainSubstring("no image for architecture s390x")))
+}
+
+func TestReadTar(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir := t.TempDir()
+
+	rootfs := filepath.Join(dir, "rootfs.tar.gz")
+	g.Expect(os.WriteFile(rootfs, testLayers()[0], 0644)).To(Succeed())
+	packages, err := ReadTar(rootfs)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(names(packages)).To(Equal([]string{"bash-0:5.2.37-1.fc44.x86_64"}))
+
+	empty := filepath.Join(dir, "empty.tar")
+	g.Expect(os.WriteFile(empty, tarball(tarFile{"etc/os-release", []byte("ID=fedora")}), 0644)).To(Succeed())
+	_, err = ReadTar(empty)
+	g.Expect(err).To(MatchError(ContainSubstring("contains no rpm database")))
+}
@@ -0,0 +1,279 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// layout of sqlite databases, see https://www.sqlite.org/fileformat.html
+const (
+	sqliteMagic          = "SQLite format 3\x00"
+	sqliteHeaderSize     = 100
+	sqlitePageTableLeaf  = 0x0d
+	sqlitePageTableInner = 0x05
+	sqlitePackagesTable  = "Packages"
+)
+
+type sqliteFile struct {
+	content  []byte
+	pageSize int
+	usable   int
+	visited  map[int]bool
+}
+
+// readSqlite returns the header blobs of all packages in a rpmdb.sqlite file.
+// rpm stores the headers in the `blob` column of the `Packages` table.
+func readSqlite(content []byte) ([][]byte, error) {
+	if len(content) < sqliteHeaderSize || !bytes.HasPrefix(content, []byte(sqliteMagic)) {
+		return nil, fmt.Errorf("not a sqlite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(content[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return nil, fmt.Errorf("invalid sqlite page size %d", pageSize)
+	}
+	db := &sqliteFile{
+		content:  content,
+		pageSize: pageSize,
+		usable:   pageSize - int(content[20]),
+	}
+
+	// the schema table is rooted on the first page
+	rootPage := 0
+	if err := db.scanTable(1, func(record [][]byte, types []uint64) error {
+		if len(record) < 4 || string(record[0]) != "table" || string(record[1]) != sqlitePackagesTable {
+			return nil
+		}
+		rootPage = int(sqliteInt(record[3], types[3]))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if rootPage == 0 {
+		return nil, fmt.Errorf("no %s table found", sqlitePackagesTable)
+	}
+
+	blobs := [][]byte{}
+	if err := db.scanTable(rootPage, func(record [][]byte, types []uint64) error {
+		if len(record) < 2 {
+			return fmt.Errorf("unexpected record in the %s table", sqlitePackagesTable)
+		}
+		blobs = append(blobs, record[1])
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+func (db *sqliteFile) page(pageNo int) ([]byte, error) {
+	start := (pageNo - 1) * db.pageSize
+	if pageNo < 1 || start+db.pageSize > len(db.content) {
+		return nil, fmt.Errorf("sqlite page %d is out of range", pageNo)
+	}
+	return db.content[start : start+db.pageSize], nil
+}
+
+// scanTable walks the table b-tree starting at the root page and passes the
+// columns of every row to the callback
+func (db *sqliteFile) scanTable(rootPage int, callback func(record [][]byte, types []uint64) error) error {
+	db.visited = map[int]bool{}
+	return db.scanPage(rootPage, callback)
+}
+
+func (db *sqliteFile) scanPage(pageNo int, callback func(record [][]byte, types []uint64) error) error {
+	if db.visited[pageNo] {
+		return fmt.Errorf("sqlite page %d is referenced twice", pageNo)
+	}
+	db.visited[pageNo] = true
+	page, err := db.page(pageNo)
+	if err != nil {
+		return err
+	}
+	headerStart := 0
+	if pageNo == 1 {
+		headerStart = sqliteHeaderSize
+	}
+	header := page[headerStart:]
+	cells := int(binary.BigEndian.Uint16(header[3:]))
+
+	switch header[0] {
+	case sqlitePageTableInner:
+		pointers := header[12:]
+		for i := 0; i < cells; i++ {
+			cell := int(binary.BigEndian.Uint16(pointers[2*i:]))
+			if cell+4 > len(page) {
+				return fmt.Errorf("cell %d of sqlite page %d is out of range", i, pageNo)
+			}
+			if err := db.scanPage(int(binary.BigEndian.Uint32(page[cell:])), callback); err != nil {
+				return err
+			}
+		}
+		return db.scanPage(int(binary.BigEndian.Uint32(header[8:])), callback)
+	case sqlitePageTableLeaf:
+		pointers := header[8:]
+		for i := 0; i < cells; i++ {
+			cell := int(binary.BigEndian.Uint16(pointers[2*i:]))
+			if cell >= len(page) {
+				return fmt.Errorf("cell %d of sqlite page %d is out of range", i, pageNo)
+			}
+			payload, err := db.payload(page[cell:])
+			if err != nil {
+				return fmt.Errorf("failed to read cell %d of sqlite page %d: %v", i, pageNo, err)
+			}
+			record, types, err := sqliteRecord(payload)
+			if err != nil {
+				return fmt.Errorf("failed to read cell %d of sqlite page %d: %v", i, pageNo, err)
+			}
+			if err := callback(record, types); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected sqlite page type %d on page %d", header[0], pageNo)
+}
+
+// payload returns the payload of a table leaf cell, including the content
+// which spilled to overflow pages
+func (db *sqliteFile) payload(cell []byte) ([]byte, error) {
+	size, n := sqliteVarint(cell)
+	if n == 0 {
+		return nil, fmt.Errorf("invalid payload size")
+	}
+	// skip the rowid
+	_, m := sqliteVarint(cell[n:])
+	if m == 0 {
+		return nil, fmt.Errorf("invalid rowid")
+	}
+	cell = cell[n+m:]
+
+	total := int(size)
+	local := db.localPayload(total)
+	if local > len(cell) {
+		return nil, fmt.Errorf("payload is out of range")
+	}
+	payload := make([]byte, 0, total)
+	payload = append(payload, cell[:local]...)
+	if local == total {
+		return payload, nil
+	}
+	if local+4 > len(cell) {
+		return nil, fmt.Errorf("overflow pointer is out of range")
+	}
+	overflow := int(binary.BigEndian.Uint32(cell[local:]))
+	for len(payload) < total {
+		if overflow == 0 {
+			return nil, fmt.Errorf("overflow chain ends early")
+		}
+		page, err := db.page(overflow)
+		if err != nil {
+			return nil, err
+		}
+		chunk := min(total-len(payload), db.usable-4)
+		payload = append(payload, page[4:4+chunk]...)
+		overflow = int(binary.BigEndian.Uint32(page))
+	}
+	return payload, nil
+}
+
+// localPayload calculates how much of the payload of a table leaf cell is
+// stored on the b-tree page itself
+func (db *sqliteFile) localPayload(total int) int {
+	maxLocal := db.usable - 35
+	if total <= maxLocal {
+		return total
+	}
+	minLocal := (db.usable-12)*32/255 - 23
+	local := minLocal + (total-minLocal)%(db.usable-4)
+	if local > maxLocal {
+		return minLocal
+	}
+	return local
+}
+
+// sqliteRecord splits a record into the raw values of its columns and their
+// serial types
+func sqliteRecord(payload []byte) ([][]byte, []uint64, error) {
+	headerSize, n := sqliteVarint(payload)
+	if n == 0 || int(headerSize) > len(payload) {
+		return nil, nil, fmt.Errorf("invalid record header")
+	}
+	types := []uint64{}
+	for pos := n; pos < int(headerSize); {
+		serialType, m := sqliteVarint(payload[pos:int(headerSize)])
+		if m == 0 {
+			return nil, nil, fmt.Errorf("invalid serial type")
+		}
+		types = append(types, serialType)
+		pos += m
+	}
+	values := make([][]byte, 0, len(types))
+	pos := int(headerSize)
+	for _, serialType := range types {
+		size := sqliteValueSize(serialType)
+		if pos+size > len(payload) {
+			return nil, nil, fmt.Errorf("record value is out of range")
+		}
+		values = append(values, payload[pos:pos+size])
+		pos += size
+	}
+	return values, types, nil
+}
+
+func sqliteValueSize(serialType uint64) int {
+	switch {
+	case serialType >= 12:
+		// blobs have even, texts odd serial types
+		return int(serialType-12) / 2
+	case serialType <= 4:
+		return int(serialType)
+	case serialType == 5:
+		return 6
+	case serialType == 6 || serialType == 7:
+		return 8
+	}
+	// NULL, the constants 0 and 1 and reserved types have no content
+	return 0
+}
+
+// sqliteInt decodes an integer value of a record
+func sqliteInt(value []byte, serialType uint64) int64 {
+	switch serialType {
+	case 8:
+		return 0
+	case 9:
+		return 1
+	}
+	if len(value) == 0 {
+		return 0
+	}
+	// sign extend the big endian two's complement value
+	result := int64(int8(value[0]))
+	for _, b := range value[1:] {
+		result = result<<8 | int64(b)
+	}
+	return result
+}
+
+// sqliteVarint decodes a big endian variable length integer. It returns the
+// value and the number of bytes read, or 0 bytes if the input is too short.
+func sqliteVarint(buf []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(buf) {
+			return 0, 0
+		}
+		if i == 8 {
+			return value<<8 | uint64(buf[i]), 9
+		}
+		value = value<<7 | uint64(buf[i]&0x7f)
+		if buf[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return value, 9
+}
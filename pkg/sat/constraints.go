@@ -0,0 +1,110 @@
+package sat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+)
+
+// versionConstraint is a parsed bazeldnf.Constraint
+type versionConstraint struct {
+	constraint bazeldnf.Constraint
+	allow      *versionExpression
+	exclude    *versionExpression
+}
+
+type versionExpression struct {
+	operator string
+	version  api.Version
+}
+
+func parseConstraints(constraints []bazeldnf.Constraint) ([]*versionConstraint, error) {
+	parsed := []*versionConstraint{}
+	for _, c := range constraints {
+		vc := &versionConstraint{constraint: c}
+		var err error
+		if c.Allow != "" {
+			if vc.allow, err = parseVersionExpression(c.Allow); err != nil {
+				return nil, fmt.Errorf("invalid constraint for %s: %v", c.Name, err)
+			}
+		}
+		if c.Exclude != "" {
+			if vc.exclude, err = parseVersionExpression(c.Exclude); err != nil {
+				return nil, fmt.Errorf("invalid constraint for %s: %v", c.Name, err)
+			}
+		}
+		parsed = append(parsed, vc)
+	}
+	return parsed, nil
+}
+
+func parseVersionExpression(expr string) (*versionExpression, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("expected an operator and a version, but got %q", expr)
+	}
+	switch fields[0] {
+	case "<", "<=", "=", "==", "!=", ">=", ">":
+	default:
+		return nil, fmt.Errorf("unknown operator %q in %q", fields[0], expr)
+	}
+	return &versionExpression{operator: fields[0], version: rpm.ParseVersion(fields[1])}, nil
+}
+
+// matches compares the version like a versioned requirement does. If the
+// expression has no release, only epoch and version are compared.
+func (e *versionExpression) matches(version api.Version) bool {
+	expected := e.version
+	if expected.Epoch == "" {
+		expected.Epoch = "0"
+	}
+	if version.Epoch == "" {
+		version.Epoch = "0"
+	}
+	if expected.Rel == "" {
+		version.Rel = ""
+	}
+	cmp := rpm.Compare(version, expected)
+	switch e.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	}
+	return false
+}
+
+func (c *versionConstraint) violatedBy(pkg *api.Package) bool {
+	if pkg.Name != c.constraint.Name {
+		return false
+	}
+	if c.allow != nil && !c.allow.matches(pkg.Version) {
+		return true
+	}
+	return c.exclude != nil && c.exclude.matches(pkg.Version)
+}
+
+func (c *versionConstraint) String() string {
+	s := c.constraint.Name
+	if c.constraint.Allow != "" {
+		s += " allow " + c.constraint.Allow
+	}
+	if c.constraint.Exclude != "" {
+		s += " exclude " + c.constraint.Exclude
+	}
+	if c.constraint.Reason != "" {
+		s += " (" + c.constraint.Reason + ")"
+	}
+	return s
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/crillab/gophersat/bf"
 	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/fixup"
 	"github.com/rmohr/bazeldnf/pkg/reducer"
 	"github.com/rmohr/bazeldnf/pkg/rpm"
 	"github.com/sirupsen/logrus"
@@ -21,6 +23,18 @@ type Loader struct {
 	m         *Model
 	provides  map[string][]*Var
 	varsCount int
+	fromRepo  map[string]string
+	prefer    []string
+	avoid     []string
+
+	constraints []bazeldnf.Constraint
+	// violations maps packages to the first constraint they violate
+	violations map[*api.Package]*versionConstraint
+
+	// installed contains the packages of a base layer, which are fixed in the model
+	installed map[api.PackageKey]struct{}
+
+	fixer *fixup.Fixer
 }
 
 // BestKey groups packages for the purpose of `--nobest` option disabled,
@@ -50,9 +64,46 @@ func NewLoader() *Loader {
 			vars:                        map[string]*Var{},
 			bestPackages:                map[BestKey]*api.Package{},
 			forceIgnoreWithDependencies: map[api.PackageKey]*api.Package{},
+			ambiguous:                   map[string]*ambiguousRequirement{},
 		},
-		provides:  map[string][]*Var{},
-		varsCount: 0,
+		provides:   map[string][]*Var{},
+		varsCount:  0,
+		violations: map[*api.Package]*versionConstraint{},
+		installed:  map[api.PackageKey]struct{}{},
+		fixer:      fixup.Builtin(),
+	}
+}
+
+// SetFixups replaces the built-in fixups which are applied to all packages
+func (loader *Loader) SetFixups(fixer *fixup.Fixer) {
+	loader.fixer = fixer
+}
+
+// SetFromRepo restricts the candidates of the given package names to the
+// repository they are mapped to
+func (loader *Loader) SetFromRepo(fromRepo map[string]string) {
+	loader.fromRepo = fromRepo
+}
+
+// SetPreferences adds soft clauses which avoid the alternatives of packages
+// matching `prefer` and soft clauses which avoid packages matching `avoid`.
+// Both take the same package specifications as the requested packages.
+func (loader *Loader) SetPreferences(prefer []string, avoid []string) {
+	loader.prefer = prefer
+	loader.avoid = avoid
+}
+
+// SetConstraints restricts the versions of packages which may be installed.
+// Every package which violates a constraint is excluded with a hard clause.
+func (loader *Loader) SetConstraints(constraints []bazeldnf.Constraint) {
+	loader.constraints = constraints
+}
+
+// SetInstalled marks packages as already installed. They are always part of
+// the solution and requirements are satisfied by them first.
+func (loader *Loader) SetInstalled(installed []*api.Package) {
+	for _, pkg := range installed {
+		loader.installed[pkg.Key()] = struct{}{}
 	}
 }
 
@@ -120,12 +171,52 @@ func (loader *Loader) Load(packages []*api.Package, matched, ignoreRegex, allowR
 
 	packages = nil
 	for _, k := range deduplicatedKeys {
-		reducer.FixPackages(deduplicated[k])
+		loader.fixer.Apply(deduplicated[k])
 		packages = append(packages, deduplicated[k])
 	}
 
+	packages, err := reducer.FilterFromRepo(packages, loader.fromRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, err := parseConstraints(loader.constraints)
+	if err != nil {
+		return nil, err
+	}
+	installed := []*api.Package{}
+	violating := []*api.Package{}
+	for _, pkg := range packages {
+		if _, exists := loader.installed[pkg.Key()]; exists {
+			installed = append(installed, pkg)
+		}
+		for _, c := range constraints {
+			if c.violatedBy(pkg) {
+				logrus.Infof("Package %v is excluded by constraint %v.", pkg.String(), c)
+				loader.violations[pkg] = c
+				violating = append(violating, pkg)
+				break
+			}
+		}
+	}
+
+	if len(installed) != len(loader.installed) {
+		missing := []string{}
+		for key := range loader.installed {
+			if !slices.ContainsFunc(installed, func(p *api.Package) bool { return p.Key() == key }) {
+				missing = append(missing, (&api.Package{Name: key.Name, Version: key.Version, Arch: key.Arch}).String())
+			}
+		}
+		slices.Sort(missing)
+		return nil, fmt.Errorf("installed packages are not available: %s", strings.Join(missing, ", "))
+	}
+
 	// Create an index to pick the best candidates
 	for _, pkg := range packages {
+		if _, violates := loader.violations[pkg]; violates {
+			// best candidates have to be installable, otherwise constraints could never be met without `--nobest`
+			continue
+		}
 		key := MakeBestKey(pkg)
 		if loader.m.bestPackages[key] == nil {
 			loader.m.bestPackages[key] = pkg
@@ -141,6 +232,14 @@ func (loader *Loader) Load(packages []*api.Package, matched, ignoreRegex, allowR
 		for _, v := range bestPackagesKeys {
 			packages = append(packages, loader.m.bestPackages[v])
 		}
+		// keep violating packages, they can't be installed, but allow explaining why there is no solution
+		packages = append(packages, violating...)
+		// installed packages are part of the solution, even if they are not the best candidates
+		for _, pkg := range installed {
+			if !slices.Contains(packages, pkg) {
+				packages = append(packages, pkg)
+			}
+		}
 	}
 
 	pkgProvides := [][]*Var{}
@@ -190,6 +289,19 @@ func (loader *Loader) Load(packages []*api.Package, matched, ignoreRegex, allowR
 	}
 	logrus.Infof("Generated %v variables.", len(loader.m.vars))
 
+	for _, x := range packagesKeys {
+		for _, pkgVar := range loader.m.packages[x] {
+			if _, exists := loader.installed[pkgVar.Package.Key()]; exists {
+				loader.m.ands = append(loader.m.ands, bf.Var(pkgVar.satVarName))
+			}
+			if c, violates := loader.violations[pkgVar.Package]; violates {
+				loader.m.constraints = append(loader.m.constraints, constraintClause{pkgVar: pkgVar, constraint: c})
+			}
+		}
+	}
+
+	loader.explodePreferences()
+
 	return loader.constructRequirements(matched, archOrder)
 }
 
@@ -253,6 +365,7 @@ func (loader *Loader) explodePackageRequires(pkgVar *Var) bf.Formula {
 			continue
 		}
 		requirements = append(requirements, satisfies)
+		loader.recordAmbiguousRequires(pkgVar.Package, req, satisfies)
 	}
 
 	if !ok {
@@ -275,6 +388,83 @@ func (loader *Loader) explodePackageRequires(pkgVar *Var) bf.Formula {
 	return bf.And(orRequirements...)
 }
 
+// recordAmbiguousRequires remembers requirements which can be satisfied by more than one package name,
+// so that the picked provider can be reported after solving
+func (loader *Loader) recordAmbiguousRequires(pkg *api.Package, req api.Entry, satisfies []*Var) {
+	names := map[string]struct{}{}
+	for _, s := range satisfies {
+		names[s.Package.Name] = struct{}{}
+	}
+	if len(names) < 2 {
+		return
+	}
+	ambiguous, exists := loader.m.ambiguous[req.Name]
+	if !exists {
+		ambiguous = &ambiguousRequirement{requirement: req.Name}
+		loader.m.ambiguous[req.Name] = ambiguous
+	}
+	ambiguous.requiredBy = append(ambiguous.requiredBy, pkg)
+	for _, s := range satisfies {
+		if !slices.Contains(ambiguous.providers, s.Package) {
+			ambiguous.providers = append(ambiguous.providers, s.Package)
+		}
+	}
+}
+
+// explodePreferences turns the `--prefer` and `--avoid` package specifications into soft clauses.
+// Preferring a package is expressed by avoiding all other packages which provide one of its resources,
+// since a positive soft clause would install the preferred package even if nothing requires it.
+func (loader *Loader) explodePreferences() {
+	matches := func(pkg *api.Package, specs []string) bool {
+		return slices.ContainsFunc(specs, func(spec string) bool {
+			return reducer.PackageMatchesString(pkg, spec)
+		})
+	}
+	pkgVars := map[*api.Package]*Var{}
+	for _, x := range maps.Keys(loader.m.packages) {
+		for _, v := range loader.m.packages[x] {
+			pkgVars[v.Package] = v
+		}
+	}
+	avoided := map[*api.Package]struct{}{}
+	addSoft := func(pkgVar *Var, weight int, reason string) {
+		if pkgVar == nil {
+			return
+		}
+		if _, exists := avoided[pkgVar.Package]; exists {
+			return
+		}
+		avoided[pkgVar.Package] = struct{}{}
+		logrus.Debugf("Adding soft clause with weight %d: %s", weight, reason)
+		loader.m.softs = append(loader.m.softs, softClause{pkgVar: pkgVar, weight: weight, reason: reason})
+	}
+
+	names := maps.Keys(loader.m.packages)
+	slices.Sort(names)
+	for _, name := range names {
+		for _, pkgVar := range loader.m.packages[name] {
+			if matches(pkgVar.Package, loader.avoid) {
+				addSoft(pkgVar, AvoidWeight, fmt.Sprintf("avoid %s", pkgVar.Package.String()))
+			}
+		}
+	}
+	for _, name := range names {
+		for _, pkgVar := range loader.m.packages[name] {
+			if !matches(pkgVar.Package, loader.prefer) {
+				continue
+			}
+			for _, res := range loader.explodeProvidedResources(pkgVar.Package) {
+				for _, alternative := range loader.provides[res.Name] {
+					if alternative.Package.Name == name || matches(alternative.Package, loader.prefer) {
+						continue
+					}
+					addSoft(pkgVars[alternative.Package], PreferWeight, fmt.Sprintf("prefer %s over %s", pkgVar.Package.String(), alternative.Package.String()))
+				}
+			}
+		}
+	}
+}
+
 func (loader *Loader) explodePackageConflicts(pkgVar *Var) bf.Formula {
 	conflictingVars := []bf.Formula{}
 	for _, req := range pkgVar.Package.Format.Conflicts.Entries {
@@ -289,7 +479,7 @@ func (loader *Loader) explodePackageConflicts(pkgVar *Var) bf.Formula {
 				//logrus.Infof("%s does not conflict with %s", s.Package.String(), pkgVar.Package.String())
 				continue
 			}
-			if !strings.HasPrefix(s.Package.Name, "fedora-release") && !strings.HasPrefix(pkgVar.Package.Name, "fedora-release") {
+			if !loader.fixer.QuietConflicts(s.Package) && !loader.fixer.QuietConflicts(pkgVar.Package) {
 				logrus.Infof("%s conflicts with %s", s.Package.String(), pkgVar.Package.String())
 			}
 			conflictingVars = append(conflictingVars, bf.Var(s.satVarName))
@@ -354,15 +544,25 @@ func (loader *Loader) resolveNewest(pkgName string, archOrder []string) (*Var, e
 	if len(pkgs) == 0 {
 		return nil, fmt.Errorf("package %s does not exist", pkgName)
 	}
-	newest := pkgs[0]
+	var newest *Var
 	for _, p := range pkgs {
-		if rpm.ComparePackage(p.Package, newest.Package, archOrder) > 0 {
+		if _, exists := loader.installed[p.Package.Key()]; exists {
+			return p, nil
+		}
+		if newest == nil || loader.installable(p) && !loader.installable(newest) ||
+			loader.installable(p) == loader.installable(newest) && rpm.ComparePackage(p.Package, newest.Package, archOrder) > 0 {
 			newest = p
 		}
 	}
 	return newest, nil
 }
 
+// installable reports if the package of the var does not violate any constraint
+func (loader *Loader) installable(v *Var) bool {
+	_, violates := loader.violations[v.Package]
+	return !violates
+}
+
 func compareRequires(entry api.Entry, provides []*Var) (accepts []*Var, err error) {
 	for _, dep := range provides {
 		entryVer := api.Version{
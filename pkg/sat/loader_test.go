@@ -423,7 +423,7 @@ func TestLoader_Load(t *testing.T) {
 	})
 
 	t.Run("Edge Cases and Robustness", func(t *testing.T) {
-		t.Run("should correctly handle reducer.FixPackages", func(t *testing.T) {
+		t.Run("should apply the built-in fixups", func(t *testing.T) {
 			pkg := newWithDepPackage("platform-python", "3.6", "/usr/libexec/platform-python")
 			model, _ := doLoad([]*api.Package{pkg}, nil, nil, nil, false)
 
@@ -660,4 +660,29 @@ func TestLoader_Load(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Pinned repositories", func(t *testing.T) {
+		pkgA := newSimplePackage("A", "2.0")
+		pkgA.Repository.Name = "upstream"
+		pinnedA := newSimplePackage("A", "1.0")
+		pinnedA.Repository.Name = "internal"
+		pkgB := newSimplePackage("B", "1.0")
+		pkgB.Repository.Name = "upstream"
+
+		loader := NewLoader()
+		loader.SetFromRepo(map[string]string{"A": "internal"})
+		model, err := loader.Load([]*api.Package{pkgA, pinnedA, pkgB}, nil, nil, nil, false, []string{"x86_64", "noarch"})
+		g.Expect(err).ToNot(HaveOccurred())
+		expectedPackages(g, model, map[string][]string{
+			"A": []string{"0:1.0"},
+			"B": []string{"0:1.0"},
+		})
+
+		loader = NewLoader()
+		loader.SetFromRepo(map[string]string{"B": "internal"})
+		model, err = loader.Load([]*api.Package{pkgA, pinnedA, pkgB}, nil, nil, nil, false, []string{"x86_64", "noarch"})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("package B is pinned to repository internal, but it has no candidate there"))
+		g.Expect(model).To(BeNil())
+	})
 }
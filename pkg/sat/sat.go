@@ -12,10 +12,20 @@ import (
 	"github.com/crillab/gophersat/maxsat"
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 type VarType string
 
+const (
+	// PreferWeight is the weight of the soft clauses which avoid alternatives
+	// of preferred packages
+	PreferWeight = 1000
+	// AvoidWeight is the weight of the soft clauses which avoid packages
+	AvoidWeight = 1000
+)
+
 const (
 	VarTypePackage  = "Package"
 	VarTypeResource = "Resource" // includes files
@@ -52,6 +62,32 @@ type Model struct {
 
 	ands                        []bf.Formula
 	forceIgnoreWithDependencies map[api.PackageKey]*api.Package
+
+	// softs contains additional weighted soft clauses which each avoid the installation of a package
+	softs []softClause
+
+	// ambiguous contains all requirements which can be satisfied by more than one package name
+	ambiguous map[string]*ambiguousRequirement
+
+	// constraints contains all packages which are excluded by version constraints
+	constraints []constraintClause
+}
+
+type constraintClause struct {
+	pkgVar     *Var
+	constraint *versionConstraint
+}
+
+type softClause struct {
+	pkgVar *Var
+	weight int
+	reason string
+}
+
+type ambiguousRequirement struct {
+	requirement string
+	requiredBy  []*api.Package
+	providers   []*api.Package
 }
 
 func (m *Model) Packages() map[string][]*Var {
@@ -67,7 +103,18 @@ func (m *Model) BestPackage(k BestKey) *api.Package {
 }
 
 func (m *Model) Ands() bf.Formula {
-	return bf.And(m.ands...)
+	return m.formula(true)
+}
+
+func (m *Model) formula(withConstraints bool) bf.Formula {
+	if !withConstraints || len(m.constraints) == 0 {
+		return bf.And(m.ands...)
+	}
+	ands := append([]bf.Formula{}, m.ands...)
+	for _, c := range m.constraints {
+		ands = append(ands, bf.Not(bf.Var(c.pkgVar.satVarName)))
+	}
+	return bf.And(ands...)
 }
 
 func (m *Model) ShouldIgnore(p api.PackageKey) bool {
@@ -76,7 +123,36 @@ func (m *Model) ShouldIgnore(p api.PackageKey) bool {
 }
 
 func Resolve(model *Model) (install []*api.Package, excluded []*api.Package, forceIgnoredWithDependencies []*api.Package, err error) {
-	logrus.WithField("bf", model.Ands()).Debug("Formula to solve")
+	install, excluded, forceIgnoredWithDependencies, err = resolve(model, true)
+	if err != nil && len(model.constraints) > 0 {
+		if reasons := explainConstraints(model); len(reasons) > 0 {
+			return nil, nil, nil, fmt.Errorf("%v, the following packages are required but excluded by constraints:\n%s", err, strings.Join(reasons, "\n"))
+		}
+	}
+	return install, excluded, forceIgnoredWithDependencies, err
+}
+
+// explainConstraints solves the model again without the version constraints
+// and returns which of the then installed packages violate a constraint
+func explainConstraints(model *Model) []string {
+	logrus.Info("Solving again without constraints to find violations.")
+	install, _, _, err := resolve(model, false)
+	if err != nil {
+		return nil
+	}
+	reasons := []string{}
+	for _, c := range model.constraints {
+		if slices.Contains(install, c.pkgVar.Package) {
+			reasons = append(reasons, fmt.Sprintf("  - %s violates %s", c.pkgVar.Package.String(), c.constraint))
+		}
+	}
+	slices.Sort(reasons)
+	return reasons
+}
+
+func resolve(model *Model, withConstraints bool) (install []*api.Package, excluded []*api.Package, forceIgnoredWithDependencies []*api.Package, err error) {
+	formula := model.formula(withConstraints)
+	logrus.WithField("bf", formula).Debug("Formula to solve")
 
 	satReader, satWriter := io.Pipe()
 	pwMaxSatReader, pwMaxSatWriter := io.Pipe()
@@ -88,7 +164,7 @@ func Resolve(model *Model) (install []*api.Package, excluded []*api.Package, for
 	go func() {
 		defer close(satErrChan)
 		defer satWriter.Close()
-		satErrChan <- bf.Dimacs(model.Ands(), satWriter)
+		satErrChan <- bf.Dimacs(formula, satWriter)
 	}()
 
 	go func() {
@@ -141,6 +217,14 @@ func Resolve(model *Model) (install []*api.Package, excluded []*api.Package, for
 				}
 			}
 		}
+		for _, soft := range model.softs {
+			satVar, exists := vars.pkgToSat[soft.pkgVar.satVarName]
+			if !exists {
+				continue
+			}
+			fmt.Fprintf(pwMaxSatWriter, "c %s\n", soft.reason)
+			fmt.Fprintf(pwMaxSatWriter, "%d -%s 0\n", soft.weight, satVar)
+		}
 	}()
 
 	logrus.Info("Loading the Partial weighted MAXSAT problem.")
@@ -201,6 +285,10 @@ func Resolve(model *Model) (install []*api.Package, excluded []*api.Package, for
 			install = append(install, v)
 		}
 
+		if withConstraints {
+			reportProviders(model, installSet)
+		}
+
 		for v := range excludedSet {
 			excluded = append(excluded, v)
 		}
@@ -217,3 +305,30 @@ type ConversionVars struct {
 	satToPkg map[string]string
 	pkgToSat map[string]string
 }
+
+// reportProviders logs which providers were picked for requirements of
+// installed packages which could have been satisfied by different packages
+func reportProviders(model *Model, installSet map[*api.Package]struct{}) {
+	requirements := maps.Keys(model.ambiguous)
+	slices.Sort(requirements)
+	for _, requirement := range requirements {
+		ambiguous := model.ambiguous[requirement]
+		required := slices.ContainsFunc(ambiguous.requiredBy, func(p *api.Package) bool {
+			_, installed := installSet[p]
+			return installed
+		})
+		if !required {
+			continue
+		}
+		picked := []string{}
+		alternatives := []string{}
+		for _, provider := range ambiguous.providers {
+			if _, installed := installSet[provider]; installed {
+				picked = append(picked, provider.String())
+			} else {
+				alternatives = append(alternatives, provider.String())
+			}
+		}
+		logrus.Infof("Picked %s for %s, alternatives: %s", strings.Join(picked, ", "), requirement, strings.Join(alternatives, ", "))
+	}
+}
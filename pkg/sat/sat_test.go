@@ -1258,6 +1258,9 @@ func TestNewResolver(t *testing.T) {
 		solvable      bool
 		focus         bool
 		nobest        bool
+		prefer        []string
+		avoid         []string
+		constraints   []bazeldnf.Constraint
 	}{
 		{name: "with indirect dependency", packages: []*api.Package{
 			newPkg("testa", "1", []string{"testa", "a", "b"}, []string{"d", "g"}, []string{}),
@@ -1494,6 +1497,97 @@ func TestNewResolver(t *testing.T) {
 			solvable:      true,
 		},
 
+		{name: "prefer one of several providers", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{"cap"}, []string{}),
+			newPkg("testb", "1", []string{"cap"}, []string{}, []string{}),
+			newPkg("testc", "1", []string{"cap"}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			prefer:   []string{"testc"},
+			install:  []string{"testa-0:1", "testc-0:1"},
+			exclude:  []string{"testb-0:1"},
+			solvable: true,
+		},
+		{name: "prefer another one of several providers", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{"cap"}, []string{}),
+			newPkg("testb", "1", []string{"cap"}, []string{}, []string{}),
+			newPkg("testc", "1", []string{"cap"}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			prefer:   []string{"testb"},
+			install:  []string{"testa-0:1", "testb-0:1"},
+			exclude:  []string{"testc-0:1"},
+			solvable: true,
+		},
+		{name: "avoid one of several providers", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{"cap"}, []string{}),
+			newPkg("testb", "1", []string{"cap"}, []string{}, []string{}),
+			newPkg("testc", "1", []string{"cap"}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			avoid:    []string{"testb"},
+			install:  []string{"testa-0:1", "testc-0:1"},
+			exclude:  []string{"testb-0:1"},
+			solvable: true,
+		},
+		{name: "avoided package is installed if it is the only provider", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{"cap"}, []string{}),
+			newPkg("testb", "1", []string{"cap"}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			avoid:    []string{"testb"},
+			install:  []string{"testa-0:1", "testb-0:1"},
+			solvable: true,
+		},
+		{name: "preferred package is not installed if nothing requires it", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{}, []string{}),
+			newPkg("testb", "1", []string{"cap"}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			prefer:   []string{"testb"},
+			install:  []string{"testa-0:1"},
+			exclude:  []string{"testb-0:1"},
+			solvable: true,
+		},
+
+		{name: "constraint picks older version", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{"testb"}, []string{}),
+			newPkg("testb", "1", []string{}, []string{}, []string{}),
+			newPkg("testb", "2", []string{}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			constraints: []bazeldnf.Constraint{{Name: "testb", Allow: "< 2"}},
+			install:     []string{"testa-0:1", "testb-0:1"},
+			exclude:     []string{"testb-0:2"},
+			solvable:    true,
+		},
+		{name: "constraint excludes requested version", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{}, []string{}),
+			newPkg("testa", "2", []string{}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			constraints: []bazeldnf.Constraint{{Name: "testa", Exclude: ">= 2"}},
+			install:     []string{"testa-0:1"},
+			exclude:     []string{"testa-0:2"},
+			solvable:    true,
+		},
+		{name: "constraint makes problem unsolvable", packages: []*api.Package{
+			newPkg("testa", "1", []string{}, []string{"testb"}, []string{}),
+			newPkg("testb", "2", []string{}, []string{}, []string{}),
+		}, requires: []string{
+			"testa",
+		},
+			constraints: []bazeldnf.Constraint{{Name: "testb", Exclude: "< 3"}},
+			solvable:    false,
+		},
+
 		// TODO: Add test cases.
 	}
 	focus := false
@@ -1513,6 +1607,8 @@ func TestNewResolver(t *testing.T) {
 			if len(architectures) == 0 {
 				architectures = []string{"x86_64", "noarch"}
 			}
+			loader.SetPreferences(tt.prefer, tt.avoid)
+			loader.SetConstraints(tt.constraints)
 			model, err := loader.Load(tt.packages, tt.requires, tt.ignoreRegex, tt.allowRegex, tt.nobest, architectures)
 			if err != nil {
 				t.Fail()
@@ -1581,3 +1677,72 @@ func pkgToString(given []*api.Package) (resolved []string) {
 	}
 	return
 }
+
+func TestConstraintViolationReasons(t *testing.T) {
+	g := NewGomegaWithT(t)
+	loader := NewLoader()
+	loader.SetConstraints([]bazeldnf.Constraint{
+		{Name: "testb", Exclude: "< 3", Reason: "CVE-1234"},
+		{Name: "testc", Allow: "<= 1"},
+	})
+	model, err := loader.Load([]*api.Package{
+		newPkg("testa", "1", []string{}, []string{"testb", "testc"}, []string{}),
+		newPkg("testb", "2", []string{}, []string{}, []string{}),
+		newPkg("testc", "1", []string{}, []string{}, []string{}),
+	}, []string{"testa"}, nil, nil, false, []string{"x86_64", "noarch"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, _, _, err = Resolve(model)
+	g.Expect(err).To(MatchError(ContainSubstring("testb-0:2 violates testb exclude < 3 (CVE-1234)")))
+	g.Expect(err.Error()).ToNot(ContainSubstring("testc"))
+}
+
+func TestVersionExpression(t *testing.T) {
+	g := NewGomegaWithT(t)
+	tests := []struct {
+		expr    string
+		version api.Version
+		matches bool
+	}{
+		{"< 6.9", api.Version{Epoch: "0", Ver: "6.8.5", Rel: "1.fc40"}, true},
+		{"< 6.9", api.Version{Epoch: "0", Ver: "6.9", Rel: "1.fc40"}, false},
+		{"= 6.9", api.Version{Epoch: "0", Ver: "6.9", Rel: "3.fc40"}, true},
+		{"= 6.9-2.fc40", api.Version{Epoch: "0", Ver: "6.9", Rel: "3.fc40"}, false},
+		{">= 1:1.0", api.Version{Epoch: "0", Ver: "2.0", Rel: "1"}, false},
+		{"!= 2", api.Version{Ver: "2"}, false},
+		{"> 2", api.Version{Ver: "10"}, true},
+	}
+	for _, tt := range tests {
+		expr, err := parseVersionExpression(tt.expr)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(expr.matches(tt.version)).To(Equal(tt.matches), "%s with %s", tt.expr, tt.version.String())
+	}
+
+	_, err := parseVersionExpression("~> 2")
+	g.Expect(err).To(HaveOccurred())
+	_, err = parseVersionExpression("2")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestInstalledPackages(t *testing.T) {
+	g := NewGomegaWithT(t)
+	installedB := newPkg("testb", "1", []string{}, []string{}, []string{})
+	packages := []*api.Package{
+		newPkg("testa", "1", []string{}, []string{"testb"}, []string{}),
+		installedB,
+		newPkg("testb", "2", []string{}, []string{}, []string{}),
+	}
+
+	loader := NewLoader()
+	loader.SetInstalled([]*api.Package{installedB})
+	model, err := loader.Load(packages, []string{"testb", "testa"}, nil, nil, false, []string{"x86_64", "noarch"})
+	g.Expect(err).ToNot(HaveOccurred())
+	install, _, _, err := Resolve(model)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pkgToString(install)).To(ConsistOf("testa-0:1", "testb-0:1"))
+
+	loader = NewLoader()
+	loader.SetInstalled([]*api.Package{newPkg("testc", "1", []string{}, []string{}, []string{})})
+	_, err = loader.Load(packages, []string{"testa"}, nil, nil, false, []string{"x86_64", "noarch"})
+	g.Expect(err).To(MatchError("installed packages are not available: testc-0:1"))
+}
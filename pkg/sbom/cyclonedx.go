@@ -0,0 +1,132 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/license"
+)
+
+type cycloneDXDocument struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     cycloneDXTools     `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type               string                       `json:"type"`
+	BOMRef             string                       `json:"bom-ref,omitempty"`
+	Supplier           *cycloneDXOrganization       `json:"supplier,omitempty"`
+	Name               string                       `json:"name"`
+	Version            string                       `json:"version,omitempty"`
+	Hashes             []cycloneDXHash              `json:"hashes,omitempty"`
+	Licenses           []cycloneDXLicenseChoice     `json:"licenses,omitempty"`
+	PURL               string                       `json:"purl,omitempty"`
+	ExternalReferences []cycloneDXExternalReference `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty          `json:"properties,omitempty"`
+}
+
+type cycloneDXOrganization struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// cycloneDXLicenseChoice contains either an SPDX expression or a license name
+type cycloneDXLicenseChoice struct {
+	Expression string            `json:"expression,omitempty"`
+	License    *cycloneDXLicense `json:"license,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// WriteCycloneDX writes the document as CycloneDX 1.5 JSON
+func WriteCycloneDX(w io.Writer, doc *Document) error {
+	bom := cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + doc.uuid(),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: doc.Created.UTC().Format(time.RFC3339),
+			Tools: cycloneDXTools{
+				Components: []cycloneDXComponent{{Type: "application", Name: Tool}},
+			},
+			Component: cycloneDXComponent{Type: "container", Name: doc.Name},
+		},
+		Components:   []cycloneDXComponent{},
+		Dependencies: []cycloneDXDependency{},
+	}
+	for _, pkg := range doc.Packages {
+		purl := pkg.PURL(doc.Namespace)
+		c := cycloneDXComponent{
+			Type:    "library",
+			BOMRef:  purl,
+			Name:    pkg.Name,
+			Version: pkg.EVR(),
+			PURL:    purl,
+		}
+		if pkg.Vendor != "" {
+			c.Supplier = &cycloneDXOrganization{Name: pkg.Vendor}
+		}
+		if pkg.SHA256 != "" {
+			c.Hashes = []cycloneDXHash{{Alg: "SHA-256", Content: pkg.SHA256}}
+		}
+		if license.Valid(pkg.License) {
+			c.Licenses = []cycloneDXLicenseChoice{{Expression: pkg.License}}
+		} else if pkg.License != "" {
+			c.Licenses = []cycloneDXLicenseChoice{{License: &cycloneDXLicense{Name: pkg.License}}}
+		}
+		if pkg.DownloadURL != "" {
+			c.ExternalReferences = []cycloneDXExternalReference{{Type: "distribution", URL: pkg.DownloadURL}}
+		}
+		if pkg.SourceRPM != "" {
+			c.Properties = []cycloneDXProperty{{Name: Tool + ":sourcerpm", Value: pkg.SourceRPM}}
+		}
+		bom.Components = append(bom.Components, c)
+
+		dependsOn := []string{}
+		for _, dep := range pkg.Dependencies {
+			dependsOn = append(dependsOn, dep.PURL(doc.Namespace))
+		}
+		bom.Dependencies = append(bom.Dependencies, cycloneDXDependency{Ref: purl, DependsOn: dependsOn})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(bom)
+}
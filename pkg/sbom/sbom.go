@@ -0,0 +1,145 @@
+// Package sbom writes software bills of materials for sets of RPMs in the
+// SPDX and CycloneDX formats.
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+// Tool is the name of the creator of the documents
+const Tool = "bazeldnf"
+
+// Package is an RPM of the SBOM
+type Package struct {
+	Name      string
+	Epoch     string
+	Version   string
+	Release   string
+	Arch      string
+	License   string
+	SourceRPM string
+	Vendor    string
+	// SHA256 is the hex encoded checksum of the RPM file
+	SHA256 string
+	// DownloadURL is the location the RPM file is downloaded from
+	DownloadURL string
+	// Dependencies contains the packages this package requires
+	Dependencies []*Package
+}
+
+// Document is the content of an SBOM
+type Document struct {
+	Name string
+	// Namespace is the namespace of the package URLs, usually the distribution
+	Namespace string
+	Created   time.Time
+	Packages  []*Package
+}
+
+// FromPackage creates an SBOM package from repository or rpm database metadata
+func FromPackage(pkg *api.Package) *Package {
+	p := &Package{
+		Name:      pkg.Name,
+		Epoch:     pkg.Version.Epoch,
+		Version:   pkg.Version.Ver,
+		Release:   pkg.Version.Rel,
+		Arch:      pkg.Arch,
+		License:   pkg.Format.License,
+		SourceRPM: pkg.Format.Sourcerpm,
+		Vendor:    pkg.Format.Vendor,
+	}
+	if pkg.Checksum.Type == "sha256" {
+		p.SHA256 = pkg.Checksum.Text
+	}
+	return p
+}
+
+// SHA256FromIntegrity returns the hex encoded checksum of a sha256 subresource
+// integrity, like it is stored in lock files. Other hashes return "".
+func SHA256FromIntegrity(integrity string) string {
+	encoded, found := strings.CutPrefix(integrity, "sha256-")
+	if !found {
+		return ""
+	}
+	hash, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash)
+}
+
+// EVR returns the version of the package, the epoch is omitted if it is 0
+func (p *Package) EVR() string {
+	evr := p.Version
+	if p.Release != "" {
+		evr += "-" + p.Release
+	}
+	if p.Epoch != "" && p.Epoch != "0" {
+		evr = p.Epoch + ":" + evr
+	}
+	return evr
+}
+
+// NEVRA returns `name-[epoch:]version-release.arch`
+func (p *Package) NEVRA() string {
+	nevra := p.Name + "-" + p.EVR()
+	if p.Arch != "" {
+		nevra += "." + p.Arch
+	}
+	return nevra
+}
+
+// PURL returns the package URL, like `pkg:rpm/fedora/bash@5.2.37-1.fc44?arch=x86_64`
+func (p *Package) PURL(namespace string) string {
+	purl := "pkg:rpm/"
+	if namespace != "" {
+		purl += purlEscape(namespace) + "/"
+	}
+	purl += purlEscape(p.Name)
+	version := p.Version
+	if p.Release != "" {
+		version += "-" + p.Release
+	}
+	if version != "" {
+		purl += "@" + purlEscape(version)
+	}
+	qualifiers := []string{}
+	if p.Arch != "" {
+		qualifiers = append(qualifiers, "arch="+url.QueryEscape(p.Arch))
+	}
+	if p.Epoch != "" && p.Epoch != "0" {
+		qualifiers = append(qualifiers, "epoch="+url.QueryEscape(p.Epoch))
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
+
+// purlEscape percent-encodes a segment of a package URL, including `+`
+func purlEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "+", "%2B")
+}
+
+// uuid derives a stable UUID from the content of the document, so that
+// generating the SBOM of the same packages twice gives the same result
+func (d *Document) uuid() string {
+	hash := sha256.New()
+	fmt.Fprintln(hash, d.Name, d.Namespace)
+	for _, pkg := range d.Packages {
+		fmt.Fprintln(hash, pkg.NEVRA(), pkg.SHA256)
+	}
+	sum := hash.Sum(nil)
+	// format it as a version 5 UUID with the RFC 4122 variant
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
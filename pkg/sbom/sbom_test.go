@@ -0,0 +1,135 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDocument() *Document {
+	glibc := &Package{
+		Name: "glibc", Epoch: "0", Version: "2.41", Release: "3.fc44", Arch: "x86_64",
+		License: "LGPL-2.1-or-later AND GPL-2.0-or-later WITH GCC-exception-2.0", Vendor: "Fedora Project",
+		SourceRPM: "glibc-2.41-3.fc44.src.rpm",
+		SHA256:    "a9dbd6ca21f3bd5b5fbd3cdc4d71fcd2263fa6a9e5a2958c9b4eb1b8b5d6f100",
+	}
+	bash := &Package{
+		Name: "bash", Epoch: "0", Version: "5.2.37", Release: "1.fc44", Arch: "x86_64",
+		License:      "GPLv3+ and GFDL",
+		DownloadURL:  "https://example.com/Packages/b/bash-5.2.37-1.fc44.x86_64.rpm",
+		Dependencies: []*Package{glibc},
+	}
+	return &Document{
+		Name:      "image",
+		Namespace: "fedora",
+		Created:   time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		Packages:  []*Package{bash, glibc},
+	}
+}
+
+func TestPURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(testDocument().Packages[0].PURL("fedora")).Should(Equal("pkg:rpm/fedora/bash@5.2.37-1.fc44?arch=x86_64"))
+	pkg := &Package{Name: "libstdc++", Epoch: "1", Version: "15.1", Release: "1.fc44", Arch: "aarch64"}
+	g.Expect(pkg.PURL("fedora")).Should(Equal("pkg:rpm/fedora/libstdc%2B%2B@15.1-1.fc44?arch=aarch64&epoch=1"))
+	g.Expect(pkg.NEVRA()).Should(Equal("libstdc++-1:15.1-1.fc44.aarch64"))
+}
+
+func TestSHA256FromIntegrity(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(SHA256FromIntegrity("sha256-qr0JnQ==")).Should(Equal("aabd099d"))
+	g.Expect(SHA256FromIntegrity("sha512-qr0JnQ==")).Should(BeEmpty())
+}
+
+func TestWriteSPDX(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	buf := &bytes.Buffer{}
+	g.Expect(WriteSPDX(buf, testDocument())).To(Succeed())
+	doc := spdxDocument{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &doc)).To(Succeed())
+
+	g.Expect(doc.SPDXVersion).Should(Equal("SPDX-2.3"))
+	g.Expect(doc.CreationInfo.Created).Should(Equal("2026-10-01T12:00:00Z"))
+	g.Expect(doc.Packages).Should(HaveLen(2))
+	bash, glibc := doc.Packages[0], doc.Packages[1]
+	g.Expect(bash.SPDXID).Should(Equal("SPDXRef-Package-bash-5.2.37-1.fc44.x86-64"))
+	g.Expect(bash.DownloadLocation).Should(Equal("https://example.com/Packages/b/bash-5.2.37-1.fc44.x86_64.rpm"))
+	g.Expect(bash.LicenseDeclared).Should(Equal("LicenseRef-GPLv3-and-GFDL"))
+	g.Expect(bash.LicenseComments).Should(ContainSubstring("GPLv3+ and GFDL"))
+	g.Expect(doc.HasExtractedLicensingInfos).Should(Equal([]spdxExtractedLicense{{
+		LicenseId:     "LicenseRef-GPLv3-and-GFDL",
+		ExtractedText: "GPLv3+ and GFDL",
+		Name:          "GPLv3+ and GFDL",
+		Comment:       "The license declared by the package, which is not an SPDX license expression",
+	}}))
+	g.Expect(bash.ExternalRefs[0].ReferenceLocator).Should(Equal("pkg:rpm/fedora/bash@5.2.37-1.fc44?arch=x86_64"))
+	g.Expect(glibc.Supplier).Should(Equal("Organization: Fedora Project"))
+	g.Expect(glibc.LicenseDeclared).Should(Equal("LGPL-2.1-or-later AND GPL-2.0-or-later WITH GCC-exception-2.0"))
+	g.Expect(glibc.Checksums).Should(Equal([]spdxChecksum{{Algorithm: "SHA256", ChecksumValue: testDocument().Packages[1].SHA256}}))
+	g.Expect(doc.Relationships).Should(ContainElement(spdxRelationship{
+		SPDXElementID:      bash.SPDXID,
+		RelationshipType:   "DEPENDS_ON",
+		RelatedSPDXElement: glibc.SPDXID,
+	}))
+
+	// the same packages give the same document
+	again := &bytes.Buffer{}
+	g.Expect(WriteSPDX(again, testDocument())).To(Succeed())
+	g.Expect(again.String()).Should(Equal(buf.String()))
+}
+
+func TestSPDXLicenses(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	licenses := &spdxLicenses{byLicense: map[string]string{}}
+	g.Expect(licenses.declare("MIT AND GPL-2.0+")).Should(Equal("MIT AND GPL-2.0+"))
+	// licenses which are not on the SPDX license list
+	g.Expect(licenses.declare("GPLv3+")).Should(Equal("LicenseRef-GPLv3"))
+	g.Expect(licenses.declare("AGPLv3")).Should(Equal("LicenseRef-AGPLv3"))
+	g.Expect(licenses.declare("GPLv3+")).Should(Equal("LicenseRef-GPLv3"))
+	g.Expect(licenses.declare("GPLv3")).Should(Equal("LicenseRef-GPLv3-2"))
+	g.Expect(licenses.declare("LicenseRef-Fedora-Public-Domain OR MIT")).Should(Equal("LicenseRef-Fedora-Public-Domain OR MIT"))
+
+	ids := []string{}
+	for _, info := range licenses.infos {
+		ids = append(ids, info.LicenseId)
+	}
+	g.Expect(ids).Should(Equal([]string{"LicenseRef-GPLv3", "LicenseRef-AGPLv3", "LicenseRef-GPLv3-2", "LicenseRef-Fedora-Public-Domain"}))
+	g.Expect(licenses.infos[2].ExtractedText).Should(Equal("GPLv3"))
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	buf := &bytes.Buffer{}
+	g.Expect(WriteCycloneDX(buf, testDocument())).To(Succeed())
+	bom := cycloneDXDocument{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &bom)).To(Succeed())
+
+	g.Expect(bom.SpecVersion).Should(Equal("1.5"))
+	g.Expect(bom.SerialNumber).Should(MatchRegexp(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+	g.Expect(bom.Components).Should(HaveLen(2))
+	bash, glibc := bom.Components[0], bom.Components[1]
+	g.Expect(bash.Licenses).Should(Equal([]cycloneDXLicenseChoice{{License: &cycloneDXLicense{Name: "GPLv3+ and GFDL"}}}))
+	g.Expect(glibc.Licenses[0].Expression).Should(HavePrefix("LGPL-2.1-or-later"))
+	g.Expect(glibc.Supplier.Name).Should(Equal("Fedora Project"))
+	g.Expect(glibc.Hashes[0].Alg).Should(Equal("SHA-256"))
+	g.Expect(bom.Dependencies).Should(Equal([]cycloneDXDependency{
+		{Ref: bash.BOMRef, DependsOn: []string{glibc.BOMRef}},
+		{Ref: glibc.BOMRef, DependsOn: []string{}},
+	}))
+
+	// a valid expression of a license which is not on the SPDX license list
+	doc := testDocument()
+	doc.Packages[0].License = "GPLv3+"
+	buf.Reset()
+	g.Expect(WriteCycloneDX(buf, doc)).To(Succeed())
+	g.Expect(json.Unmarshal(buf.Bytes(), &bom)).To(Succeed())
+	g.Expect(bom.Components[0].Licenses).Should(Equal([]cycloneDXLicenseChoice{{License: &cycloneDXLicense{Name: "GPLv3+"}}}))
+}
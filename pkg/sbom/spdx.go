@@ -0,0 +1,220 @@
+package sbom
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/license"
+)
+
+const noAssertion = "NOASSERTION"
+
+// invalidSPDXIdChars matches characters which are not allowed in SPDX ids
+var invalidSPDXIdChars = regexp.MustCompile(`[^A-Za-z0-9.\-]`)
+
+// licenseRefSeparators matches the characters which are replaced by a single
+// dash in the license references of declared licenses
+var licenseRefSeparators = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+	// HasExtractedLicensingInfos declares the license references which are
+	// used by the packages
+	HasExtractedLicensingInfos []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	Supplier         string            `json:"supplier"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	LicenseComments  string            `json:"licenseComments,omitempty"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseId     string `json:"licenseId"`
+	ExtractedText string `json:"extractedText"`
+	Name          string `json:"name"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+func spdxId(pkg *Package) string {
+	return "SPDXRef-Package-" + invalidSPDXIdChars.ReplaceAllString(pkg.NEVRA(), "-")
+}
+
+// spdxLicenses collects the license references of a document. Licenses which
+// are no SPDX expressions of known licenses get a license reference, their
+// text is the declared license.
+type spdxLicenses struct {
+	byLicense map[string]string
+	infos     []spdxExtractedLicense
+}
+
+// declare returns the SPDX expression for the declared license of a package
+func (l *spdxLicenses) declare(declared string) string {
+	expr, err := license.Parse(declared)
+	if err != nil {
+		return l.reference(declared)
+	}
+	for _, term := range expr.Terms() {
+		if strings.HasPrefix(term.License, "LicenseRef-") {
+			l.add(term.License, spdxExtractedLicense{
+				LicenseId:     term.License,
+				ExtractedText: term.License,
+				Name:          strings.TrimPrefix(term.License, "LicenseRef-"),
+				Comment:       "The license is only known by the license reference declared by the package",
+			})
+		}
+	}
+	return declared
+}
+
+// reference returns the license reference for a license which is no SPDX
+// expression of known licenses
+func (l *spdxLicenses) reference(declared string) string {
+	if id, exists := l.byLicense[declared]; exists {
+		return id
+	}
+	base := "LicenseRef-" + cmp.Or(strings.Trim(licenseRefSeparators.ReplaceAllString(declared, "-"), "-"), "unknown")
+	id := base
+	for i := 2; l.exists(id); i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	l.add(id, spdxExtractedLicense{
+		LicenseId:     id,
+		ExtractedText: declared,
+		Name:          declared,
+		Comment:       "The license declared by the package, which is not an SPDX license expression",
+	})
+	l.byLicense[declared] = id
+	return id
+}
+
+func (l *spdxLicenses) add(id string, info spdxExtractedLicense) {
+	if !l.exists(id) {
+		l.infos = append(l.infos, info)
+	}
+}
+
+func (l *spdxLicenses) exists(id string) bool {
+	for _, info := range l.infos {
+		if info.LicenseId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteSPDX writes the document as SPDX 2.3 JSON
+func WriteSPDX(w io.Writer, doc *Document) error {
+	spdx := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              doc.Name,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + Tool + "/" + doc.uuid(),
+		CreationInfo: spdxCreationInfo{
+			Created:  doc.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + Tool},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	licenses := &spdxLicenses{byLicense: map[string]string{}}
+	for _, pkg := range doc.Packages {
+		p := spdxPackage{
+			SPDXID:           spdxId(pkg),
+			Name:             pkg.Name,
+			VersionInfo:      pkg.EVR(),
+			Supplier:         noAssertion,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  pkg.PURL(doc.Namespace),
+			}},
+		}
+		if pkg.Vendor != "" {
+			p.Supplier = "Organization: " + pkg.Vendor
+		}
+		if pkg.DownloadURL != "" {
+			p.DownloadLocation = pkg.DownloadURL
+		}
+		if pkg.SHA256 != "" {
+			p.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: pkg.SHA256}}
+		}
+		if pkg.SourceRPM != "" {
+			p.SourceInfo = "built from source RPM " + pkg.SourceRPM
+		}
+		if pkg.License != "" {
+			p.LicenseDeclared = licenses.declare(pkg.License)
+			if !license.Valid(pkg.License) {
+				p.LicenseComments = "The package declares the license " + pkg.License + ", which is not an SPDX license expression"
+			}
+		}
+		spdx.Packages = append(spdx.Packages, p)
+		spdx.Relationships = append(spdx.Relationships, spdxRelationship{
+			SPDXElementID:      spdx.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: p.SPDXID,
+		})
+	}
+	for _, pkg := range doc.Packages {
+		for _, dep := range pkg.Dependencies {
+			spdx.Relationships = append(spdx.Relationships, spdxRelationship{
+				SPDXElementID:      spdxId(pkg),
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: spdxId(dep),
+			})
+		}
+	}
+	spdx.HasExtractedLicensingInfos = licenses.infos
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(spdx)
+}